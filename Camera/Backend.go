@@ -0,0 +1,87 @@
+package Camera7670
+
+import (
+	"context"
+	"errors"
+)
+
+/*
+~ File Description:
+^ A CaptureBackend moves the lines of a frame from D[7:0] into buffers of the caller, the capture loops in DS only deal with lines.
+^ BitBang polls PCLK with the CPU and is used when OV7670.Backend is nil, PIOBackend (PIO.go) samples with a PIO state machine and DMA.
+^ The checked capture and the pin calibration follow HREF edge by edge and always poll the pins.
+*/
+
+// & Error of a line that did not start because VSync started the next frame first.
+var ErrFrameEnded = errors.New("VSync started the next frame before the line.")
+
+/*
+ * @brief = Source of the lines of a frame.
+ * @method StartFrame = Waits for the start of a frame and gets ready for lines of lineBytes clocked bytes.
+ * @method ReadLine = Reads the next line, keeping every stride-th clocked byte in line, len(line) * stride bytes are clocked.
+ * ^ It returns the bytes clocked, and ErrFrameEnded if VSync started the next frame first or the *TimeoutError of the stalled signal.
+ * @method EndFrame = Stops the capture after the last line or an error.
+ */
+type CaptureBackend interface {
+	StartFrame(ctx context.Context, Cam *OV7670, lineBytes int) error
+	ReadLine(ctx context.Context, Cam *OV7670, line []byte, stride int) (int, error)
+	EndFrame(Cam *OV7670)
+}
+
+// & OneLine Brief = Returns the backend captures go through, BitBang when Backend is nil.
+func (Cam *OV7670) CaptureBackend() CaptureBackend {
+	if Cam.Backend == nil {
+		return BitBang{}
+	}
+	return Cam.Backend
+}
+
+/*
+ * @brief = CaptureBackend that polls VSync, HREF and PCLK and reads DataPins with the CPU, PCLK has to be slow enough for the loop.
+ * ^ Every line starts on a rising edge of HREF, a line that already started when ReadLine is called is skipped.
+ * ^ So a caller slower than the line blanking loses whole lines instead of merging them, the rows after move up and the
+ * ^ frame ends before the last rows with ErrFrameEnded: the rows that did not arrive are the lines that were lost.
+ */
+type BitBang struct{}
+
+func (BitBang) StartFrame(ctx context.Context, Cam *OV7670, lineBytes int) error {
+	return Cam.WaitForNewFrameContext(ctx)
+}
+
+func (BitBang) ReadLine(ctx context.Context, Cam *OV7670, line []byte, stride int) (int, error) {
+	// A line that started before the call is skipped, its first bytes are gone.
+	if err := Cam.WaitForHorizontalSyncHighContext(ctx); err != nil {
+		return 0, err
+	}
+	// VSync may still be high from the start of the frame, only a new rising edge ends it.
+	vsync_low := false
+	for polls := 1; !Cam.HSync.Get(); polls++ {
+		if !Cam.VSync.Get() {
+			vsync_low = true
+		} else if vsync_low {
+			return 0, ErrFrameEnded
+		}
+		if polls%DEADLINE_POLLS == 0 {
+			if err := Expired(ctx, SIGNAL_HREF, true); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	clocked := 0
+	for ; clocked < len(line)*stride; clocked++ {
+		if err := Cam.WaitForPixelClockLowContext(ctx); err != nil {
+			return clocked, err
+		}
+		if clocked%stride == 0 {
+			line[clocked/stride] = Cam.ReadPins()
+		}
+		if err := Cam.WaitForPixelClockHighContext(ctx); err != nil {
+			return clocked, err
+		}
+	}
+
+	return clocked, nil
+}
+
+func (BitBang) EndFrame(Cam *OV7670) {}
@@ -0,0 +1,187 @@
+package Camera7670
+
+import "fmt"
+
+/*
+~ File Description:
+^ Banding filter and night mode, both part of the automatic exposure (AEC) and computed from the frame timing.
+^ Lamps on 50Hz or 60Hz mains flicker at 100Hz or 120Hz, the banding filter keeps the exposure a multiple of that period.
+^ The steps are counted in lines, SetFrameRate computes them again for the new line time.
+*/
+
+type BANDING int
+
+const (
+	BANDING_OFF  = iota
+	BANDING_50HZ // Lighting on 50Hz mains (Europe, Asia, Africa).
+	BANDING_60HZ // Lighting on 60Hz mains (Americas).
+	BANDING_AUTO // The sensor detects the light frequency (COM11).
+)
+
+func (b BANDING) String() string {
+	switch b {
+	case BANDING_OFF:
+		return "OFF"
+	case BANDING_50HZ:
+		return "50HZ"
+	case BANDING_60HZ:
+		return "60HZ"
+	case BANDING_AUTO:
+		return "AUTO"
+	}
+
+	return "NOT VALID"
+}
+
+type NIGHT_MODE int
+
+const (
+	NIGHT_MODE_OFF          = iota
+	NIGHT_MODE_FULL_RATE    // Night mode without dropping the frame rate.
+	NIGHT_MODE_HALF_RATE    // The frame rate may drop to 1/2.
+	NIGHT_MODE_QUARTER_RATE // The frame rate may drop to 1/4.
+	NIGHT_MODE_EIGHTH_RATE  // The frame rate may drop to 1/8.
+)
+
+func (n NIGHT_MODE) String() string {
+	switch n {
+	case NIGHT_MODE_OFF:
+		return "OFF"
+	case NIGHT_MODE_FULL_RATE:
+		return "FULL RATE"
+	case NIGHT_MODE_HALF_RATE:
+		return "HALF RATE"
+	case NIGHT_MODE_QUARTER_RATE:
+		return "QUARTER RATE"
+	case NIGHT_MODE_EIGHTH_RATE:
+		return "EIGHTH RATE"
+	}
+
+	return "NOT VALID"
+}
+
+/*
+ * @brief = Light frequency options of the automatic exposure, they only act while AEC is on.
+ * @element Filter = Banding filter to use.
+ * @element Night = Night mode and the lowest frame rate it may drop to.
+ * @element ExposureBelowBand = Allow an exposure shorter than one banding step under strong light.
+ */
+type BandingOptions struct {
+	Filter            BANDING
+	Night             NIGHT_MODE
+	ExposureBelowBand bool
+}
+
+/*
+ * @brief = Register values computed by SetBanding.
+ * @element Options = Options they were computed for.
+ * @elements Step50, Step60 = Lines exposed in one flicker period of 50Hz and 60Hz light (BD50ST, BD60ST).
+ * @elements Max50, Max60 = Banding steps that fit in one frame (BD50MAX, BD60MAX).
+ * @element MinFPS = Lowest frame rate night mode may drop to.
+ */
+type BandingSettings struct {
+	Options BandingOptions
+	Step50  int
+	Step60  int
+	Max50   int
+	Max60   int
+	MinFPS  float64
+}
+
+func (b BandingSettings) String() string {
+	return fmt.Sprintf("banding %s, 50Hz %d lines x%d, 60Hz %d lines x%d, night mode %s down to %.2f fps", b.Options.Filter.String(), b.Step50, b.Max50, b.Step60, b.Max60, b.Options.Night.String(), b.MinFPS)
+}
+
+// & OneLine Brief = Writes of the COM8 banding enable, BD50ST/BD60ST, BD50MAX/BD60MAX and COM11.
+func (b BandingSettings) sequence() RegisterSequence {
+	com11 := COM11{
+		NightMode:         b.Options.Night != NIGHT_MODE_OFF,
+		AutoBanding:       b.Options.Filter == BANDING_AUTO,
+		Banding50Hz:       b.Options.Filter == BANDING_50HZ,
+		ExposureBelowBand: b.Options.ExposureBelowBand,
+	}
+	if com11.NightMode {
+		com11.NightDivider = uint8(b.Options.Night - NIGHT_MODE_FULL_RATE)
+	}
+
+	// COM11[2] and COM11[0] are reserved.
+	return RegisterSequence{
+		{REG_COM8, COM8{BandingFilter: b.Options.Filter != BANDING_OFF}.Value(), COM8{BandingFilter: true}.Value(), 0},
+		{REG_BD50ST, uint8(b.Step50), MASK_ALL, 0},
+		{REG_BD60ST, uint8(b.Step60), MASK_ALL, 0},
+		{REG_BD50MAX, uint8(b.Max50), MASK_ALL, 0},
+		{REG_BD60MAX, uint8(b.Max60), MASK_ALL, 0},
+		{REG_COM11, com11.Value(), 0xFA, 0},
+	}
+}
+
+/*
+ * @brief = Computes the banding steps and night mode limit for a frame timing.
+ * @param timing = Timing the sensor runs at, see FrameTiming and SetFrameRate.
+ * @param options = Banding filter and night mode wanted.
+ * @return = The settings or an error if an option is not valid or the line time does not fit the step registers.
+ */
+func ComputeBanding(timing FrameTiming, options BandingOptions) (BandingSettings, error) {
+	if options.Filter.String() == "NOT VALID" || options.Night.String() == "NOT VALID" {
+		return BandingSettings{}, fmt.Errorf("INVALID BANDING OPTIONS, FILTER: %s | NIGHT MODE: %s", options.Filter.String(), options.Night.String())
+	}
+	if timing.FPS <= 0 {
+		return BandingSettings{}, fmt.Errorf("Banding needs a frame timing above 0 fps.")
+	}
+
+	frame_lines := SENSOR_FRAME_LINES + timing.DummyLines
+	lines_second := timing.FPS * float64(frame_lines)
+
+	// Light flickers at twice the mains frequency.
+	settings := BandingSettings{
+		Options: options,
+		Step50:  int(lines_second/100 + 0.5),
+		Step60:  int(lines_second/120 + 0.5),
+		MinFPS:  timing.FPS,
+	}
+	if settings.Step60 < 1 || settings.Step50 > 0xFF {
+		return BandingSettings{}, fmt.Errorf("Banding step of %.1f lines at %.2f fps does not fit 1 to 255 lines.", lines_second/100, timing.FPS)
+	}
+	settings.Max50 = max(min(frame_lines/settings.Step50, 0xFF), 1)
+	settings.Max60 = max(min(frame_lines/settings.Step60, 0xFF), 1)
+	if options.Night != NIGHT_MODE_OFF {
+		settings.MinFPS /= float64(int(1) << (options.Night - NIGHT_MODE_FULL_RATE))
+	}
+
+	return settings, nil
+}
+
+// & OneLine Brief = Computes and writes the settings for a timing, the options are kept for SetFrameRate.
+func (Cam *OV7670) banding_settings(timing FrameTiming, options BandingOptions) (BandingSettings, error) {
+	settings, err := ComputeBanding(timing, options)
+	if err != nil {
+		return settings, err
+	}
+	if err := Cam.ApplySequence(settings.sequence()); err != nil {
+		return settings, err
+	}
+	Cam.banding = options
+
+	return settings, nil
+}
+
+/*
+* @brief = Sets the banding filter and night mode for the frame timing the sensor runs at.
+* ^ Both only act while AEC is on, see SetAutoControls. SetFrameRate keeps them matched to the new timing.
+* @param options = Banding filter and night mode wanted, BandingOptions{} turns both off.
+* @return = The settings written and an error if an option is not valid, MCLK is not running or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetBanding(options BandingOptions) (BandingSettings, error) {
+	timing, err := Cam.FrameTiming()
+	if err != nil {
+		return BandingSettings{}, err
+	}
+
+	return Cam.banding_settings(timing, options)
+}
+
+// & OneLine Brief = Returns the banding filter and night mode options last set.
+func (Cam *OV7670) Banding() BandingOptions {
+	return Cam.banding
+}
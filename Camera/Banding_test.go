@@ -0,0 +1,76 @@
+package Camera7670
+
+import "testing"
+
+func TestComputeBanding(t *testing.T) {
+	// 20 MHz at 15 fps runs 510 lines per frame, 7650 lines a second.
+	timing, err := ComputeFrameTiming(20_000_000, 15, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := ComputeBanding(timing, BandingOptions{Filter: BANDING_50HZ})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Step50 != 77 || settings.Max50 != 6 || settings.Step60 != 64 || settings.Max60 != 7 {
+		t.Errorf("%s, want 50Hz 77 lines x6, 60Hz 64 lines x7", settings)
+	}
+
+	minimum := map[NIGHT_MODE]float64{NIGHT_MODE_OFF: 15, NIGHT_MODE_FULL_RATE: 15, NIGHT_MODE_HALF_RATE: 7.5, NIGHT_MODE_QUARTER_RATE: 3.75, NIGHT_MODE_EIGHTH_RATE: 1.875}
+	for night, want := range minimum {
+		settings, err := ComputeBanding(timing, BandingOptions{Filter: BANDING_60HZ, Night: night})
+		if err != nil {
+			t.Fatalf("%s: %v", night, err)
+		}
+		if settings.MinFPS < want*0.999 || settings.MinFPS > want*1.001 {
+			t.Errorf("%s: MinFPS = %.3f, want %.3f", night, settings.MinFPS, want)
+		}
+	}
+
+	if _, err := ComputeBanding(timing, BandingOptions{Filter: BANDING(7)}); err == nil {
+		t.Errorf("ComputeBanding of filter 7 = nil, want an error")
+	}
+	if _, err := ComputeBanding(FrameTiming{}, BandingOptions{Filter: BANDING_50HZ}); err == nil {
+		t.Errorf("ComputeBanding of a 0 fps timing = nil, want an error")
+	}
+}
+
+func TestSetBandingRegisters(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if _, err := Cam.SetFrameRate(15); err != nil {
+		t.Fatalf("SetFrameRate: %v", err)
+	}
+	bus.registers[REG_COM11] = 0x05 // Reserved bits set by someone else.
+
+	options := BandingOptions{Filter: BANDING_50HZ, Night: NIGHT_MODE_QUARTER_RATE, ExposureBelowBand: true}
+	if _, err := Cam.SetBanding(options); err != nil {
+		t.Fatalf("SetBanding: %v", err)
+	}
+	want := COM11{NightMode: true, NightDivider: 2, Banding50Hz: true, ExposureBelowBand: true}
+	if got := ParseCOM11(bus.registers[REG_COM11]); got != want {
+		t.Errorf("COM11 = %+v, want %+v", got, want)
+	}
+	if bus.registers[REG_COM11]&0x05 != 0x05 {
+		t.Errorf("COM11 = 0x%02X, the reserved bits 2 and 0 were cleared", bus.registers[REG_COM11])
+	}
+	if !ParseCOM8(bus.registers[REG_COM8]).BandingFilter || bus.registers[REG_BD50ST] != 77 || bus.registers[REG_BD50MAX] != 6 {
+		t.Errorf("COM8 = 0x%02X, BD50ST = %d, BD50MAX = %d, want the filter on at 77 lines x6", bus.registers[REG_COM8], bus.registers[REG_BD50ST], bus.registers[REG_BD50MAX])
+	}
+
+	// Half the frame rate doubles the line time, the steps have to follow.
+	timing, err := Cam.SetFrameRate(7.5)
+	if err != nil {
+		t.Fatalf("SetFrameRate: %v", err)
+	}
+	expected, _ := ComputeBanding(timing, options)
+	if int(bus.registers[REG_BD50ST]) != expected.Step50 || int(bus.registers[REG_BD60ST]) != expected.Step60 || expected.Step50 == 77 {
+		t.Errorf("BD50ST = %d, BD60ST = %d after SetFrameRate, want %d and %d", bus.registers[REG_BD50ST], bus.registers[REG_BD60ST], expected.Step50, expected.Step60)
+	}
+	if Cam.Banding() != options {
+		t.Errorf("Banding = %+v, want %+v", Cam.Banding(), options)
+	}
+}
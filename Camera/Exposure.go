@@ -0,0 +1,152 @@
+package Camera7670
+
+import "fmt"
+
+/*
+~ File Description:
+^ Automatic and manual exposure, gain and white balance.
+^ Manual values only hold while the matching automatic control of COM8 is off, the setters switch it off for you.
+*/
+
+// & Largest values of the manual controls.
+const (
+	MAX_EXPOSURE uint16 = 0xFFFF // AECHH[5:0] AECH[7:0] COM1[1:0]
+	MAX_GAIN     uint16 = 0x03FF // VREF[7:6] GAIN[7:0]
+)
+
+// & Manual gains of the white balance channels.
+type WhiteBalance struct {
+	Blue  uint8
+	Red   uint8
+	Green uint8
+}
+
+/*
+ * @brief = Reads a list of registers.
+ * @param regs = Registers to read, in order.
+ * @return = Values in the order of regs or the *RegisterError of the first read that failed.
+ */
+func (Cam *OV7670) read_registers(regs ...uint8) ([]uint8, error) {
+	values := make([]uint8, len(regs))
+	for index, reg := range regs {
+		val, err := Cam.Read(reg)
+		if err != nil {
+			return nil, &RegisterError{Register: reg, Operation: "read", Err: err}
+		}
+		values[index] = val
+	}
+
+	return values, nil
+}
+
+/*
+* @brief = Switches the automatic exposure (AEC), gain (AGC) and white balance (AWB) on or off, leaving the rest of COM8 as is.
+* @params aec, agc, awb = true to let the sensor control the value.
+* @return = *RegisterError of the write.
+! Handle Error.
+*/
+func (Cam *OV7670) SetAutoControls(aec, agc, awb bool) error {
+	return Cam.ApplySequence(RegisterSequence{
+		{REG_COM8, COM8{AEC: aec, AGC: agc, AWB: awb}.Value(), COM8{AEC: true, AGC: true, AWB: true}.Value(), 0},
+	})
+}
+
+/*
+ * @brief = Reads back which automatic controls are on.
+ * @return = COM8 of the sensor or the *RegisterError of the read.
+ */
+func (Cam *OV7670) AutoControls() (COM8, error) {
+	values, err := Cam.read_registers(REG_COM8)
+	if err != nil {
+		return COM8{}, err
+	}
+
+	return ParseCOM8(values[0]), nil
+}
+
+/*
+* @brief = Turns AEC off and sets the exposure time.
+* @param lines = Exposure in row times, 0 to MAX_EXPOSURE.
+* @return = *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetExposure(lines uint16) error {
+	return Cam.ApplySequence(RegisterSequence{
+		{REG_COM8, COM8{}.Value(), COM8{AEC: true}.Value(), 0},
+		{REG_AECHH, uint8(lines >> 10), 0x3F, 0},
+		{REG_AECH, uint8(lines >> 2), MASK_ALL, 0},
+		{REG_COM1, uint8(lines & 0x03), 0x03, 0},
+	})
+}
+
+/*
+ * @brief = Reads back the exposure time, the value picked by AEC while it is on.
+ * @return = Exposure in row times or the *RegisterError of the read.
+ */
+func (Cam *OV7670) Exposure() (uint16, error) {
+	values, err := Cam.read_registers(REG_AECHH, REG_AECH, REG_COM1)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint16(values[0]&0x3F)<<10 | uint16(values[1])<<2 | uint16(values[2]&0x03), nil
+}
+
+/*
+* @brief = Turns AGC off and sets the analog gain.
+* @param gain = Gain code, 0 to MAX_GAIN.
+* @return = returns an error if the gain is out of range or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetGain(gain uint16) error {
+	if gain > MAX_GAIN {
+		return fmt.Errorf("Gain 0x%03X is larger than 0x%03X.", gain, MAX_GAIN)
+	}
+
+	return Cam.ApplySequence(RegisterSequence{
+		{REG_COM8, COM8{}.Value(), COM8{AGC: true}.Value(), 0},
+		{REG_GAIN, uint8(gain), MASK_ALL, 0},
+		{REG_VREF, uint8(gain>>8) << 6, 0xC0, 0},
+	})
+}
+
+/*
+ * @brief = Reads back the analog gain, the value picked by AGC while it is on.
+ * @return = Gain code or the *RegisterError of the read.
+ */
+func (Cam *OV7670) Gain() (uint16, error) {
+	values, err := Cam.read_registers(REG_VREF, REG_GAIN)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint16(values[0]>>6)<<8 | uint16(values[1]), nil
+}
+
+/*
+* @brief = Turns AWB off and sets the gain of every colour channel.
+* @param wb = Channel gains, 0x80 is about unity for blue and red.
+* @return = *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetWhiteBalance(wb WhiteBalance) error {
+	return Cam.ApplySequence(RegisterSequence{
+		{REG_COM8, COM8{}.Value(), COM8{AWB: true}.Value(), 0},
+		{REG_BLUE, wb.Blue, MASK_ALL, 0},
+		{REG_RED, wb.Red, MASK_ALL, 0},
+		{REG_GGAIN, wb.Green, MASK_ALL, 0},
+	})
+}
+
+/*
+ * @brief = Reads back the channel gains, the values picked by AWB while it is on.
+ * @return = Channel gains or the *RegisterError of the read.
+ */
+func (Cam *OV7670) WhiteBalance() (WhiteBalance, error) {
+	values, err := Cam.read_registers(REG_BLUE, REG_RED, REG_GGAIN)
+	if err != nil {
+		return WhiteBalance{}, err
+	}
+
+	return WhiteBalance{Blue: values[0], Red: values[1], Green: values[2]}, nil
+}
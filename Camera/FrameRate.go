@@ -0,0 +1,188 @@
+package Camera7670
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+~ File Description:
+^ Frame rate control through the internal clock (MCLK * DBLV PLL / CLKRC) and dummy pixels and lines.
+^ A frame is SENSOR_FRAME_LINES lines of SENSOR_LINE_PIXELS pixels, 2 internal clocks per pixel, at every resolution.
+^ The down sampled resolutions divide PCLK (COM14) but clock out fewer bytes, so the frame time does not change.
+*/
+
+// & Frame timing of the sensor.
+const (
+	SENSOR_FRAME_LINES    = 510
+	MAX_INTERNAL_CLOCK    = 24_000_000 // 30 fps at VGA, the fastest the sensor is specified for.
+	MAX_DUMMY_PIXELS      = 0x0FFF     // EXHCH[7:4] EXHCL[7:0]
+	MAX_DUMMY_LINES       = 0xFFFF     // DM_LNH[7:0] DM_LNL[7:0]
+	INTERNAL_CLOCKS_PIXEL = 2
+)
+
+// & Multipliers of the DBLV PLL, indexed by DBLV[7:6].
+var PLL_FACTORS = [4]int{1, 4, 6, 8}
+
+/*
+ * @brief = Timing picked by SetFrameRate.
+ * @element Clock = CLKRC written, the internal clock prescaler.
+ * @element PLL = DBLV PLL multiplier, 1 when bypassed.
+ * @elements DummyPixels, DummyLines = Blanking added to every line and frame.
+ * @element FPS = Frame rate the settings give.
+ * @element PCLK = Frequency of PCLK at the active resolution, in Hz.
+ * @element PCLKPeriod = Time the capture loop has for every byte.
+ */
+type FrameTiming struct {
+	Clock       CLKRC
+	PLL         int
+	DummyPixels int
+	DummyLines  int
+	FPS         float64
+	PCLK        uint64
+	PCLKPeriod  time.Duration
+}
+
+func (t FrameTiming) String() string {
+	return fmt.Sprintf("%.2f fps, PLL x%d, CLKRC /%d, %d dummy pixels, %d dummy lines, PCLK %d Hz (%s)", t.FPS, t.PLL, t.Clock.Divider(), t.DummyPixels, t.DummyLines, t.PCLK, t.PCLKPeriod)
+}
+
+// & OneLine Brief = Writes of CLKRC, DBLV, EXHCH/EXHCL and DM_LNL/DM_LNH for the timing.
+func (t FrameTiming) sequence() RegisterSequence {
+	var pll uint8
+	for index, factor := range PLL_FACTORS {
+		if factor == t.PLL {
+			pll = uint8(index)
+		}
+	}
+
+	// EXHCH[3:0] hold the HSYNC edge delays, DBLV[5:0] the regulator settings.
+	return RegisterSequence{
+		{REG_DBLV, pll << 6, 0xC0, 0},
+		{REG_CLKRC, t.Clock.Value(), MASK_ALL, 0},
+		{REG_EXHCH, uint8(t.DummyPixels>>8) << 4, 0xF0, 0},
+		{REG_EXHCL, uint8(t.DummyPixels), MASK_ALL, 0},
+		{REG_DM_LNL, uint8(t.DummyLines), MASK_ALL, 0},
+		{REG_DM_LNH, uint8(t.DummyLines >> 8), MASK_ALL, 0},
+	}
+}
+
+/*
+ * @brief = Computes the timing closest to a frame rate.
+ * ^ The slowest internal clock that reaches fps is used, so PCLK stays as slow as possible for the capture loop.
+ * ^ Dummy lines then stretch the frame down to fps and dummy pixels take up the rest.
+ * @param mclk = MCLK frequency in Hz.
+ * @param fps = Frames per second wanted.
+ * @param pclk_divider = Division of PCLK by the scaler of the active resolution (COM14).
+ * @return = The timing or an error if fps can not be reached with this MCLK.
+ */
+func ComputeFrameTiming(mclk uint64, fps float64, pclk_divider int) (FrameTiming, error) {
+	if fps <= 0 {
+		return FrameTiming{}, fmt.Errorf("Frame rate %.2f fps is not above 0.", fps)
+	}
+	if mclk == 0 {
+		return FrameTiming{}, fmt.Errorf("Frame rate needs a running MCLK, call Initialize first.")
+	}
+
+	var best FrameTiming
+	var internal uint64
+	for _, pll := range PLL_FACTORS {
+		for divider := 1; divider <= 64; divider++ {
+			clock := mclk * uint64(pll) / uint64(divider)
+			fastest := float64(clock) / (INTERNAL_CLOCKS_PIXEL * SENSOR_LINE_PIXELS * SENSOR_FRAME_LINES)
+			if clock > MAX_INTERNAL_CLOCK || fastest < fps {
+				continue
+			}
+			if internal == 0 || clock < internal {
+				internal = clock
+				best = FrameTiming{Clock: CLKRC{Prescaler: uint8(divider - 1)}, PLL: pll}
+			}
+		}
+	}
+	if internal == 0 {
+		return FrameTiming{}, fmt.Errorf("Frame rate %.2f fps is too fast for MCLK = %d Hz.", fps, mclk)
+	}
+
+	// Pixels of the whole frame at the wanted rate, split into lines of at least SENSOR_LINE_PIXELS.
+	pixels := float64(internal) / (INTERNAL_CLOCKS_PIXEL * fps)
+	lines := min(int(pixels/SENSOR_LINE_PIXELS), SENSOR_FRAME_LINES+MAX_DUMMY_LINES)
+	line_pixels := min(int(pixels/float64(lines)+0.5), SENSOR_LINE_PIXELS+MAX_DUMMY_PIXELS)
+
+	best.DummyLines = lines - SENSOR_FRAME_LINES
+	best.DummyPixels = line_pixels - SENSOR_LINE_PIXELS
+	best.FPS = float64(internal) / float64(INTERNAL_CLOCKS_PIXEL*line_pixels*lines)
+	if best.FPS > fps*1.01 {
+		return FrameTiming{}, fmt.Errorf("Frame rate %.2f fps is too slow for MCLK = %d Hz, %.2f fps at the least.", fps, mclk, best.FPS)
+	}
+	best.PCLK = internal / uint64(max(pclk_divider, 1))
+	best.PCLKPeriod = time.Duration(uint64(time.Second) / best.PCLK)
+
+	return best, nil
+}
+
+/*
+* @brief = Sets the frame rate for the MCLK given to Initialize and the active resolution.
+* ^ Configure QQVGA and SetPCLKSpeed write CLKRC as well, call SetFrameRate after them. The banding filter is computed again.
+* @param fps = Frames per second wanted.
+* @return = The timing written, check PCLKPeriod against the capture loop, and an error if fps can not be reached or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetFrameRate(fps float64) (FrameTiming, error) {
+	values, err := Cam.read_registers(REG_COM14)
+	if err != nil {
+		return FrameTiming{}, err
+	}
+
+	timing, err := ComputeFrameTiming(Cam.mclk, fps, scaler_pclk_divider(values[0]))
+	if err != nil {
+		return timing, err
+	}
+	if err := Cam.ApplySequence(timing.sequence()); err != nil {
+		return timing, err
+	}
+
+	// The banding steps are counted in lines, they change with the line time.
+	if Cam.banding != (BandingOptions{}) {
+		if _, err := Cam.banding_settings(timing, Cam.banding); err != nil {
+			return timing, err
+		}
+	}
+
+	return timing, nil
+}
+
+// & OneLine Brief = Division of PCLK by the scaler, from the value of COM14.
+func scaler_pclk_divider(com14_value uint8) int {
+	com14 := ParseCOM14(com14_value)
+	if !com14.ScalingPCLK {
+		return 1
+	}
+	return 1 << min(com14.PCLKDivider, 4)
+}
+
+/*
+ * @brief = Reads back the frame timing the sensor runs at, from DBLV, CLKRC, the dummy pixels and lines and COM14.
+ * @return = The timing or an error if MCLK is not running or the *RegisterError of the read.
+ */
+func (Cam *OV7670) FrameTiming() (FrameTiming, error) {
+	if Cam.mclk == 0 {
+		return FrameTiming{}, fmt.Errorf("Frame timing needs a running MCLK, call Initialize first.")
+	}
+	values, err := Cam.read_registers(REG_DBLV, REG_CLKRC, REG_EXHCH, REG_EXHCL, REG_DM_LNL, REG_DM_LNH, REG_COM14)
+	if err != nil {
+		return FrameTiming{}, err
+	}
+
+	timing := FrameTiming{
+		Clock:       ParseCLKRC(values[1]),
+		PLL:         PLL_FACTORS[values[0]>>6],
+		DummyPixels: int(values[2]>>4)<<8 | int(values[3]),
+		DummyLines:  int(values[5])<<8 | int(values[4]),
+	}
+	internal := Cam.mclk * uint64(timing.PLL) / uint64(timing.Clock.Divider())
+	timing.FPS = float64(internal) / float64(INTERNAL_CLOCKS_PIXEL*(SENSOR_LINE_PIXELS+timing.DummyPixels)*(SENSOR_FRAME_LINES+timing.DummyLines))
+	timing.PCLK = internal / uint64(scaler_pclk_divider(values[6]))
+	timing.PCLKPeriod = time.Duration(uint64(time.Second) / max(timing.PCLK, 1))
+
+	return timing, nil
+}
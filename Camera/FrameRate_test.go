@@ -0,0 +1,70 @@
+package Camera7670
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeFrameTiming(t *testing.T) {
+	cases := []struct {
+		mclk                    uint64
+		fps                     float64
+		pll, divider            int
+		dummyPixels, dummyLines int
+		err                     string
+	}{
+		{20_000_000, 15, 6, 10, 0, 0, ""},
+		{24_000_000, 25, 6, 7, 1, 14, ""},
+		// Even /64 of the slowest PLL is too fast, dummy lines stretch the frame.
+		{20_000_000, 0.1, 1, 64, 0, 1482, ""},
+		{20_000_000, 60, 0, 0, 0, 0, "too fast"},
+		{20_000_000, 0, 0, 0, 0, 0, "not above 0"},
+		{0, 15, 0, 0, 0, 0, "running MCLK"},
+	}
+
+	for _, c := range cases {
+		timing, err := ComputeFrameTiming(c.mclk, c.fps, 1)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%d Hz %.2f fps: error = %v, want %q", c.mclk, c.fps, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d Hz %.2f fps: %v", c.mclk, c.fps, err)
+			continue
+		}
+		if timing.PLL != c.pll || timing.Clock.Divider() != c.divider || timing.DummyPixels != c.dummyPixels || timing.DummyLines != c.dummyLines {
+			t.Errorf("%d Hz %.2f fps: %s, want PLL x%d, CLKRC /%d, %d dummy pixels, %d dummy lines", c.mclk, c.fps, timing, c.pll, c.divider, c.dummyPixels, c.dummyLines)
+		}
+		if timing.FPS < c.fps*0.99 || timing.FPS > c.fps*1.01 {
+			t.Errorf("%d Hz %.2f fps: timing gives %.3f fps", c.mclk, c.fps, timing.FPS)
+		}
+	}
+}
+
+func TestFrameTimingReadBack(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(24_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := Cam.Configure(RGB, QVGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	written, err := Cam.SetFrameRate(25)
+	if err != nil {
+		t.Fatalf("SetFrameRate: %v", err)
+	}
+	if written.PCLK != 24_000_000*6/7/2 {
+		t.Errorf("PCLK at QVGA = %d Hz, want the internal clock halved by COM14", written.PCLK)
+	}
+	read, err := Cam.FrameTiming()
+	if err != nil {
+		t.Fatalf("FrameTiming: %v", err)
+	}
+	if read != written {
+		t.Errorf("FrameTiming = %s, SetFrameRate wrote %s", read, written)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build tinygo && !rp2040
+
+package Camera7670
+
+import "machine"
+
+/*
+~ File Description:
+^ Builds the GPIO input register from the data pins on chips without a known register, so PortPlan still applies.
+*/
+
+// & OneLine Brief = Returns a register with bit n being GPIOn, only the pins of the plan are read.
+func gpio_input(plan *PortPlan) uint32 {
+	var register uint32
+	for _, gpio := range plan.Pins {
+		if machine.Pin(gpio).Get() {
+			register |= 1 << gpio
+		}
+	}
+	return register
+}
@@ -0,0 +1,15 @@
+//go:build tinygo && rp2040
+
+package Camera7670
+
+import "device/rp"
+
+/*
+~ File Description:
+^ Reads all GPIO inputs of the RP2040 with one load of the SIO GPIO_IN register.
+*/
+
+// & OneLine Brief = Returns the GPIO_IN register, bit n being GPIOn.
+func gpio_input(plan *PortPlan) uint32 {
+	return rp.SIO.GPIO_IN.Get()
+}
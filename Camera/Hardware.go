@@ -0,0 +1,54 @@
+package Camera7670
+
+/*
+~ File Description:
+^ Hardware abstraction used by the OV7670 Driver.
+^ The driver only talks to these small interfaces so it can run off-target (plain go test on a host machine).
+^ TinyGO adapters wrapping the machine types live in TinyGoAdapters.go.
+*/
+
+/*
+ * @brief = Bus used to reach the SCCB (I2C compatible) register interface of OV7670.
+ * @method WriteRegister = Writes data starting at register reg of the device at address.
+ * @method ReadRegister = Reads len(data) bytes starting at register reg of the device at address.
+ * ^ *machine.I2C satisfies this interface directly.
+ */
+type RegisterBus interface {
+	WriteRegister(address uint8, reg uint8, data []byte) error
+	ReadRegister(address uint8, reg uint8, data []byte) error
+}
+
+/*
+ * @brief = A single digital input such as VSync, HSync (HREF) or PCLK.
+ * ^ machine.Pin satisfies this interface directly.
+ */
+type InputPin interface {
+	Get() bool
+}
+
+/*
+ * @brief = A single digital output such as the PWDN or RESET input of OV7670.
+ * ^ machine.Pin satisfies this interface directly, configure it as an output first.
+ */
+type OutputPin interface {
+	Set(high bool)
+}
+
+/*
+ * @brief = Clock source which drives the XCLK (MCLK) input of OV7670.
+ * @method Start = Starts generating a clock of the given frequency in Hz.
+ * @method Stop = Stops the clock and holds the output low.
+ */
+type ClockSource interface {
+	Start(frequency uint64) error
+	Stop() error
+}
+
+/*
+ * @brief = The 8-bit parallel data port D[7:0] of OV7670.
+ * @method Read = Returns a byte made from the 8 Pin States, bit i being D[i].
+ * ^ *PArray satisfies this interface, RegisterPort does on a host with a fake input register.
+ */
+type DataPort interface {
+	Read() uint8
+}
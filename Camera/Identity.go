@@ -0,0 +1,99 @@
+package Camera7670
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Identification of the image sensor on the bus through PID/VER (0x0A/0x0B) and MIDH/MIDL (0x1C/0x1D).
+*/
+
+// & Identification values of an OV7670.
+const (
+	OV7670_PID        uint8  = 0x76
+	OV7670_VER        uint8  = 0x73
+	OMNIVISION_MFR_ID uint16 = 0x7FA2
+)
+
+// & Reasons a Probe can fail, wrapped by ProbeError.
+var (
+	ErrNoDevice       = errors.New("no camera answered on the bus")
+	ErrNotOmniVision  = errors.New("device is not an OmniVision sensor")
+	ErrUnsupportedPID = errors.New("OmniVision sensor is not an OV7670")
+)
+
+/*
+ * @brief = Identity read from the sensor.
+ * @element PID = Product ID MSB.
+ * @element VER = Product ID LSB.
+ * @element Manufacturer = MIDH << 8 | MIDL.
+ */
+type SensorIdentity struct {
+	PID          uint8
+	VER          uint8
+	Manufacturer uint16
+}
+
+func (id SensorIdentity) String() string {
+	return fmt.Sprintf("PID 0x%02X VER 0x%02X MID 0x%04X", id.PID, id.VER, id.Manufacturer)
+}
+
+// & OneLine Brief = Checks if the identity belongs to an OV7670.
+func (id SensorIdentity) IsOV7670() bool {
+	return id.Manufacturer == OMNIVISION_MFR_ID && id.PID == OV7670_PID && id.VER == OV7670_VER
+}
+
+/*
+ * @brief = Error returned by Probe.
+ * @element Address = Address that was probed.
+ * @element Identity = What was read, zero if the bus failed.
+ * @element Err = ErrNoDevice, ErrNotOmniVision or ErrUnsupportedPID, with the bus error if there was one.
+ */
+type ProbeError struct {
+	Address  uint8
+	Identity SensorIdentity
+	Err      error
+}
+
+func (e *ProbeError) Error() string {
+	if errors.Is(e.Err, ErrNoDevice) {
+		return fmt.Sprintf("OV7670 probe at 0x%02X: %v", e.Address, e.Err)
+	}
+	return fmt.Sprintf("OV7670 probe at 0x%02X: %v (%s)", e.Address, e.Err, e.Identity.String())
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+/*
+* @brief = Reads the identification registers and checks that an OV7670 is on the bus.
+* ^ MCLK must be running, the sensor does not answer on SCCB without it.
+* @return = Identity of the sensor, and a *ProbeError if it is missing or is a different part.
+ */
+func (Cam *OV7670) Probe() (SensorIdentity, error) {
+	var id SensorIdentity
+	var raw [4]uint8
+	for index, reg := range []uint8{REG_PID, REG_VER, REG_MIDH, REG_MIDL} {
+		val, err := Cam.Read(reg)
+		if err != nil {
+			return id, &ProbeError{Address: Cam.Address, Err: fmt.Errorf("%w: %w", ErrNoDevice, &RegisterError{Register: reg, Operation: "read", Err: err})}
+		}
+		raw[index] = val
+	}
+	id = SensorIdentity{PID: raw[0], VER: raw[1], Manufacturer: uint16(raw[2])<<8 | uint16(raw[3])}
+
+	switch {
+	case raw == [4]uint8{} || raw == [4]uint8{0xFF, 0xFF, 0xFF, 0xFF}:
+		// A floating or shorted bus reads back the same value from every register.
+		return id, &ProbeError{Address: Cam.Address, Identity: id, Err: ErrNoDevice}
+	case id.Manufacturer != OMNIVISION_MFR_ID:
+		return id, &ProbeError{Address: Cam.Address, Identity: id, Err: ErrNotOmniVision}
+	case !id.IsOV7670():
+		return id, &ProbeError{Address: Cam.Address, Identity: id, Err: ErrUnsupportedPID}
+	}
+
+	return id, nil
+}
@@ -0,0 +1,208 @@
+package Camera7670
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+~ File Description:
+^ Image quality controls: brightness, contrast, saturation and hue (colour matrix), edge enhancement and gamma.
+^ Saturation and hue are kept per camera and applied again by Configure. Configure leaves gamma (GAM1 to GAM15, SLOP and
+^ COM13[7]) alone, it does write COM16 and REG76 for BAYER, which resets the edge enhancement upper limit.
+*/
+
+// & Valid ranges of the tuning controls.
+const (
+	MIN_BRIGHTNESS = -127 // BRIGHT is sign and magnitude.
+	MAX_BRIGHTNESS = 127
+	MAX_CONTRAST   = 200 // Percent, 100 is the power on CONTRAS of 0x40.
+	MAX_SATURATION = 200 // Percent of the colour matrix of the format.
+	MAX_HUE        = 180 // Degrees in both directions.
+	MAX_EDGE       = 0x1F
+	MIN_GAMMA      = 0.25
+	MAX_GAMMA      = 4.0
+)
+
+/*
+ * @brief = Colour matrix MTX1 to MTX6, turns YUV into the output format.
+ * ^ Every coefficient is a magnitude up to 255 with its sign in MTXS, MTX1/2/3 are the U weights and MTX4/5/6 the V weights.
+ */
+type ColorMatrix [6]int
+
+// & Colour matrix of every format at 100% saturation and no hue rotation, GREYSCALED/YUV use the power on matrix.
+var colorMatrices = map[IMAGE]ColorMatrix{
+	GREYSCALED:  {0x40, -0x34, -0x0C, -0x17, -0x29, 0x40},
+	YUV:         {0x40, -0x34, -0x0C, -0x17, -0x29, 0x40},
+	RGB:         {0xB3, -0xB3, 0x00, -0x3D, -0xA7, 0xE4},
+	RGB555:      {0xB3, -0xB3, 0x00, -0x3D, -0xA7, 0xE4},
+	RGB444_XRGB: {0xB3, -0xB3, 0x00, -0x3D, -0xA7, 0xE4},
+	RGB444_RGBX: {0xB3, -0xB3, 0x00, -0x3D, -0xA7, 0xE4},
+}
+
+/*
+ * @brief = Scales the matrix for a saturation and rotates the U/V plane for a hue.
+ * @param saturation = Percent, 0 to MAX_SATURATION.
+ * @param hue = Degrees, -MAX_HUE to MAX_HUE.
+ * @return = The adjusted matrix.
+ */
+func (m ColorMatrix) Adjust(saturation, hue int) ColorMatrix {
+	sin, cos := math.Sincos(float64(hue) * math.Pi / 180)
+	var adjusted ColorMatrix
+	for i := 0; i < 3; i++ {
+		u := float64(m[i]*saturation) / 100
+		v := float64(m[i+3]*saturation) / 100
+		adjusted[i] = int(math.Round(u*cos + v*sin))
+		adjusted[i+3] = int(math.Round(v*cos - u*sin))
+	}
+
+	return adjusted
+}
+
+// & OneLine Brief = Writes of MTX1 to MTX6 and the sign bits of MTXS, coefficients are clamped to 255.
+func (m ColorMatrix) sequence() RegisterSequence {
+	var signs uint8
+	seq := make(RegisterSequence, 0, len(m)+1)
+	for i, coefficient := range m {
+		if coefficient < 0 {
+			signs |= 1 << i
+			coefficient = -coefficient
+		}
+		seq = append(seq, RegisterWrite{REG_MTX1 + uint8(i), uint8(min(coefficient, 0xFF)), MASK_ALL, 0})
+	}
+
+	// Bit 7 of MTXS is the auto contrast centre, it is not part of the matrix.
+	return append(seq, RegisterWrite{REG_MTXS, signs, 0x3F, 0})
+}
+
+/*
+* @brief = Sets the brightness offset.
+* @param level = MIN_BRIGHTNESS to MAX_BRIGHTNESS, 0 is the power on value.
+* @return = returns an error if the level is out of range or the *RegisterError of the write.
+! Handle Error.
+*/
+func (Cam *OV7670) SetBrightness(level int) error {
+	if level < MIN_BRIGHTNESS || level > MAX_BRIGHTNESS {
+		return fmt.Errorf("Brightness %d is outside %d to %d.", level, MIN_BRIGHTNESS, MAX_BRIGHTNESS)
+	}
+
+	value := uint8(level)
+	if level < 0 {
+		value = 0x80 | uint8(-level)
+	}
+	return Cam.ApplySequence(RegisterSequence{{REG_BRIGHT, value, MASK_ALL, 0}})
+}
+
+/*
+* @brief = Sets the contrast gain.
+* @param percent = 0 to MAX_CONTRAST, 100 is the power on value.
+* @return = returns an error if the percent is out of range or the *RegisterError of the write.
+! Handle Error.
+*/
+func (Cam *OV7670) SetContrast(percent int) error {
+	if percent < 0 || percent > MAX_CONTRAST {
+		return fmt.Errorf("Contrast %d%% is outside 0 to %d%%.", percent, MAX_CONTRAST)
+	}
+	return Cam.ApplySequence(RegisterSequence{{REG_CONTRAS, uint8(percent * 0x40 / 100), MASK_ALL, 0}})
+}
+
+/*
+* @brief = Sets the colour saturation and hue through the colour matrix of the active format.
+* @param saturation = Percent, 0 (grey) to MAX_SATURATION, 100 is the matrix of the format.
+* @param hue = Rotation in degrees, -MAX_HUE to MAX_HUE.
+* @return = returns an error if a value is out of range or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetSaturationHue(saturation, hue int) error {
+	if saturation < 0 || saturation > MAX_SATURATION || hue < -MAX_HUE || hue > MAX_HUE {
+		return fmt.Errorf("Saturation %d%% or hue %d is outside 0 to %d%% and -%d to %d.", saturation, hue, MAX_SATURATION, MAX_HUE, MAX_HUE)
+	}
+
+	if matrix, ok := colorMatrices[Cam.imageType]; ok {
+		if err := Cam.ApplySequence(matrix.Adjust(saturation, hue).sequence()); err != nil {
+			return err
+		}
+	}
+	Cam.saturation, Cam.hue = saturation, hue
+
+	return nil
+}
+
+// & OneLine Brief = Returns the saturation in percent and the hue in degrees applied to the colour matrix.
+func (Cam *OV7670) SaturationHue() (int, int) {
+	return Cam.saturation, Cam.hue
+}
+
+/*
+* @brief = Sets the edge enhancement (sharpness).
+* @param factor = Strength, 0 (off) to MAX_EDGE.
+* @params lower, upper = Limits of the enhancement, 0 to MAX_EDGE with lower <= upper.
+* @return = returns an error if a value is out of range or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetEdgeEnhancement(factor, lower, upper uint8) error {
+	if factor > MAX_EDGE || lower > MAX_EDGE || upper > MAX_EDGE || lower > upper {
+		return fmt.Errorf("Edge enhancement %d (%d to %d) is outside 0 to %d.", factor, lower, upper, MAX_EDGE)
+	}
+
+	// REG76 keeps its black and white pixel correction bits.
+	return Cam.ApplySequence(RegisterSequence{
+		{REG_EDGE, factor, 0x1F, 0},
+		{REG_REG75, lower, 0x1F, 0},
+		{REG_REG76, upper, 0x1F, 0},
+	})
+}
+
+// & Input end points of the 15 gamma segments, the last segment runs to 0xFF with slope SLOP.
+var GAMMA_INPUTS = [15]int{0x04, 0x08, 0x10, 0x20, 0x28, 0x30, 0x38, 0x40, 0x48, 0x50, 0x60, 0x70, 0x90, 0xB0, 0xD0}
+
+// & Gamma curve outputs at GAMMA_INPUTS, GAM1 to GAM15.
+type GammaCurve [15]uint8
+
+/*
+ * @brief = Builds the curve out = 255 * (in / 255) ^ (1 / gamma).
+ * @param gamma = MIN_GAMMA to MAX_GAMMA, 1 is a straight line and larger values brighten the shadows.
+ * @return = The gamma curve.
+ */
+func GammaCurveFor(gamma float64) GammaCurve {
+	var curve GammaCurve
+	for i, input := range GAMMA_INPUTS {
+		curve[i] = uint8(math.Round(255 * math.Pow(float64(input)/255, 1/gamma)))
+	}
+	return curve
+}
+
+/*
+* @brief = Programs a gamma curve and turns gamma on in COM13, SLOP is computed from GAM15.
+* @param curve = Outputs at GAMMA_INPUTS, must not decrease.
+* @return = returns an error if the curve decreases or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetGammaCurve(curve GammaCurve) error {
+	for i := 1; i < len(curve); i++ {
+		if curve[i] < curve[i-1] {
+			return fmt.Errorf("Gamma curve decreases at GAM%d (0x%02X < 0x%02X).", i+1, curve[i], curve[i-1])
+		}
+	}
+
+	// Datasheet: SLOP = (0x100 - GAM15) * 4 / 3.
+	seq := RegisterSequence{{REG_SLOP, uint8(min((0x100-int(curve[14]))*4/3, 0xFF)), MASK_ALL, 0}}
+	for i, output := range curve {
+		seq = append(seq, RegisterWrite{REG_GAM1 + uint8(i), output, MASK_ALL, 0})
+	}
+	seq = append(seq, RegisterWrite{REG_COM13, COM13{GammaEnable: true}.Value(), COM13{GammaEnable: true}.Value(), 0})
+	return Cam.ApplySequence(seq)
+}
+
+/*
+* @brief = Programs the gamma curve of a gamma value.
+* @param gamma = MIN_GAMMA to MAX_GAMMA.
+* @return = returns an error if gamma is out of range or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetGamma(gamma float64) error {
+	if gamma < MIN_GAMMA || gamma > MAX_GAMMA {
+		return fmt.Errorf("Gamma %.2f is outside %.2f to %.2f.", gamma, MIN_GAMMA, MAX_GAMMA)
+	}
+	return Cam.SetGammaCurve(GammaCurveFor(gamma))
+}
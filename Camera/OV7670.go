@@ -2,33 +2,63 @@ package Camera7670
 
 import (
 	"fmt"
-	"machine"
 	"time"
 )
 
-// & ImageType and ImageResolution for usage across multiple files.
-var CurrentImageType IMAGE
-var CurrentResolution RESOLUTION
-
 // & Default address of OV7670 I2C Interface.
 const DEFAULT_OV7670_ADDRESS uint8 = 0x21
 
 /*
  * @brief = First Ever TinyGO OV7670 Driver.
  * @element Address = Address of the OV7670 Object.
- * @element I2C_Bus = Pointer to the I2C Driver of TinyGO.
- * @elements VSync, HSync, PCLK = IO Pins to track the image data given by OV7670.
- * @element MCLK = PWM Enabled Pin which is used to generate a clock for the image sensor.
- * @element DataPins = pointer of PArray made to read data from the 8 Data pins with ease.
+ * @element I2C_Bus = Register bus (I2C Driver of TinyGO on target).
+ * @elements VSync, HSync, PCLK = Input Pins to track the image data given by OV7670.
+ * @element MCLK = Clock source which is used to generate a clock for the image sensor.
+ * @element DataPins = Data port made to read data from the 8 Data pins with ease.
+ * @elements PWDN, RESET = Optional Output Pins to the power down and reset inputs, nil when not wired.
+ * @element VerifyWrites = Read every register back while applying register sequences and report mismatches.
+ * @element Timeout = Longest time a capture may take before it gives up with a *TimeoutError, 0 waits forever.
+ * @element Backend = Moves the lines of a frame into memory, nil polls the pins with the CPU (BitBang).
+ * @elements imageType, yuvOrder, resolution, window, clock = Active configuration of this camera, read them with the getters.
+ * @element frame = Whole frame the window is cut out of.
+ * @elements saturation, hue = Colour matrix adjustment applied by every Configure.
+ * @element orientation = Mirror and flip of the readout, kept by Configure.
+ * @element testPattern = Test pattern output instead of the image, kept by Configure.
+ * @element banding = Banding filter and night mode options, computed again by SetFrameRate.
+ * @element mclk = Frequency MCLK was started at, 0 before Initialize.
+ * @element shadow = Last value written to every register since the last reset, in first write order, Wake writes it back.
+ * @element sleep = How the camera was put to sleep, SLEEP_AWAKE while running.
+ * @element mclkStopped = MCLK was stopped by Sleep, Wake starts it again at mclk.
  */
 type OV7670 struct {
-	Address  uint8
-	I2C_Bus  *machine.I2C
-	VSync    machine.Pin
-	HSync    machine.Pin
-	MCLK     machine.Pin
-	PCLK     machine.Pin
-	DataPins *PArray
+	Address      uint8
+	I2C_Bus      RegisterBus
+	VSync        InputPin
+	HSync        InputPin
+	MCLK         ClockSource
+	PCLK         InputPin
+	DataPins     DataPort
+	PWDN         OutputPin
+	RESET        OutputPin
+	VerifyWrites bool
+	Timeout      time.Duration
+	Backend      CaptureBackend
+
+	imageType   IMAGE
+	yuvOrder    YUV_ORDER
+	resolution  RESOLUTION
+	window      Window
+	frame       FrameFormat
+	clock       CLKRC
+	saturation  int
+	hue         int
+	orientation Orientation
+	testPattern TEST_PATTERN
+	banding     BandingOptions
+	mclk        uint64
+	shadow      RegisterSequence
+	sleep       SLEEP_STATE
+	mclkStopped bool
 }
 
 /*
@@ -36,75 +66,50 @@ type OV7670 struct {
  * @params = Too many params, not explaining they just go to their corresponding args in the struct construction.
  * @return = pointer of an OV7670 Driver Object.
  */
-func CreateOV7670(bus_i2c *machine.I2C, vsync, hsync, mclk, pclk machine.Pin, data_pins *PArray) *OV7670 {
-	return &OV7670{Address: DEFAULT_OV7670_ADDRESS, I2C_Bus: bus_i2c, VSync: vsync, HSync: hsync, MCLK: mclk, PCLK: pclk, DataPins: data_pins}
+func CreateOV7670(bus_i2c RegisterBus, vsync, hsync InputPin, mclk ClockSource, pclk InputPin, data_pins DataPort) *OV7670 {
+	Cam := &OV7670{Address: DEFAULT_OV7670_ADDRESS, I2C_Bus: bus_i2c, VSync: vsync, HSync: hsync, MCLK: mclk, PCLK: pclk, DataPins: data_pins, Timeout: DEFAULT_CAPTURE_TIMEOUT}
+	Cam.resetState()
+	return Cam
+}
+
+// & OneLine Brief = Puts the active configuration back to the power on state of the sensor (VGA YUV, CLKRC 0x80).
+func (Cam *OV7670) resetState() {
+	Cam.imageType = GREYSCALED
+	Cam.yuvOrder = YUYV
+	Cam.resolution = VGA
+	Cam.window = VGA_WINDOW
+	Cam.frame = frameFormats[VGA]
+	Cam.clock = ParseCLKRC(0x80)
+	Cam.saturation = 100
+	Cam.hue = 0
+	Cam.orientation = Orientation{}
+	Cam.testPattern = TEST_PATTERN_OFF
+	Cam.banding = BandingOptions{}
+	Cam.shadow = nil
 }
 
 /*
-* @brief = Generates the Clock Signal and resets the OV7670 Camera.
-* @param = Frequency on the PWM pin. Directly changes the speed of camera.
-* @return = returns an error if found any.
+* @brief = Generates the Clock Signal, probes and initializes the OV7670 Camera.
+* @param = Frequency of the clock source. Directly changes the speed of camera.
+* @return = returns an error if found any, a *ProbeError if there is no OV7670 on the bus.
 ! Handle Error.
 */
 func (Cam *OV7670) Initialize(_freq uint64) error {
 	// Producing MCLK.
-	slice, _ := machine.PWMPeripheral(Cam.MCLK)
-	pwm := machine.PWM0
-	switch slice {
-	case 0:
-		pwm = machine.PWM0
-		break
-	case 1:
-		pwm = machine.PWM1
-		break
-	case 2:
-		pwm = machine.PWM2
-		break
-	case 3:
-		pwm = machine.PWM3
-		break
-	case 4:
-		pwm = machine.PWM4
-		break
-	case 5:
-		pwm = machine.PWM5
-		break
-	case 6:
-		pwm = machine.PWM6
-		break
-	case 7:
-		pwm = machine.PWM7
-		break
-	default:
-		return fmt.Errorf("Invalid PWM Pin - Failed to create MCLK Signal.")
-	}
-
-	if err := pwm.Configure(machine.PWMConfig{Period: uint64(1e9 / _freq)}); err != nil {
+	if err := Cam.MCLK.Start(_freq); err != nil {
 		return err
 	}
+	Cam.mclk = _freq
 
-	if ch, err := pwm.Channel(Cam.MCLK); err != nil {
+	// Checking that an OV7670 answers before writing anything to it.
+	if _, err := Cam.Probe(); err != nil {
 		return err
-	} else {
-		pwm.Set(ch, pwm.Top()/2)
 	}
 
 	// Writing Start-up registers.
-	Cam.Write(0x3A, 0x04)
-	Cam.Write(0x13, 0xC0)
-	Cam.Write(0x00, 0x00)
-	Cam.Write(0x10, 0x00)
-	Cam.Write(0x0D, 0x40)
-	Cam.Write(0x14, 0x18)
-	Cam.Write(0x24, 0x95)
-	Cam.Write(0x25, 0x33)
-	Cam.Write(0x13, 0xC5)
-	Cam.Write(0x6A, 0x40)
-	Cam.Write(0x01, 0x40)
-	Cam.Write(0x02, 0x60)
-	Cam.Write(0x13, 0xC7)
-	Cam.Write(0x41, 0x08)
-	Cam.Write(0x15, 0x20)
+	if err := Cam.ApplySequence(initializeSequence); err != nil {
+		return fmt.Errorf("Failed to write start-up registers: %w", err)
+	}
 	return nil
 }
 
@@ -118,9 +123,21 @@ func (Cam *OV7670) Initialize(_freq uint64) error {
 func (Cam *OV7670) Write(reg, val uint8) error {
 	err := Cam.I2C_Bus.WriteRegister(Cam.Address, reg, []byte{val})
 	time.Sleep(time.Millisecond)
+	if err == nil {
+		Cam.record(reg, val)
+	}
 	return err
 }
 
+/*
+* @brief = Writes a value built from named fields to its register.
+* @param field = Any register bitfield, for eg. COM7{Resolution: COM7_QVGA, Format: COM7_RGB}.
+* @return = Returns I2C Error if any.
+ */
+func (Cam *OV7670) WriteField(field RegisterField) error {
+	return Cam.Write(field.Register(), field.Value())
+}
+
 /*
 * @brief = Reads a value to a selected register of OV7670.
 * @param reg = The Desired Register.
@@ -136,95 +153,78 @@ func (Cam *OV7670) Read(reg uint8) (uint8, error) {
 /*
  * @brief = Sets the image output resolution of OV7670
  * @param res = The desired resolution.
+ * @return = error if there is no sequence for res, else the *RegisterError of the first register that failed.
  */
-func (Cam *OV7670) set_resolution(res RESOLUTION) {
-	CurrentResolution = res
-	switch res.String() {
-	case "VGA":
-		Cam.Write(0x0C, 0x00)
-		Cam.Write(0x32, 0xF6)
-		Cam.Write(0x17, 0x13)
-		Cam.Write(0x18, 0x01)
-		Cam.Write(0x19, 0x02)
-		Cam.Write(0x1A, 0x7A)
-		Cam.Write(0x03, 0x0A)
-		break
-	case "QVGA":
-		Cam.Write(0x0C, 0x04)
-		Cam.Write(0x3E, 0x19)
-		Cam.Write(0x72, 0x11)
-		Cam.Write(0x73, 0xF1)
-		Cam.Write(0x17, 0x16)
-		Cam.Write(0x18, 0x04)
-		Cam.Write(0x32, 0xA4)
-		Cam.Write(0x19, 0x02)
-		Cam.Write(0x1A, 0x7A)
-		Cam.Write(0x03, 0x0A)
-		break
-	case "QQVGA":
-		Cam.Write(0x12, 0x00)
-		Cam.Write(0x11, 0x01)
-		Cam.Write(0x0C, 0x04)
-		Cam.Write(0x3E, 0x1A)
-		Cam.Write(0x72, 0x22)
-		Cam.Write(0x73, 0xF2)
-		Cam.Write(0x70, 0x3A)
-		Cam.Write(0x71, 0x35)
-		Cam.Write(0x17, 0x16)
-		Cam.Write(0x18, 0x04)
-		Cam.Write(0x32, 0x80)
-		Cam.Write(0x19, 0x02)
-		Cam.Write(0x1A, 0x7A)
-		Cam.Write(0x03, 0x0A)
-		break
-	default:
-		return
+func (Cam *OV7670) set_resolution(res RESOLUTION) error {
+	seq, ok := resolutionSequences[res]
+	if !ok {
+		return fmt.Errorf("No register sequence for %s.", res.String())
+	}
+
+	if err := Cam.ApplySequence(seq); err != nil {
+		return err
+	}
+	Cam.resolution = res
+	Cam.frame = frameFormats[res]
+	Cam.window = Cam.frame.Window()
+
+	// The sequences hold the window of the normal orientation.
+	if Cam.orientation != (Orientation{}) {
+		return Cam.apply_window(Cam.window)
 	}
+	return nil
 }
 
 /*
- * @brief = Sets the image output resolution of OV7670
- * @param res = The desired resolution.
+ * @brief = Sets the image output format of OV7670
+ * @param col = The desired format.
+ * @return = error if there is no sequence for col, else the *RegisterError of the first register that failed.
+ */
+func (Cam *OV7670) set_color(col IMAGE) error {
+	seq, ok := colorSequences[col]
+	if !ok {
+		return fmt.Errorf("No register sequence for %s.", col.String())
+	}
+
+	if err := Cam.ApplySequence(seq); err != nil {
+		return err
+	}
+	if col == YUV {
+		if err := Cam.ApplySequence(Cam.yuvOrder.sequence()); err != nil {
+			return err
+		}
+	}
+	if matrix, ok := colorMatrices[col]; ok {
+		if err := Cam.ApplySequence(matrix.Adjust(Cam.saturation, Cam.hue).sequence()); err != nil {
+			return err
+		}
+	}
+	Cam.imageType = col
+
+	return nil
+}
+
+/*
+* @brief = Selects the byte order of the YUV422 output, written right away if the camera is in YUV mode.
+* @param order = YUYV, YVYU, UYVY or VYUY.
+* @return = returns an error if the order is not valid or the *RegisterError of the write.
  */
-func (Cam *OV7670) set_color(col IMAGE) {
-	CurrentImageType = col
-	switch col.String() {
-	case "GREYSCALED":
-		Cam.Write(0x12, 0x00)
-		Cam.Write(0x8C, 0x00)
-		Cam.Write(0x04, 0x00)
-		Cam.Write(0x40, 0xC0)
-		Cam.Write(0x14, 0x1A)
-		Cam.Write(0x3D, 0x40)
-		break
-	case "RGB":
-		Cam.Write(0x12, 0x04)
-		Cam.Write(0x8C, 0x00)
-		Cam.Write(0x04, 0x00)
-		Cam.Write(0x40, 0xD0)
-		Cam.Write(0x14, 0x6A)
-		Cam.Write(0x4F, 0xB3)
-		Cam.Write(0x50, 0xB3)
-		Cam.Write(0x51, 0x00)
-		Cam.Write(0x52, 0x3D)
-		Cam.Write(0x53, 0xA7)
-		Cam.Write(0x54, 0xE4)
-		Cam.Write(0x3D, 0x40)
-		break
-	case "BAYER":
-		Cam.Write(0x12, 0x01)
-		Cam.Write(0x3D, 0x08)
-		Cam.Write(0x41, 0x3D)
-		Cam.Write(0x76, 0xE1)
-		break
-	default:
-		return
+func (Cam *OV7670) SetYUVOrder(order YUV_ORDER) error {
+	if order.String() == "NOT VALID" {
+		return fmt.Errorf("Not a valid YUV Order. Value = %d", order)
+	}
+
+	Cam.yuvOrder = order
+	if Cam.imageType == YUV {
+		return Cam.ApplySequence(order.sequence())
 	}
+
+	return nil
 }
 
 /*
 * @brief = Sets the desired Image type and Output Resolution.
-* @return = returns an error if the size or image type are not valid.
+* @return = returns an error if the size or image type are not valid, or wraps the *RegisterError of the register that failed.
 ! Handle Error.
 */
 func (Cam *OV7670) Configure(col IMAGE, res RESOLUTION) error {
@@ -232,9 +232,47 @@ func (Cam *OV7670) Configure(col IMAGE, res RESOLUTION) error {
 		return fmt.Errorf("INVALID COLOR OR RESOLUTION, RESOLUTION: %s | IMAGE: %s\n", res.String(), col.String())
 	}
 
-	Cam.set_color(col)
-	Cam.set_resolution(res)
+	if res == CUSTOM {
+		return fmt.Errorf("CUSTOM resolution needs a size, use ConfigureCustom.")
+	}
+
+	if err := Cam.set_color(col); err != nil {
+		return fmt.Errorf("Failed to set image type %s: %w", col.String(), err)
+	}
+	if err := Cam.set_resolution(res); err != nil {
+		return fmt.Errorf("Failed to set resolution %s: %w", res.String(), err)
+	}
+
+	return nil
+}
+
+/*
+* @brief = Sets the desired Image type and any Output size up to VGA, the resolution becomes CUSTOM.
+* ^ The down sampler and the fractional scaler shrink the whole VGA field of view to the size, a centred window cuts the
+* ^ few pixels the scaler overshoots by. Read the result back with Window.
+* @params width, height = Size of every frame, 1x1 up to 640x480.
+* @return = returns an error if the size or image type are not valid, or wraps the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) ConfigureCustom(col IMAGE, width, height int) error {
+	if col.String() == "NOT VALID" || width <= 0 || height <= 0 || width > SENSOR_WIDTH || height > SENSOR_HEIGHT {
+		return fmt.Errorf("INVALID COLOR OR SIZE, SIZE: %dx%d | IMAGE: %s\n", width, height, col.String())
+	}
+
+	if err := Cam.set_color(col); err != nil {
+		return fmt.Errorf("Failed to set image type %s: %w", col.String(), err)
+	}
+	format, win, seq := customSequence(width, height)
+	if err := Cam.ApplySequence(seq); err != nil {
+		return fmt.Errorf("Failed to set size %dx%d: %w", width, height, err)
+	}
+	Cam.resolution = CUSTOM
+	Cam.frame = format
+	Cam.window = win
 
+	if Cam.orientation != (Orientation{}) {
+		return Cam.apply_window(Cam.window)
+	}
 	return nil
 }
 
@@ -250,9 +288,7 @@ func (Cam *OV7670) SetPCLKSpeed(spd PCLK_DIVIDER) error {
 	if spd.String() == "NOT VALID" {
 		return fmt.Errorf("Not a valid Pixel Clock Divider. Value = %d", spd)
 	}
-	Cam.Write(0x11, uint8(spd))
-
-	return nil
+	return Cam.ApplySequence(RegisterSequence{{REG_CLKRC, uint8(spd), MASK_ALL, 0}})
 }
 
 /*
@@ -298,9 +334,100 @@ func (Cam *OV7670) WaitForHorizontalSyncLow() {
 }
 
 /*
-& OneLine Brief = Resets all the registers in OV7670.
+& OneLine Brief = Resets all the registers in OV7670 through the RESET pin when wired or COM7, returns the I2C Error if any.
+*/
+func (Cam *OV7670) Reset() error {
+	if Cam.RESET != nil {
+		return Cam.HardwareReset()
+	}
+	if err := Cam.ApplySequence(resetSequence); err != nil {
+		return err
+	}
+	Cam.resetState()
+
+	return nil
+}
+
+// & OneLine Brief = Returns the image format this camera is configured to output.
+func (Cam *OV7670) ImageType() IMAGE {
+	return Cam.imageType
+}
+
+/*
+* @brief = Limits the output to a region of interest, only that region is clocked out in every frame.
+* ^ The window is given in output pixels of the whole frame of the configured resolution and is reset by Configure.
+* @params x, y = Top left corner of the region.
+* @params width, height = Size of the region.
+* @return = returns an error if the region does not fit in the resolution or the *RegisterError of the write.
+! Handle Error.
+*/
+func (Cam *OV7670) SetWindow(x, y, width, height int) error {
+	max_width, max_height := Cam.frame.Width, Cam.frame.Height
+	if width <= 0 || height <= 0 || x < 0 || y < 0 || x+width > max_width || y+height > max_height {
+		return fmt.Errorf("Window %dx%d at (%d, %d) does not fit in %s (%dx%d).", width, height, x, y, Cam.resolution.String(), max_width, max_height)
+	}
+
+	return Cam.apply_window(Window{X: x, Y: y, Width: width, Height: height})
+}
+
+/*
+ * @brief = Writes the window registers for the active frame and orientation.
+ * @param win = Window in output pixels of the frame.
+ * @return = *RegisterError of the register that failed.
+ */
+func (Cam *OV7670) apply_window(win Window) error {
+	if err := Cam.ApplySequence(windowSequence(Cam.frame.Oriented(Cam.orientation), win)); err != nil {
+		return err
+	}
+	Cam.window = win
+
+	return nil
+}
+
+/*
+* @brief = Mirrors and/or flips the image in the sensor, the window registers are corrected to keep the same region.
+* @param mirror = Swap left and right.
+* @param flip = Swap top and bottom (upside down mounting needs both).
+* @return = *RegisterError of the register that failed.
+! Handle Error.
 */
-func (Cam *OV7670) Reset() {
-	Cam.Write(0x12, 0x80)
-	time.Sleep(100 * time.Millisecond)
+func (Cam *OV7670) SetOrientation(mirror, flip bool) error {
+	orientation := Orientation{Mirror: mirror, Flip: flip}
+	if err := Cam.ApplySequence(RegisterSequence{{REG_MVFP, MVFP{Mirror: mirror, VFlip: flip}.Value(), MVFP{Mirror: true, VFlip: true}.Value(), 0}}); err != nil {
+		return err
+	}
+
+	Cam.orientation = orientation
+
+	return Cam.apply_window(Cam.window)
+}
+
+// & OneLine Brief = Returns the mirror and flip of the readout.
+func (Cam *OV7670) Orientation() Orientation {
+	return Cam.orientation
+}
+
+// & OneLine Brief = Returns the byte order used in YUV mode.
+func (Cam *OV7670) YUVOrder() YUV_ORDER {
+	return Cam.yuvOrder
+}
+
+// & OneLine Brief = Returns the resolution this camera is configured to output.
+func (Cam *OV7670) Resolution() RESOLUTION {
+	return Cam.resolution
+}
+
+// & OneLine Brief = Returns the output window, its Width and Height are the size of every frame.
+func (Cam *OV7670) Window() Window {
+	return Cam.window
+}
+
+// & OneLine Brief = Returns the whole frame of the configured resolution, SetWindow regions are given inside it.
+func (Cam *OV7670) Frame() FrameFormat {
+	return Cam.frame
+}
+
+// & OneLine Brief = Returns the internal clock prescaler (CLKRC) currently programmed.
+func (Cam *OV7670) ClockDivider() CLKRC {
+	return Cam.clock
 }
@@ -4,6 +4,7 @@ package Camera7670
 ~ File Description:
 ^ Simply simulates the functionality of enums to configure OV7670 with ease.
 ^ Simulates enums named IMAGE (image format) and RESOLUTION (output resolution).
+^ Also holds the Window of the sensor output.
 */
 
 type PCLK_DIVIDER int
@@ -39,6 +40,10 @@ const (
 	GREYSCALED = iota
 	RGB
 	BAYER
+	YUV
+	RGB555
+	RGB444_XRGB
+	RGB444_RGBX
 )
 
 func (i IMAGE) String() string {
@@ -49,17 +54,84 @@ func (i IMAGE) String() string {
 		return "RGB"
 	case BAYER:
 		return "BAYER"
+	case YUV:
+		return "YUV"
+	case RGB555:
+		return "RGB555"
+	case RGB444_XRGB:
+		return "RGB444_XRGB"
+	case RGB444_RGBX:
+		return "RGB444_RGBX"
 	}
 
 	return "NOT VALID"
 }
 
+// & Byte order of the YUV422 output, every two pixels share one U and one V.
+type YUV_ORDER int
+
+const (
+	YUYV YUV_ORDER = iota
+	YVYU
+	UYVY
+	VYUY
+)
+
+func (o YUV_ORDER) String() string {
+	switch o {
+	case YUYV:
+		return "YUYV"
+	case YVYU:
+		return "YVYU"
+	case UYVY:
+		return "UYVY"
+	case VYUY:
+		return "VYUY"
+	}
+
+	return "NOT VALID"
+}
+
+/*
+ * @brief = Positions of the bytes inside the 4 byte group of a pixel pair.
+ * @return = Offsets of Y of the first pixel, U, Y of the second pixel and V.
+ */
+func (o YUV_ORDER) Offsets() (y0, u, y1, v int) {
+	switch o {
+	case YVYU:
+		return 0, 3, 2, 1
+	case UYVY:
+		return 1, 0, 3, 2
+	case VYUY:
+		return 1, 2, 3, 0
+	}
+
+	return 0, 1, 2, 3
+}
+
+/*
+ * @brief = Register bits selecting the order, TSLB[3] picks chroma first and COM13[0] swaps U and V.
+ * @return = Masked writes of TSLB and COM13.
+ */
+func (o YUV_ORDER) sequence() RegisterSequence {
+	uvFirst := o == UYVY || o == VYUY
+	uvSwap := o == YVYU || o == VYUY
+	return RegisterSequence{
+		{REG_TSLB, TSLB{UVFirst: uvFirst}.Value(), 0x08, 0},
+		{REG_COM13, COM13{UVSwap: uvSwap}.Value(), 0x01, 0},
+	}
+}
+
 type RESOLUTION int
 
 const (
 	VGA = iota
 	QVGA
 	QQVGA
+	CIF
+	QCIF
+	QQCIF
+	CUSTOM // Any size up to VGA, set with OV7670.ConfigureCustom.
 )
 
 func (r RESOLUTION) String() string {
@@ -70,7 +142,251 @@ func (r RESOLUTION) String() string {
 		return "QVGA"
 	case QQVGA:
 		return "QQVGA"
+	case CIF:
+		return "CIF"
+	case QCIF:
+		return "QCIF"
+	case QQCIF:
+		return "QQCIF"
+	case CUSTOM:
+		return "CUSTOM"
 	}
 
 	return "NOT VALID"
 }
+
+/*
+ * @brief = Gets the dimension according to the Resolution Mode.
+ * @return = Tuple of integers representing image size, 0x0 for CUSTOM (read OV7670.Window) and 640x480 if the resolution is not valid.
+ */
+func (r RESOLUTION) Dimensions() (int, int) {
+	if r == CUSTOM {
+		return 0, 0
+	}
+	format, ok := frameFormats[r]
+	if !ok {
+		return 640, 480
+	}
+
+	return format.Width, format.Height
+}
+
+/*
+ * @brief = Region of the sensor output, in output pixels.
+ * @elements X, Y = Top left corner.
+ * @elements Width, Height = Size of every frame.
+ */
+type Window struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// & Full VGA frame, the power on window.
+var VGA_WINDOW = Window{Width: 640, Height: 480}
+
+// & OneLine Brief = Pixels in the window.
+func (w Window) Pixels() int {
+	return w.Width * w.Height
+}
+
+// & Sensor timing of the window registers, in sensor pixels and lines.
+const (
+	SENSOR_LINE_PIXELS = 784
+	SENSOR_WIDTH       = 640
+	SENSOR_HEIGHT      = 480
+)
+
+/*
+ * @brief = Whole frame the sensor outputs for a resolution, before any window is cut out of it.
+ * @elements Width, Height = Output size of the whole frame.
+ * @elements SpanX, SpanY = Sensor pixels and lines covered by the whole frame.
+ * @elements OriginX, OriginY = First sensor pixel and line of the output.
+ */
+type FrameFormat struct {
+	Width   int
+	Height  int
+	SpanX   int
+	SpanY   int
+	OriginX int
+	OriginY int
+}
+
+// & OneLine Brief = Window covering the whole frame.
+func (f FrameFormat) Window() Window {
+	return Window{Width: f.Width, Height: f.Height}
+}
+
+/*
+ * @brief = Readout direction of the sensor, set with OV7670.SetOrientation.
+ * @element Mirror = Left and right are swapped.
+ * @element Flip = Top and bottom are swapped.
+ */
+type Orientation struct {
+	Mirror bool
+	Flip   bool
+}
+
+func (o Orientation) String() string {
+	switch o {
+	case Orientation{Mirror: true}:
+		return "MIRRORED"
+	case Orientation{Flip: true}:
+		return "FLIPPED"
+	case Orientation{Mirror: true, Flip: true}:
+		return "ROTATED 180"
+	}
+
+	return "NORMAL"
+}
+
+/*
+ * @brief = Gets the frame as read out in an orientation.
+ * ^ A reversed readout starts one sensor pixel (mirror) or line (flip) later, the window registers move with it to keep the Bayer phase and the alignment.
+ * @param o = Orientation of the readout.
+ * @return = The frame with its origin corrected.
+ */
+func (f FrameFormat) Oriented(o Orientation) FrameFormat {
+	if o.Mirror {
+		f.OriginX++
+	}
+	if o.Flip {
+		f.OriginY++
+	}
+	return f
+}
+
+/*
+ * @brief = First sensor pixel of the VGA based output for each horizontal down sampling rate.
+ * ^ The origin moves with the down sampler because of its pipeline delay (reference register values).
+ */
+var dcwOrigins = [4]int{158, 180, 176, 176}
+
+/*
+ * @brief = Gets the whole frame of the sensor from the COM7 resolution bits and the SCALING_DCWCTR rates.
+ * @param res = Resolution bits of COM7, CIF and QCIF cover 704 sensor pixels per line.
+ * @params h_rate, v_rate = Horizontal and vertical down sampling, the size is halved per step (0 to 3).
+ * @return = The frame format.
+ */
+func ScaledFormat(res COM7_RESOLUTION, h_rate, v_rate uint8) FrameFormat {
+	h_rate, v_rate = min(h_rate, 3), min(v_rate, 3)
+	format := FrameFormat{Width: SENSOR_WIDTH, Height: SENSOR_HEIGHT, SpanX: SENSOR_WIDTH, SpanY: SENSOR_HEIGHT, OriginX: dcwOrigins[h_rate], OriginY: 10}
+	switch res {
+	case COM7_QVGA:
+		format.Width, format.Height = 320, 240
+	case COM7_CIF:
+		format = FrameFormat{Width: 352, Height: 288, SpanX: 704, SpanY: SENSOR_HEIGHT, OriginX: 170, OriginY: 14}
+	case COM7_QCIF:
+		format = FrameFormat{Width: 176, Height: 144, SpanX: 704, SpanY: SENSOR_HEIGHT, OriginX: 170, OriginY: 14}
+	}
+	format.Width >>= h_rate
+	format.Height >>= v_rate
+
+	return format
+}
+
+/*
+ * @brief = Values of SCALING_XSC[6:0] and SCALING_YSC[6:0] at which the scaler passes the down sampled frame through 1:1.
+ * ^ They are the reference values of every standard size. With COM3 scale enable a larger value zooms out by UNITY / value,
+ * ^ down to about half, which fills the gaps in between two halvings of the down sampler.
+ */
+const (
+	SCALING_XSC_UNITY uint8 = 0x3A
+	SCALING_YSC_UNITY uint8 = 0x35
+	SCALING_MAX       uint8 = 0x7F
+)
+
+/*
+ * @brief = Gets the frame after the fractional scaler.
+ * @params xsc, ysc = Scale factors of SCALING_XSC and SCALING_YSC, the test pattern bit is ignored.
+ * @return = The frame with its output size shrunk, it still covers the same sensor pixels and lines.
+ */
+func (f FrameFormat) Zoomed(xsc, ysc uint8) FrameFormat {
+	xsc, ysc = max(xsc&SCALING_MAX, SCALING_XSC_UNITY), max(ysc&SCALING_MAX, SCALING_YSC_UNITY)
+	f.Width = f.Width * int(SCALING_XSC_UNITY) / int(xsc)
+	f.Height = f.Height * int(SCALING_YSC_UNITY) / int(ysc)
+	return f
+}
+
+// & Whole frame of every standard resolution.
+var frameFormats = map[RESOLUTION]FrameFormat{
+	VGA:   ScaledFormat(COM7_VGA, 0, 0),
+	QVGA:  ScaledFormat(COM7_VGA, 1, 1),
+	QQVGA: ScaledFormat(COM7_VGA, 2, 2),
+	CIF:   ScaledFormat(COM7_CIF, 0, 0),
+	QCIF:  ScaledFormat(COM7_QCIF, 0, 0),
+	QQCIF: ScaledFormat(COM7_QCIF, 1, 1),
+}
+
+/*
+ * @brief = Builds the writes of HSTART/HSTOP/HREF and VSTRT/VSTOP/VREF for a window.
+ * @param format = Whole frame the window is cut out of.
+ * @param win = Window in output pixels of format.
+ * ^ Sensor positions are rounded up, so a scaled frame with fewer output pixels than sensor pixels maps back to exactly win.
+ * @return = Register sequence, HREF and VREF are masked to keep the HREF edge offset and the AGC gain bits.
+ */
+func windowSequence(format FrameFormat, win Window) RegisterSequence {
+	to_sensor := func(output, span, size int) int { return (output*span + size - 1) / size }
+	hstart := format.OriginX + to_sensor(win.X, format.SpanX, format.Width)
+	hstop := (hstart + to_sensor(win.Width, format.SpanX, format.Width)) % SENSOR_LINE_PIXELS
+	vstart := format.OriginY + to_sensor(win.Y, format.SpanY, format.Height)
+	vstop := vstart + to_sensor(win.Height, format.SpanY, format.Height)
+
+	return RegisterSequence{
+		{REG_HSTART, uint8(hstart >> 3), MASK_ALL, 0},
+		{REG_HSTOP, uint8(hstop >> 3), MASK_ALL, 0},
+		{REG_HREF, uint8(hstop&0x07)<<3 | uint8(hstart&0x07), 0x3F, 0},
+		{REG_VSTRT, uint8(vstart >> 2), MASK_ALL, 0},
+		{REG_VSTOP, uint8(vstop >> 2), MASK_ALL, 0},
+		{REG_VREF, uint8(vstop&0x03)<<2 | uint8(vstart&0x03), 0x0F, 0},
+	}
+}
+
+/*
+ * @brief = Scale factor that shrinks a size to the smallest one the scaler can make that is still at least target.
+ * @params size, target = Size out of the down sampler and size wanted.
+ * @param unity = SCALING_XSC_UNITY or SCALING_YSC_UNITY.
+ * @return = Value of SCALING_XSC/YSC[6:0].
+ */
+func scale_factor(size, target int, unity uint8) uint8 {
+	return uint8(min(max(size*int(unity)/target, int(unity)), int(SCALING_MAX)))
+}
+
+/*
+ * @brief = Computes the scaler settings of a custom output size.
+ * ^ The down sampler halves the VGA frame until the next halving would be smaller than the size, SCALING_XSC/YSC zoom the
+ * ^ result out to the size, so the whole field of view of VGA is kept. Scale factors are whole steps of the register, the
+ * ^ few pixels they overshoot by (and sizes under 80 * UNITY / SCALING_MAX wide or 60 * UNITY / SCALING_MAX high) are cut by a centred window.
+ * @params width, height = Output size, up to 640x480.
+ * @return = Frame the size is cut out of, the centred window and the register sequence.
+ */
+func customSequence(width, height int) (FrameFormat, Window, RegisterSequence) {
+	var h_rate, v_rate uint8
+	for h_rate < 3 && SENSOR_WIDTH>>(h_rate+1) >= width {
+		h_rate++
+	}
+	for v_rate < 3 && SENSOR_HEIGHT>>(v_rate+1) >= height {
+		v_rate++
+	}
+
+	down_sampled := ScaledFormat(COM7_VGA, h_rate, v_rate)
+	xsc := scale_factor(down_sampled.Width, width, SCALING_XSC_UNITY)
+	ysc := scale_factor(down_sampled.Height, height, SCALING_YSC_UNITY)
+	scaled := xsc != SCALING_XSC_UNITY || ysc != SCALING_YSC_UNITY
+
+	format := down_sampled.Zoomed(xsc, ysc)
+	win := Window{X: (format.Width - width) / 2, Y: (format.Height - height) / 2, Width: width, Height: height}
+
+	seq := RegisterSequence{
+		{REG_COM7, COM7{Resolution: COM7_VGA}.Value(), 0x38, 0},
+		{REG_COM3, COM3{DCWEnable: h_rate != 0 || v_rate != 0, ScaleEnable: scaled}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{ScalingPCLK: h_rate != 0, ManualScaling: true, PCLKDivider: h_rate}.Value(), MASK_ALL, 0},
+		{REG_SCALING_DCWCTR, SCALING_DCWCTR{VerticalRate: v_rate, HorizontalRate: h_rate}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{Divider: h_rate}.Value(), MASK_ALL, 0},
+		{REG_SCALING_XSC, xsc, SCALING_MAX, 0}, // Bit 7 selects the test pattern.
+		{REG_SCALING_YSC, ysc, SCALING_MAX, 0},
+	}
+
+	return format, win, append(seq, windowSequence(format, win)...)
+}
@@ -0,0 +1,163 @@
+package Camera7670
+
+import "time"
+
+/*
+~ File Description:
+^ Register sequences used by Initialize, Configure and Reset.
+^ Each row is {Register, Value, Mask, Delay}, applied in order by ApplySequence.
+*/
+
+// & Soft reset through COM7, the sensor needs some time before it accepts new writes.
+var resetSequence = RegisterSequence{
+	{REG_COM7, COM7{Reset: true}.Value(), MASK_ALL, 100 * time.Millisecond},
+}
+
+// & Start-up registers written by Initialize.
+var initializeSequence = RegisterSequence{
+	{REG_TSLB, TSLB{YLast: true}.Value(), MASK_ALL, 0},
+	{REG_COM8, COM8{FastAEC: true, AECStepUnlimited: true}.Value(), MASK_ALL, 0},
+	{REG_GAIN, 0x00, MASK_ALL, 0},
+	{REG_AECH, 0x00, MASK_ALL, 0},
+	{REG_COM4, 0x40, MASK_ALL, 0},
+	{REG_COM9, 0x18, MASK_ALL, 0}, // 4x gain ceiling.
+	{REG_AEW, 0x95, MASK_ALL, 0},
+	{REG_AEB, 0x33, MASK_ALL, 0},
+	{REG_COM8, COM8{FastAEC: true, AECStepUnlimited: true, AGC: true, AEC: true}.Value(), MASK_ALL, 0},
+	{REG_GGAIN, 0x40, MASK_ALL, 0},
+	{REG_BLUE, 0x40, MASK_ALL, 0},
+	{REG_RED, 0x60, MASK_ALL, 0},
+	{REG_COM8, COM8{FastAEC: true, AECStepUnlimited: true, AGC: true, AWB: true, AEC: true}.Value(), MASK_ALL, 0},
+	{REG_COM16, 0x08, MASK_ALL, 0}, // AWB gain enable.
+	{REG_COM10, COM10{PCLKGatedByHREF: true}.Value(), MASK_ALL, 0},
+}
+
+// & Output resolution sequences written by Configure.
+var resolutionSequences = map[RESOLUTION]RegisterSequence{
+	VGA: {
+		// Only the resolution bits of COM7, the format bits belong to the colour sequence.
+		{REG_COM7, COM7{Resolution: COM7_VGA}.Value(), 0x38, 0},
+		{REG_COM3, COM3{}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{}.Value(), MASK_ALL, 0},
+		{REG_HREF, 0xF6, MASK_ALL, 0},
+		{REG_HSTART, 0x13, MASK_ALL, 0},
+		{REG_HSTOP, 0x01, MASK_ALL, 0},
+		{REG_VSTRT, 0x02, MASK_ALL, 0},
+		{REG_VSTOP, 0x7A, MASK_ALL, 0},
+		{REG_VREF, 0x0A, 0x0F, 0}, // VREF[7:6] are AGC gain bits [9:8].
+	},
+	QVGA: {
+		{REG_COM7, COM7{Resolution: COM7_VGA}.Value(), 0x38, 0},
+		{REG_COM3, COM3{DCWEnable: true}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{ScalingPCLK: true, ManualScaling: true, PCLKDivider: 1}.Value(), MASK_ALL, 0},
+		{REG_SCALING_DCWCTR, SCALING_DCWCTR{VerticalRate: 1, HorizontalRate: 1}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{Divider: 1}.Value(), MASK_ALL, 0},
+		{REG_HSTART, 0x16, MASK_ALL, 0},
+		{REG_HSTOP, 0x04, MASK_ALL, 0},
+		{REG_HREF, 0xA4, MASK_ALL, 0},
+		{REG_VSTRT, 0x02, MASK_ALL, 0},
+		{REG_VSTOP, 0x7A, MASK_ALL, 0},
+		{REG_VREF, 0x0A, 0x0F, 0},
+	},
+	QQVGA: {
+		{REG_COM7, COM7{Resolution: COM7_VGA}.Value(), 0x38, 0},
+		{REG_CLKRC, CLKRC{Prescaler: 1}.Value(), MASK_ALL, 0},
+		{REG_COM3, COM3{DCWEnable: true}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{ScalingPCLK: true, ManualScaling: true, PCLKDivider: 2}.Value(), MASK_ALL, 0},
+		{REG_SCALING_DCWCTR, SCALING_DCWCTR{VerticalRate: 2, HorizontalRate: 2}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{Divider: 2}.Value(), MASK_ALL, 0},
+		{REG_SCALING_XSC, SCALING_XSC_UNITY, SCALING_MAX, 0}, // Bit 7 selects the test pattern.
+		{REG_SCALING_YSC, SCALING_YSC_UNITY, SCALING_MAX, 0},
+		{REG_HSTART, 0x16, MASK_ALL, 0},
+		{REG_HSTOP, 0x04, MASK_ALL, 0},
+		{REG_HREF, 0x80, MASK_ALL, 0},
+		{REG_VSTRT, 0x02, MASK_ALL, 0},
+		{REG_VSTOP, 0x7A, MASK_ALL, 0},
+		{REG_VREF, 0x0A, 0x0F, 0},
+	},
+	// CIF and QCIF are scaled by the sensor itself from 704 pixels per line, the DSP scaler stays off.
+	CIF: append(RegisterSequence{
+		{REG_COM7, COM7{Resolution: COM7_CIF}.Value(), 0x38, 0},
+		{REG_COM3, COM3{}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{}.Value(), MASK_ALL, 0},
+		{REG_SCALING_DCWCTR, SCALING_DCWCTR{}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{}.Value(), MASK_ALL, 0},
+	}, windowSequence(frameFormats[CIF], frameFormats[CIF].Window())...),
+	QCIF: append(RegisterSequence{
+		{REG_COM7, COM7{Resolution: COM7_QCIF}.Value(), 0x38, 0},
+		{REG_COM3, COM3{}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{}.Value(), MASK_ALL, 0},
+		{REG_SCALING_DCWCTR, SCALING_DCWCTR{}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{}.Value(), MASK_ALL, 0},
+	}, windowSequence(frameFormats[QCIF], frameFormats[QCIF].Window())...),
+	QQCIF: append(RegisterSequence{
+		{REG_COM7, COM7{Resolution: COM7_QCIF}.Value(), 0x38, 0},
+		{REG_COM3, COM3{DCWEnable: true}.Value(), MASK_ALL, 0},
+		{REG_COM14, COM14{ScalingPCLK: true, ManualScaling: true, PCLKDivider: 1}.Value(), MASK_ALL, 0},
+		{REG_SCALING_DCWCTR, SCALING_DCWCTR{VerticalRate: 1, HorizontalRate: 1}.Value(), MASK_ALL, 0},
+		{REG_SCALING_PCLK_DIV, SCALING_PCLK_DIV{Divider: 1}.Value(), MASK_ALL, 0},
+	}, windowSequence(frameFormats[QQCIF], frameFormats[QQCIF].Window())...),
+}
+
+// & Image format sequences written by Configure.
+// ^ GREYSCALED keeps only the first byte of every pixel so it always runs YUYV, YUV adds the order of YUVOrder().
+// ^ RGB444 is selected through COM15 RGB565 together with the RGB444 register.
+// ^ The colour matrix is not part of these, set_color writes colorMatrices adjusted by SetSaturationHue.
+var colorSequences = map[IMAGE]RegisterSequence{
+	GREYSCALED: {
+		{REG_COM7, COM7{Format: COM7_YUV}.Value(), MASK_ALL, 0},
+		{REG_RGB444, RGB444{}.Value(), MASK_ALL, 0},
+		{REG_COM1, 0x00, 0xFC, 0}, // CCIR656 off, AEC[1:0] belong to the exposure.
+		{REG_COM15, COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB_NORMAL}.Value(), MASK_ALL, 0},
+		{REG_COM9, 0x1A, MASK_ALL, 0},
+		{REG_COM13, COM13{UVSaturationAuto: true}.Value(), 0x7F, 0}, // Gamma enable belongs to SetGammaCurve.
+		{REG_TSLB, TSLB{UVFirst: false}.Value(), 0x08, 0},
+	},
+	YUV: {
+		{REG_COM7, COM7{Format: COM7_YUV}.Value(), MASK_ALL, 0},
+		{REG_RGB444, RGB444{}.Value(), MASK_ALL, 0},
+		{REG_COM1, 0x00, 0xFC, 0}, // CCIR656 off, AEC[1:0] belong to the exposure.
+		{REG_COM15, COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB_NORMAL}.Value(), MASK_ALL, 0},
+		{REG_COM9, 0x1A, MASK_ALL, 0},
+		{REG_COM13, COM13{UVSaturationAuto: true}.Value(), 0x7F, 0}, // Gamma enable belongs to SetGammaCurve.
+	},
+	RGB: {
+		{REG_COM7, COM7{Format: COM7_RGB}.Value(), MASK_ALL, 0},
+		{REG_RGB444, RGB444{}.Value(), MASK_ALL, 0},
+		{REG_COM1, 0x00, 0xFC, 0}, // CCIR656 off, AEC[1:0] belong to the exposure.
+		{REG_COM15, COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB565}.Value(), MASK_ALL, 0},
+		{REG_COM9, 0x6A, MASK_ALL, 0},
+		{REG_COM13, COM13{UVSaturationAuto: true}.Value(), 0x7F, 0}, // Gamma enable belongs to SetGammaCurve.
+	},
+	RGB555: {
+		{REG_COM7, COM7{Format: COM7_RGB}.Value(), MASK_ALL, 0},
+		{REG_RGB444, RGB444{}.Value(), MASK_ALL, 0},
+		{REG_COM1, 0x00, 0xFC, 0}, // CCIR656 off, AEC[1:0] belong to the exposure.
+		{REG_COM15, COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB555}.Value(), MASK_ALL, 0},
+		{REG_COM9, 0x6A, MASK_ALL, 0},
+		{REG_COM13, COM13{UVSaturationAuto: true}.Value(), 0x7F, 0}, // Gamma enable belongs to SetGammaCurve.
+	},
+	RGB444_XRGB: {
+		{REG_COM7, COM7{Format: COM7_RGB}.Value(), MASK_ALL, 0},
+		{REG_RGB444, RGB444{Enable: true}.Value(), MASK_ALL, 0},
+		{REG_COM1, 0x00, 0xFC, 0}, // CCIR656 off, AEC[1:0] belong to the exposure.
+		{REG_COM15, COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB565}.Value(), MASK_ALL, 0},
+		{REG_COM9, 0x6A, MASK_ALL, 0},
+		{REG_COM13, COM13{UVSaturationAuto: true}.Value(), 0x7F, 0}, // Gamma enable belongs to SetGammaCurve.
+	},
+	RGB444_RGBX: {
+		{REG_COM7, COM7{Format: COM7_RGB}.Value(), MASK_ALL, 0},
+		{REG_RGB444, RGB444{Enable: true, WordFormatRGBx: true}.Value(), MASK_ALL, 0},
+		{REG_COM1, 0x00, 0xFC, 0}, // CCIR656 off, AEC[1:0] belong to the exposure.
+		{REG_COM15, COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB565}.Value(), MASK_ALL, 0},
+		{REG_COM9, 0x6A, MASK_ALL, 0},
+		{REG_COM13, COM13{UVSaturationAuto: true}.Value(), 0x7F, 0}, // Gamma enable belongs to SetGammaCurve.
+	},
+	BAYER: {
+		{REG_COM7, COM7{Format: COM7_RAW_BAYER}.Value(), MASK_ALL, 0},
+		{REG_COM13, 0x08, 0x7F, 0},
+		{REG_COM16, 0x3D, MASK_ALL, 0},
+		{REG_REG76, 0xE1, MASK_ALL, 0},
+	},
+}
@@ -0,0 +1,316 @@
+package Camera7670
+
+import (
+	"errors"
+	"testing"
+)
+
+/*
+~ File Description:
+^ Host tests of the driver through the RegisterBus, InputPin, ClockSource and DataPort seams of Hardware.go.
+^ fakeBus is a register file with failures that can be injected per register.
+*/
+
+// & Error the fake bus returns for injected failures.
+var errBus = errors.New("bus failed")
+
+/*
+ * @brief = Register file of a fake OV7670.
+ * @element registers = Value of every register.
+ * @element writes = Registers in the order they were written.
+ * @elements failWrite, failRead = Registers whose writes or reads return errBus.
+ * @element stuck = Registers that ignore writes.
+ */
+type fakeBus struct {
+	registers [256]uint8
+	writes    []uint8
+	failWrite map[uint8]bool
+	failRead  map[uint8]bool
+	stuck     map[uint8]bool
+}
+
+// & OneLine Brief = Fake bus answering with the identity of an OV7670.
+func newFakeBus() *fakeBus {
+	bus := &fakeBus{failWrite: map[uint8]bool{}, failRead: map[uint8]bool{}, stuck: map[uint8]bool{}}
+	bus.registers[REG_PID], bus.registers[REG_VER] = OV7670_PID, OV7670_VER
+	bus.registers[REG_MIDH], bus.registers[REG_MIDL] = 0x7F, 0xA2
+	return bus
+}
+
+func (b *fakeBus) WriteRegister(address uint8, reg uint8, data []byte) error {
+	if b.failWrite[reg] {
+		return errBus
+	}
+	b.writes = append(b.writes, reg)
+	if !b.stuck[reg] {
+		b.registers[reg] = data[0]
+	}
+	return nil
+}
+
+func (b *fakeBus) ReadRegister(address uint8, reg uint8, data []byte) error {
+	if b.failRead[reg] {
+		return errBus
+	}
+	data[0] = b.registers[reg]
+	return nil
+}
+
+// & OneLine Brief = Reports whether a register was written.
+func (b *fakeBus) wrote(reg uint8) bool {
+	for _, written := range b.writes {
+		if written == reg {
+			return true
+		}
+	}
+	return false
+}
+
+type fakePin bool
+
+func (p fakePin) Get() bool { return bool(p) }
+
+type fakeClock struct {
+	frequency uint64
+	err       error
+}
+
+func (c *fakeClock) Start(frequency uint64) error {
+	c.frequency = frequency
+	return c.err
+}
+
+func (c *fakeClock) Stop() error {
+	c.frequency = 0
+	return nil
+}
+
+type fakePort uint8
+
+func (p fakePort) Read() uint8 { return uint8(p) }
+
+// & OneLine Brief = Camera on a fake bus with idle pins.
+func newFakeCamera(bus *fakeBus, clock *fakeClock) *OV7670 {
+	return CreateOV7670(bus, fakePin(false), fakePin(false), clock, fakePin(false), fakePort(0))
+}
+
+func TestInitialize(t *testing.T) {
+	bus, clock := newFakeBus(), &fakeClock{}
+	Cam := newFakeCamera(bus, clock)
+
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if clock.frequency != 20_000_000 {
+		t.Errorf("MCLK started at %d Hz, want 20000000", clock.frequency)
+	}
+	for _, entry := range initializeSequence {
+		if !bus.wrote(entry.Register) {
+			t.Errorf("%s was not written", RegisterName(entry.Register))
+		}
+	}
+	if got, want := bus.registers[REG_COM10], (COM10{PCLKGatedByHREF: true}).Value(); got != want {
+		t.Errorf("COM10 = 0x%02X, want 0x%02X", got, want)
+	}
+}
+
+func TestInitializeFailures(t *testing.T) {
+	t.Run("clock", func(t *testing.T) {
+		Cam := newFakeCamera(newFakeBus(), &fakeClock{err: errBus})
+		if err := Cam.Initialize(20_000_000); !errors.Is(err, errBus) {
+			t.Fatalf("Initialize = %v, want the clock error", err)
+		}
+	})
+
+	t.Run("no device", func(t *testing.T) {
+		bus := newFakeBus()
+		bus.failRead[REG_PID] = true
+		err := newFakeCamera(bus, &fakeClock{}).Initialize(20_000_000)
+		var probe *ProbeError
+		var register *RegisterError
+		if !errors.As(err, &probe) || !errors.Is(err, ErrNoDevice) {
+			t.Fatalf("Initialize = %v, want a *ProbeError with ErrNoDevice", err)
+		}
+		if !errors.As(err, &register) || register.Register != REG_PID || register.Operation != "read" {
+			t.Fatalf("Initialize = %v, want the read of PID", err)
+		}
+		if len(bus.writes) != 0 {
+			t.Errorf("%d registers written without a sensor", len(bus.writes))
+		}
+	})
+
+	t.Run("other part", func(t *testing.T) {
+		bus := newFakeBus()
+		bus.registers[REG_PID] = 0x77
+		if err := newFakeCamera(bus, &fakeClock{}).Initialize(20_000_000); !errors.Is(err, ErrUnsupportedPID) {
+			t.Fatalf("Initialize = %v, want ErrUnsupportedPID", err)
+		}
+	})
+
+	t.Run("write", func(t *testing.T) {
+		bus := newFakeBus()
+		bus.failWrite[REG_COM9] = true
+		err := newFakeCamera(bus, &fakeClock{}).Initialize(20_000_000)
+		var register *RegisterError
+		if !errors.As(err, &register) || register.Register != REG_COM9 || register.Operation != "write" || !errors.Is(err, errBus) {
+			t.Fatalf("Initialize = %v, want the write of COM9 wrapping the bus error", err)
+		}
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	if err := Cam.Configure(RGB, QVGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if com7 := ParseCOM7(bus.registers[REG_COM7]); com7.Format != COM7_RGB || com7.Resolution != COM7_VGA {
+		t.Errorf("COM7 = 0x%02X, want RGB at VGA", bus.registers[REG_COM7])
+	}
+	if got, want := bus.registers[REG_COM15], (COM15{Range: COM15_RANGE_00_FF, RGB: COM15_RGB565}).Value(); got != want {
+		t.Errorf("COM15 = 0x%02X, want 0x%02X", got, want)
+	}
+	if !ParseCOM3(bus.registers[REG_COM3]).DCWEnable {
+		t.Errorf("COM3 = 0x%02X, want the down sampler on for QVGA", bus.registers[REG_COM3])
+	}
+	if Cam.ImageType() != RGB || Cam.Resolution() != QVGA {
+		t.Errorf("state = %s %s, want RGB QVGA", Cam.ImageType(), Cam.Resolution())
+	}
+	if win := Cam.Window(); win.Width != 320 || win.Height != 240 {
+		t.Errorf("window = %dx%d, want 320x240", win.Width, win.Height)
+	}
+
+	if err := Cam.Configure(IMAGE(99), QVGA); err == nil {
+		t.Errorf("Configure accepted a format that is not valid")
+	}
+	if err := Cam.Configure(RGB, CUSTOM); err == nil {
+		t.Errorf("Configure accepted CUSTOM without a size")
+	}
+}
+
+func TestMissingSequence(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+
+	if err := Cam.set_resolution(CUSTOM); err == nil {
+		t.Errorf("set_resolution(CUSTOM) = nil, want an error for the missing sequence")
+	}
+	if err := Cam.set_color(IMAGE(99)); err == nil {
+		t.Errorf("set_color(99) = nil, want an error for the missing sequence")
+	}
+	if len(bus.writes) != 0 || Cam.Resolution() != VGA {
+		t.Errorf("missing sequences wrote %d registers and left the resolution at %s", len(bus.writes), Cam.Resolution())
+	}
+}
+
+func TestConfigureRegisterErrors(t *testing.T) {
+	t.Run("write", func(t *testing.T) {
+		bus := newFakeBus()
+		Cam := newFakeCamera(bus, &fakeClock{})
+		bus.failWrite[REG_COM15] = true
+		err := Cam.Configure(RGB, QVGA)
+		var register *RegisterError
+		if !errors.As(err, &register) || register.Register != REG_COM15 || register.Operation != "write" || !errors.Is(err, errBus) {
+			t.Fatalf("Configure = %v, want the write of COM15 wrapping the bus error", err)
+		}
+		if Cam.ImageType() == RGB {
+			t.Errorf("image type changed to RGB although its sequence failed")
+		}
+	})
+
+	t.Run("read", func(t *testing.T) {
+		// The resolution bits of COM7 are a read-modify-write.
+		bus := newFakeBus()
+		Cam := newFakeCamera(bus, &fakeClock{})
+		bus.failRead[REG_COM7] = true
+		err := Cam.Configure(RGB, QVGA)
+		var register *RegisterError
+		if !errors.As(err, &register) || register.Register != REG_COM7 || register.Operation != "read" {
+			t.Fatalf("Configure = %v, want the read of COM7", err)
+		}
+		if Cam.Resolution() == QVGA {
+			t.Errorf("resolution changed to QVGA although its sequence failed")
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		bus := newFakeBus()
+		Cam := newFakeCamera(bus, &fakeClock{})
+		Cam.VerifyWrites = true
+		bus.stuck[REG_COM9] = true
+		err := Cam.Configure(RGB, QVGA)
+		var register *RegisterError
+		if !errors.As(err, &register) || register.Register != REG_COM9 || register.Operation != "verify" || !errors.Is(err, ErrRegisterMismatch) {
+			t.Fatalf("Configure = %v, want a read-back mismatch of COM9", err)
+		}
+		if register.Expected != 0x6A || register.Got != 0x00 {
+			t.Errorf("mismatch = expected 0x%02X got 0x%02X, want 0x6A and 0x00", register.Expected, register.Got)
+		}
+	})
+}
+
+func TestConfigureKeepsExposure(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	bus.registers[REG_COM1] = 0x40 // CCIR656 left on by someone else.
+
+	const lines = 0x1235 // AEC[1:0] = 01 lives in COM1.
+	if err := Cam.SetExposure(lines); err != nil {
+		t.Fatalf("SetExposure: %v", err)
+	}
+	for _, col := range []IMAGE{GREYSCALED, YUV, RGB, RGB555, RGB444_XRGB, RGB444_RGBX} {
+		if err := Cam.Configure(col, QVGA); err != nil {
+			t.Fatalf("Configure %s: %v", col, err)
+		}
+		if exposure, err := Cam.Exposure(); err != nil || exposure != lines {
+			t.Errorf("%s: exposure = 0x%04X (%v), want 0x%04X", col, exposure, err, lines)
+		}
+		if bus.registers[REG_COM1]&0x40 != 0 {
+			t.Errorf("%s: COM1 = 0x%02X, want CCIR656 off", col, bus.registers[REG_COM1])
+		}
+	}
+
+	// VREF[7:6] hold gain bits [9:8], the VGA based resolutions write VREF too.
+	if err := Cam.SetGain(MAX_GAIN); err != nil {
+		t.Fatalf("SetGain: %v", err)
+	}
+	for _, res := range []RESOLUTION{VGA, QVGA, QQVGA, CIF, QCIF, QQCIF} {
+		if err := Cam.Configure(RGB, res); err != nil {
+			t.Fatalf("Configure %s: %v", res, err)
+		}
+		if gain, err := Cam.Gain(); err != nil || gain != MAX_GAIN {
+			t.Errorf("%s: gain = 0x%03X (%v), want 0x%03X", res, gain, err, MAX_GAIN)
+		}
+	}
+}
+
+func TestGammaSurvivesConfigure(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	bus.registers[REG_COM13] = 0x00
+
+	if err := Cam.SetGamma(2.2); err != nil {
+		t.Fatalf("SetGamma: %v", err)
+	}
+	if !ParseCOM13(bus.registers[REG_COM13]).GammaEnable {
+		t.Fatalf("COM13 = 0x%02X after SetGamma, want gamma on", bus.registers[REG_COM13])
+	}
+	for _, col := range []IMAGE{GREYSCALED, YUV, RGB, RGB555, RGB444_XRGB, RGB444_RGBX, BAYER} {
+		if err := Cam.Configure(col, QVGA); err != nil {
+			t.Fatalf("Configure %s: %v", col, err)
+		}
+		if !ParseCOM13(bus.registers[REG_COM13]).GammaEnable {
+			t.Errorf("%s: COM13 = 0x%02X, want gamma still on", col, bus.registers[REG_COM13])
+		}
+	}
+}
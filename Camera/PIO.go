@@ -0,0 +1,458 @@
+package Camera7670
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Capture with a PIO state machine of the RP2040: it waits for HREF, samples D[7:0] on every rising PCLK edge and pushes the bytes to its RX FIFO.
+^ Two DMA channels drain the FIFO into two line buffers, each one chains to the other once the CPU has read its line, so PCLK runs at full speed.
+^ Everything goes through register offsets of PIO0 and the DMA (PIOHardware) so the same code runs on the chip and on the emulator of the simulator.
+*/
+
+// & Register offsets of a PIO block (RP2040 datasheet 3.7).
+const (
+	PIO_CTRL          = 0x000
+	PIO_FSTAT         = 0x004
+	PIO_FDEBUG        = 0x008
+	PIO_TXF0          = 0x010
+	PIO_RXF0          = 0x020
+	PIO_INSTR_MEM0    = 0x048
+	PIO_SM0_CLKDIV    = 0x0C8
+	PIO_SM0_EXECCTRL  = 0x0CC
+	PIO_SM0_SHIFTCTRL = 0x0D0
+	PIO_SM0_ADDR      = 0x0D4
+	PIO_SM0_INSTR     = 0x0D8
+	PIO_SM0_PINCTRL   = 0x0DC
+	PIO_SM_STRIDE     = 0x018 // Offset between the registers of two state machines.
+	PIO_INSTR_COUNT   = 32
+)
+
+// & Fields of the PIO registers, shifted by the number of the state machine where there is one bit per machine.
+const (
+	PIO_CTRL_SM_ENABLE            = 0x001
+	PIO_CTRL_SM_RESTART           = 0x010
+	PIO_CTRL_CLKDIV_RESTART       = 0x100
+	PIO_FDEBUG_RXSTALL            = 0x001
+	PIO_CLKDIV_INT_Pos            = 16
+	PIO_EXECCTRL_WRAP_TOP_Pos     = 12
+	PIO_EXECCTRL_WRAP_BOTTOM_Pos  = 7
+	PIO_SHIFTCTRL_AUTOPUSH        = 1 << 16
+	PIO_SHIFTCTRL_IN_SHIFTDIR     = 1 << 18 // Shift right, the first bits end up in the low bits of a word.
+	PIO_SHIFTCTRL_OUT_SHIFTDIR    = 1 << 19
+	PIO_SHIFTCTRL_PUSH_THRESH_Pos = 20
+	PIO_SHIFTCTRL_FJOIN_RX        = 1 << 31
+	PIO_PINCTRL_IN_BASE_Pos       = 15
+)
+
+// & Register offsets of the DMA (RP2040 datasheet 2.5), the channel registers repeat every DMA_CHANNEL_STRIDE.
+const (
+	DMA_READ_ADDR      = 0x000
+	DMA_WRITE_ADDR     = 0x004
+	DMA_TRANS_COUNT    = 0x008
+	DMA_CTRL_TRIG      = 0x00C
+	DMA_AL1_CTRL       = 0x010
+	DMA_CHANNEL_STRIDE = 0x040
+	DMA_INTR           = 0x400
+	DMA_CHAN_ABORT     = 0x444
+	DMA_CHANNELS       = 12
+)
+
+// & Fields of the DMA CTRL register.
+const (
+	DMA_CTRL_EN             = 1 << 0
+	DMA_CTRL_DATA_SIZE_WORD = 2 << 2
+	DMA_CTRL_INCR_READ      = 1 << 4
+	DMA_CTRL_INCR_WRITE     = 1 << 5
+	DMA_CTRL_CHAIN_TO_Pos   = 11
+	DMA_CTRL_TREQ_SEL_Pos   = 15
+	DMA_CTRL_BUSY           = 1 << 24
+	DMA_DREQ_PIO0_RX0       = 4 // Data request of the RX FIFO of state machine 0 of PIO0, + the machine number.
+)
+
+// & PIO instructions used by the capture program.
+const (
+	pio_jmp       = 0x0000
+	pio_wait      = 0x2000
+	pio_in        = 0x4000
+	pio_pull      = 0x8080
+	pio_mov       = 0xA000
+	pio_jmp_x_dec = 2 << 5
+	pio_in_pins   = 0 << 5
+	pio_mov_x     = 1 << 5
+	pio_mov_osr   = 7
+	pio_block     = 1 << 5
+)
+
+/*
+ * @brief = Register access of a PIO block and the DMA.
+ * @methods ReadPIO, WritePIO = Access the PIO0 register at offset.
+ * @methods ReadDMA, WriteDMA = Access the DMA register at offset.
+ * @method PIOAddress = Bus address of a PIO0 register, DMA reads the RX FIFO there.
+ * @method Address = Bus address of a buffer, DMA writes the lines there.
+ * ^ RP2040PIO implements it on target (PIORP2040.go), the simulator implements it with an emulator.
+ */
+type PIOHardware interface {
+	ReadPIO(offset uint32) uint32
+	WritePIO(offset uint32, value uint32)
+	ReadDMA(offset uint32) uint32
+	WriteDMA(offset uint32, value uint32)
+	PIOAddress(offset uint32) uint32
+	Address(buffer []uint32) uint32
+}
+
+/*
+ * @brief = GPIOs the PIO capture reads.
+ * @elements VSync, HREF, PCLK = GPIO number of the sync and clock outputs of OV7670.
+ * @element Data = Plan of D[7:0], consecutive pins are sampled as a byte, any other wiring as the whole register and remapped.
+ */
+type PIOPins struct {
+	VSync uint8
+	HREF  uint8
+	PCLK  uint8
+	Data  *PortPlan
+}
+
+/*
+ * @brief = Capture program of a line length and the register values of its state machine.
+ * @element Program = Instructions, loaded at address 0.
+ * @elements WrapTarget, Wrap = Loop of the program, it starts over at WrapTarget after Wrap.
+ * @elements ClockDivider, ExecCtrl, ShiftCtrl, PinCtrl = Values of the SMx_CLKDIV, SMx_EXECCTRL, SMx_SHIFTCTRL and SMx_PINCTRL registers.
+ * @element LineBytes = Bytes clocked per line.
+ * @element InBits = Bits sampled per byte, 8 (D[7:0] from their first GPIO) or 32 (the whole input register).
+ * @element BytesPerWord = Bytes packed in a word of the RX FIFO, 4 for consecutive pins, 1 when the whole register is sampled.
+ * @element LineWords = Words DMA moves per line.
+ * @element Pins = GPIOs of the capture.
+ */
+type PIOCapture struct {
+	Program      []uint16
+	WrapTarget   uint8
+	Wrap         uint8
+	ClockDivider uint32
+	ExecCtrl     uint32
+	ShiftCtrl    uint32
+	PinCtrl      uint32
+	LineBytes    int
+	InBits       uint8
+	BytesPerWord int
+	LineWords    int
+	Pins         PIOPins
+}
+
+// & Addresses of the capture program.
+const (
+	pio_addr_pull = iota
+	pio_addr_line
+	pio_addr_href_low
+	pio_addr_href_high
+	pio_addr_pclk_high
+	pio_addr_in
+	pio_addr_pclk_low
+	pio_addr_loop
+)
+
+/*
+ * @brief = Generates the capture program and its state machine configuration.
+ * @param pins = GPIOs of the capture.
+ * @param line_bytes = Bytes clocked per line.
+ * @return = pointer to the capture or an error if a pin is outside the GPIOs or there is no data plan.
+ */
+func CreatePIOCapture(pins PIOPins, line_bytes int) (*PIOCapture, error) {
+	if pins.Data == nil {
+		return nil, fmt.Errorf("PIO capture needs the PortPlan of the data pins.")
+	}
+	if pins.VSync >= PORT_WIDTH || pins.HREF >= PORT_WIDTH || pins.PCLK >= PORT_WIDTH {
+		return nil, fmt.Errorf("PIO capture pins have to be GPIO0 - GPIO%d.", PORT_WIDTH-1)
+	}
+	if line_bytes <= 0 {
+		return nil, fmt.Errorf("Not a valid line length for PIO capture. Value = %d", line_bytes)
+	}
+
+	capture := &PIOCapture{LineBytes: line_bytes, Pins: pins, ClockDivider: 1 << PIO_CLKDIV_INT_Pos}
+
+	// Consecutive pins pack 4 bytes a word when the line is a whole number of words, else 1 byte a word.
+	in_base, push_bits := uint8(0), uint32(32)
+	capture.InBits, capture.BytesPerWord = 32, 1
+	if pins.Data.Contiguous && len(pins.Data.Pins) == 8 {
+		capture.InBits, in_base = 8, pins.Data.Shift
+		if line_bytes%4 == 0 {
+			capture.BytesPerWord = 4
+		} else {
+			push_bits = 8
+		}
+	}
+	capture.LineWords = line_bytes / capture.BytesPerWord
+
+	capture.Program = []uint16{
+		pio_pull | pio_block,                // OSR = LineBytes - 1, written once when the capture starts.
+		pio_mov | pio_mov_x | pio_mov_osr,   // X counts the bytes of the line.
+		pio_wait | 0<<7 | uint16(pins.HREF), // A line starts on the rising edge of HREF.
+		pio_wait | 1<<7 | uint16(pins.HREF),
+		pio_wait | 1<<7 | uint16(pins.PCLK),                // The byte is valid on the rising edge of PCLK.
+		pio_in | pio_in_pins | uint16(capture.InBits&0x1F), // Autopush hands full words to the RX FIFO.
+		pio_wait | 0<<7 | uint16(pins.PCLK),
+		pio_jmp | pio_jmp_x_dec | uint16(pio_addr_pclk_high),
+	}
+	capture.WrapTarget, capture.Wrap = pio_addr_line, pio_addr_loop
+
+	capture.ExecCtrl = uint32(capture.Wrap)<<PIO_EXECCTRL_WRAP_TOP_Pos | uint32(capture.WrapTarget)<<PIO_EXECCTRL_WRAP_BOTTOM_Pos
+	capture.ShiftCtrl = PIO_SHIFTCTRL_AUTOPUSH | PIO_SHIFTCTRL_IN_SHIFTDIR | PIO_SHIFTCTRL_OUT_SHIFTDIR | (push_bits&0x1F)<<PIO_SHIFTCTRL_PUSH_THRESH_Pos
+	capture.PinCtrl = uint32(in_base) << PIO_PINCTRL_IN_BASE_Pos
+
+	return capture, nil
+}
+
+/*
+ * @brief = Names the signal the state machine waits on at an address of the program.
+ * @param pc = Program counter of the state machine.
+ * @return = Signal and level waited for, PCLK high while it is busy with a byte.
+ */
+func (c *PIOCapture) Signal(pc uint8) (SIGNAL, bool) {
+	switch pc {
+	case pio_addr_href_low:
+		return SIGNAL_HREF, false
+	case pio_addr_pull, pio_addr_line, pio_addr_href_high:
+		return SIGNAL_HREF, true
+	case pio_addr_pclk_low:
+		return SIGNAL_PCLK, false
+	}
+	return SIGNAL_PCLK, true
+}
+
+/*
+ * @brief = Turns the words DMA moved into the bytes of a line.
+ * @param words = LineWords words of a line.
+ * @param line = Destination, every stride-th clocked byte is kept till it is full.
+ * @param stride = Clocked bytes per kept byte.
+ */
+func (c *PIOCapture) Unpack(words []uint32, line []byte, stride int) {
+	for index := 0; index < len(line) && index*stride < c.LineBytes; index++ {
+		clocked := index * stride
+		switch {
+		case c.BytesPerWord == 4:
+			line[index] = uint8(words[clocked/4] >> (8 * (clocked % 4)))
+		case c.InBits == 8:
+			line[index] = uint8(words[clocked] >> 24)
+		default:
+			line[index] = c.Pins.Data.Remap(words[clocked])
+		}
+	}
+}
+
+/*
+ * @brief = Describes a DMA channel: what it copies where and its CTRL value.
+ * @elements Read, Write = Bus addresses.
+ * @element Count = Transfers (words).
+ * @element Ctrl = Value of the CTRL register.
+ */
+type DMADescriptor struct {
+	Read  uint32
+	Write uint32
+	Count uint32
+	Ctrl  uint32
+}
+
+/*
+ * @brief = Descriptor of a channel moving words from the RX FIFO of a state machine to a buffer.
+ * @param fifo = Bus address of the RX FIFO.
+ * @param machine = State machine, selects the data request.
+ * @param buffer = Bus address of the line buffer.
+ * @param words = Words per line.
+ * @param chain = Channel started when this one is done, its own number to not chain.
+ * @return = The descriptor.
+ */
+func FIFODescriptor(fifo uint32, machine uint8, buffer uint32, words int, chain uint8) DMADescriptor {
+	return DMADescriptor{
+		Read:  fifo,
+		Write: buffer,
+		Count: uint32(words),
+		Ctrl:  DMA_CTRL_EN | DMA_CTRL_DATA_SIZE_WORD | DMA_CTRL_INCR_WRITE | uint32(chain&0x0F)<<DMA_CTRL_CHAIN_TO_Pos | uint32(DMA_DREQ_PIO0_RX0+machine)<<DMA_CTRL_TREQ_SEL_Pos,
+	}
+}
+
+/*
+ * @brief = CaptureBackend that samples with a PIO state machine and 2 ping pong DMA channels.
+ * ^ A channel only chains to the other one after the CPU read the line of the other one, a CPU that falls a line behind
+ * ^ stops the DMA instead of overwriting a line and gets an error.
+ * @element Hardware = PIO0 and DMA registers.
+ * @element Pins = GPIOs of the capture.
+ * @element Machine = State machine of PIO0 used, 0 - 3.
+ * @element Channels = DMA channels used, they must be distinct.
+ */
+type PIOBackend struct {
+	Hardware PIOHardware
+	Pins     PIOPins
+	Machine  uint8
+	Channels [2]uint8
+
+	capture *PIOCapture
+	buffers [2][]uint32
+	line    int
+	pulsed  bool // The VSync pulse that started the frame is over.
+}
+
+/*
+* @brief = Creates a PIO capture backend.
+* @params hardware, pins, machine, channels = See PIOBackend.
+* @return = pointer to the backend or an error if the pins, the machine or the channels are not valid.
+! Handle Error.
+*/
+func CreatePIOBackend(hardware PIOHardware, pins PIOPins, machine uint8, channels [2]uint8) (*PIOBackend, error) {
+	if machine > 3 {
+		return nil, fmt.Errorf("PIO has state machines 0 - 3. Value = %d", machine)
+	}
+	if channels[0] == channels[1] || channels[0] >= DMA_CHANNELS || channels[1] >= DMA_CHANNELS {
+		return nil, fmt.Errorf("PIO capture needs 2 distinct DMA channels 0 - %d. Value = %v", DMA_CHANNELS-1, channels)
+	}
+	if _, err := CreatePIOCapture(pins, 1); err != nil {
+		return nil, err
+	}
+	return &PIOBackend{Hardware: hardware, Pins: pins, Machine: machine, Channels: channels}, nil
+}
+
+// & OneLine Brief = Offset of a register of the state machine, given as the offset of state machine 0.
+func (b *PIOBackend) sm(offset uint32) uint32 {
+	return offset + uint32(b.Machine)*PIO_SM_STRIDE
+}
+
+// & OneLine Brief = Offset of a register of a DMA channel.
+func dma_channel(channel uint8, offset uint32) uint32 {
+	return uint32(channel)*DMA_CHANNEL_STRIDE + offset
+}
+
+// & OneLine Brief = Loads the program of a line length and its state machine configuration.
+func (b *PIOBackend) load(line_bytes int) error {
+	capture, err := CreatePIOCapture(b.Pins, line_bytes)
+	if err != nil {
+		return err
+	}
+
+	b.stop()
+	for address, instruction := range capture.Program {
+		b.Hardware.WritePIO(PIO_INSTR_MEM0+uint32(address)*4, uint32(instruction))
+	}
+	b.Hardware.WritePIO(b.sm(PIO_SM0_CLKDIV), capture.ClockDivider)
+	b.Hardware.WritePIO(b.sm(PIO_SM0_EXECCTRL), capture.ExecCtrl)
+	b.Hardware.WritePIO(b.sm(PIO_SM0_SHIFTCTRL), capture.ShiftCtrl)
+	b.Hardware.WritePIO(b.sm(PIO_SM0_PINCTRL), capture.PinCtrl)
+
+	b.capture = capture
+	for index := range b.buffers {
+		b.buffers[index] = make([]uint32, capture.LineWords)
+	}
+	return nil
+}
+
+// & OneLine Brief = Descriptor of a channel of the ping pong pair.
+func (b *PIOBackend) descriptor(index int, chain uint8) DMADescriptor {
+	fifo := b.Hardware.PIOAddress(PIO_RXF0 + uint32(b.Machine)*4)
+	return FIFODescriptor(fifo, b.Machine, b.Hardware.Address(b.buffers[index]), b.capture.LineWords, chain)
+}
+
+// & OneLine Brief = Stops the state machine and the DMA channels.
+func (b *PIOBackend) stop() {
+	b.Hardware.WritePIO(PIO_CTRL, b.Hardware.ReadPIO(PIO_CTRL)&^(PIO_CTRL_SM_ENABLE<<b.Machine))
+	b.Hardware.WriteDMA(DMA_CHAN_ABORT, 1<<b.Channels[0]|1<<b.Channels[1])
+	for _, channel := range b.Channels {
+		for b.Hardware.ReadDMA(dma_channel(channel, DMA_CTRL_TRIG))&DMA_CTRL_BUSY != 0 {
+		}
+	}
+	b.Hardware.WriteDMA(DMA_INTR, 1<<b.Channels[0]|1<<b.Channels[1])
+}
+
+/*
+ * @brief = Restarts the state machine and the DMA for a new frame.
+ * ^ Channel 0 takes the first line and chains to channel 1, which does not chain till line 0 was read.
+ */
+func (b *PIOBackend) arm() {
+	// Toggling FJOIN_RX twice empties both FIFOs.
+	shift := b.capture.ShiftCtrl
+	b.Hardware.WritePIO(b.sm(PIO_SM0_SHIFTCTRL), shift^PIO_SHIFTCTRL_FJOIN_RX)
+	b.Hardware.WritePIO(b.sm(PIO_SM0_SHIFTCTRL), shift)
+	b.Hardware.WritePIO(PIO_FDEBUG, PIO_FDEBUG_RXSTALL<<b.Machine)
+	b.Hardware.WritePIO(PIO_CTRL, b.Hardware.ReadPIO(PIO_CTRL)|(PIO_CTRL_SM_RESTART|PIO_CTRL_CLKDIV_RESTART)<<b.Machine)
+	b.Hardware.WritePIO(b.sm(PIO_SM0_INSTR), pio_jmp|pio_addr_pull)
+	b.Hardware.WritePIO(PIO_TXF0+uint32(b.Machine)*4, uint32(b.capture.LineBytes-1))
+
+	for index, channel := range b.Channels {
+		chain := b.Channels[1]
+		if index == 1 {
+			chain = channel
+		}
+		descriptor := b.descriptor(index, chain)
+		b.Hardware.WriteDMA(dma_channel(channel, DMA_READ_ADDR), descriptor.Read)
+		b.Hardware.WriteDMA(dma_channel(channel, DMA_WRITE_ADDR), descriptor.Write)
+		b.Hardware.WriteDMA(dma_channel(channel, DMA_TRANS_COUNT), descriptor.Count)
+		if index == 0 {
+			b.Hardware.WriteDMA(dma_channel(channel, DMA_CTRL_TRIG), descriptor.Ctrl)
+		} else {
+			b.Hardware.WriteDMA(dma_channel(channel, DMA_AL1_CTRL), descriptor.Ctrl)
+		}
+	}
+
+	b.line, b.pulsed = 0, false
+	b.Hardware.WritePIO(PIO_CTRL, b.Hardware.ReadPIO(PIO_CTRL)|PIO_CTRL_SM_ENABLE<<b.Machine)
+}
+
+func (b *PIOBackend) StartFrame(ctx context.Context, Cam *OV7670, lineBytes int) error {
+	if b.capture == nil || b.capture.LineBytes != lineBytes {
+		if err := b.load(lineBytes); err != nil {
+			return err
+		}
+	}
+	b.stop()
+
+	if err := Cam.WaitForNewFrameContext(ctx); err != nil {
+		return err
+	}
+	b.arm()
+	return nil
+}
+
+// & OneLine Brief = Reports whether a channel finished a line since its flag was cleared.
+func (b *PIOBackend) done(channel uint8) bool {
+	return b.Hardware.ReadDMA(DMA_INTR)&(1<<channel) != 0
+}
+
+func (b *PIOBackend) ReadLine(ctx context.Context, Cam *OV7670, line []byte, stride int) (int, error) {
+	index := b.line % 2
+	channel, other := b.Channels[index], b.Channels[1-index]
+
+	for polls := 1; !b.done(channel); polls++ {
+		// VSync is still high from the start of the frame while the first lines are waited for.
+		if vsync := Cam.VSync.Get(); !vsync {
+			b.pulsed = true
+		} else if b.pulsed {
+			return 0, ErrFrameEnded
+		}
+		if polls%DEADLINE_POLLS == 0 {
+			sig, level := b.capture.Signal(uint8(b.Hardware.ReadPIO(b.sm(PIO_SM0_ADDR))))
+			if err := Expired(ctx, sig, level); err != nil {
+				left := int(b.Hardware.ReadDMA(dma_channel(channel, DMA_TRANS_COUNT)))
+				return (b.capture.LineWords - left) * b.capture.BytesPerWord, err
+			}
+		}
+	}
+	b.Hardware.WriteDMA(DMA_INTR, 1<<channel)
+	if b.Hardware.ReadPIO(PIO_FDEBUG)&(PIO_FDEBUG_RXSTALL<<b.Machine) != 0 || b.done(other) {
+		return b.capture.LineBytes, fmt.Errorf("Line %d was not read before the PIO capture needed its buffer, lines were lost.", b.line)
+	}
+	b.capture.Unpack(b.buffers[index], line, stride)
+
+	// The channel gets its buffer back, then the other channel may chain to it.
+	b.Hardware.WriteDMA(dma_channel(channel, DMA_WRITE_ADDR), b.Hardware.Address(b.buffers[index]))
+	b.Hardware.WriteDMA(dma_channel(channel, DMA_AL1_CTRL), b.descriptor(index, channel).Ctrl)
+	b.Hardware.WriteDMA(dma_channel(other, DMA_AL1_CTRL), b.descriptor(1-index, channel).Ctrl)
+	if b.done(other) && b.Hardware.ReadDMA(dma_channel(channel, DMA_CTRL_TRIG))&DMA_CTRL_BUSY == 0 {
+		return b.capture.LineBytes, fmt.Errorf("Line %d ended before the line after it had a buffer, lines were lost.", b.line+1)
+	}
+
+	b.line++
+	return min(len(line)*stride, b.capture.LineBytes), nil
+}
+
+func (b *PIOBackend) EndFrame(Cam *OV7670) {
+	b.stop()
+}
@@ -0,0 +1,63 @@
+//go:build tinygo && rp2040
+
+package Camera7670
+
+import (
+	"device/rp"
+	"runtime/volatile"
+	"unsafe"
+)
+
+/*
+~ File Description:
+^ PIOHardware of the RP2040: PIO0 and the DMA reached through their registers.
+*/
+
+// & Compile time check that the RP2040 registers are a PIOHardware.
+var _ PIOHardware = RP2040PIO{}
+
+// & Bits of PIO0 and the DMA in the RESETS registers.
+const (
+	resets_dma  = 1 << 2
+	resets_pio0 = 1 << 10
+)
+
+// & PIOHardware using PIO0 and the DMA of the RP2040.
+type RP2040PIO struct{}
+
+// & OneLine Brief = Takes PIO0 and the DMA out of reset and returns their PIOHardware.
+func CreateRP2040PIO() RP2040PIO {
+	rp.RESETS.RESET.ClearBits(resets_dma | resets_pio0)
+	for !rp.RESETS.RESET_DONE.HasBits(resets_dma | resets_pio0) {
+	}
+	return RP2040PIO{}
+}
+
+// & OneLine Brief = Register at offset of a peripheral.
+func peripheral_register(base unsafe.Pointer, offset uint32) *volatile.Register32 {
+	return (*volatile.Register32)(unsafe.Add(base, offset))
+}
+
+func (RP2040PIO) ReadPIO(offset uint32) uint32 {
+	return peripheral_register(unsafe.Pointer(rp.PIO0), offset).Get()
+}
+
+func (RP2040PIO) WritePIO(offset uint32, value uint32) {
+	peripheral_register(unsafe.Pointer(rp.PIO0), offset).Set(value)
+}
+
+func (RP2040PIO) ReadDMA(offset uint32) uint32 {
+	return peripheral_register(unsafe.Pointer(rp.DMA), offset).Get()
+}
+
+func (RP2040PIO) WriteDMA(offset uint32, value uint32) {
+	peripheral_register(unsafe.Pointer(rp.DMA), offset).Set(value)
+}
+
+func (RP2040PIO) PIOAddress(offset uint32) uint32 {
+	return uint32(uintptr(unsafe.Pointer(rp.PIO0))) + offset
+}
+
+func (RP2040PIO) Address(buffer []uint32) uint32 {
+	return uint32(uintptr(unsafe.Pointer(unsafe.SliceData(buffer))))
+}
@@ -1,3 +1,5 @@
+//go:build tinygo
+
 package Camera7670
 
 import "machine"
@@ -7,24 +9,83 @@ import "machine"
 ^ Implements a Datatype that can manipulate and read 8 Pins at a time.
 ^ It can read the 8 Pin States and package it into a byte.
 ^ Also it can write to 8 Pins simultaneously with uint8 or byte as a argument.
+^ Reads go through a PortPlan: the GPIO input register is read once and remapped instead of calling Get on every Pin.
 */
 
 type PArray struct {
 	Pins []machine.Pin
 	Mode machine.PinMode
+	plan *PortPlan
 }
 
 func GeneratePArray(pins []machine.Pin, mode machine.PinMode) *PArray {
-	return &PArray{Pins: pins, Mode: mode}
+	pa := &PArray{Pins: pins, Mode: mode}
+	pa.plan_port()
+	return pa
+}
+
+/*
+* @brief = Creates a PArray after checking its pins, see Validate.
+* @param pins = Data pins, pins[i] is D[i].
+* @param mode = Mode the pins are configured with by Init.
+* @param others = Pins of the other signals (VSync, HREF, PCLK, MCLK, SDA, SCL ...) that must not be data pins.
+* @return = pointer to the PArray or the error of Validate.
+! Handle Error.
+*/
+func CreatePArray(pins []machine.Pin, mode machine.PinMode, others ...PinUse) (*PArray, error) {
+	pa := GeneratePArray(pins, mode)
+	if err := pa.Validate(others...); err != nil {
+		return nil, err
+	}
+	return pa, nil
+}
+
+// & OneLine Brief = Names a pin for the conflict check of Validate.
+func UsePin(name string, pin machine.Pin) PinUse {
+	return PinUse{Name: name, GPIO: uint8(pin)}
+}
+
+/*
+* @brief = Checks that the PArray has exactly 8 distinct pins and shares none with the other signals.
+* @param others = Pins of the other signals, made with UsePin.
+* @return = An error if there are not 8 pins, or the *PinConflictError of the first pin used twice.
+! Handle Error.
+*/
+func (pa *PArray) Validate(others ...PinUse) error {
+	return CheckDataPins(pa.gpios(), others...)
+}
+
+// & OneLine Brief = GPIO numbers of the Pins.
+func (pa *PArray) gpios() []uint8 {
+	gpios := make([]uint8, len(pa.Pins))
+	for index, item := range pa.Pins {
+		gpios[index] = uint8(item)
+	}
+	return gpios
+}
+
+// & OneLine Brief = Builds the PortPlan of the Pins, Read falls back to a Get per Pin without one.
+func (pa *PArray) plan_port() {
+	pa.plan, _ = CreatePortPlan(pa.gpios())
 }
 
 func (pa *PArray) Init() {
 	for _, item := range pa.Pins {
 		item.Configure(machine.PinConfig{Mode: pa.Mode})
 	}
+	pa.plan_port()
+}
+
+// & OneLine Brief = Returns the PortPlan used by Read, nil if the Pins are read one by one.
+func (pa *PArray) Plan() *PortPlan {
+	return pa.plan
 }
 
 func (pa *PArray) Read() uint8 {
+	if pa.plan != nil {
+		return pa.plan.Remap(gpio_input(pa.plan))
+	}
+
 	var result uint8 = 0
 	for index, item := range pa.Pins {
 		if item.Get() {
@@ -36,7 +97,7 @@ func (pa *PArray) Read() uint8 {
 }
 
 func (pa *PArray) Write(data uint8) {
-	for i := 0; i < 8; i++ {
+	for i := 0; i < min(len(pa.Pins), 8); i++ {
 		if data&(1<<i) != 0 {
 			pa.Pins[i].High()
 		} else {
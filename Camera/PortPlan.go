@@ -0,0 +1,130 @@
+package Camera7670
+
+import "fmt"
+
+/*
+~ File Description:
+^ Turns one read of a 32 bit GPIO input register into the byte on D[7:0], whatever GPIOs the data pins are wired to.
+^ Data pins on consecutive GPIOs in order are a shift and mask, any other wiring looks every byte of the register up in a table.
+^ Kept free of the machine package so the remapping runs on a host against a fake register, PArray uses it on target.
+*/
+
+// & Bits of the GPIO input register.
+const PORT_WIDTH = 32
+
+// & Compile time check that RegisterPort is a data port.
+var _ DataPort = RegisterPort{}
+
+// & Lookup table of one byte of the register, the value of the byte gives the data bits it holds.
+type portLane struct {
+	shift uint8
+	table [256]uint8
+}
+
+/*
+ * @brief = Plan to read D[7:0] out of a GPIO input register.
+ * @element Pins = GPIO number of every data pin, Pins[i] is D[i].
+ * @element Mask = Bits of the register that hold a data pin.
+ * @element Contiguous = The pins are consecutive GPIOs in order, D = register >> Shift.
+ * @element Shift = GPIO of D[0].
+ */
+type PortPlan struct {
+	Pins       []uint8
+	Mask       uint32
+	Contiguous bool
+	Shift      uint8
+	lanes      []portLane
+}
+
+/*
+ * @brief = Creates the plan for a list of GPIO numbers.
+ * @param pins = GPIO number of every data pin, pins[i] is D[i], up to 8 pins below PORT_WIDTH.
+ * @return = pointer to the plan or an error if there are too many pins, one is outside the register or used twice.
+ */
+func CreatePortPlan(pins []uint8) (*PortPlan, error) {
+	if len(pins) == 0 || len(pins) > 8 {
+		return nil, fmt.Errorf("A data port has 1 to 8 pins, got %d.", len(pins))
+	}
+
+	plan := &PortPlan{Pins: append([]uint8(nil), pins...), Contiguous: true, Shift: pins[0]}
+	for index, pin := range pins {
+		if pin >= PORT_WIDTH {
+			return nil, fmt.Errorf("GPIO%d of D%d is outside the %d bit input register.", pin, index, PORT_WIDTH)
+		}
+		if plan.Mask&(1<<pin) != 0 {
+			return nil, fmt.Errorf("GPIO%d is wired to more than one data pin, again at D%d.", pin, index)
+		}
+		plan.Mask |= 1 << pin
+		if pin != pins[0]+uint8(index) {
+			plan.Contiguous = false
+		}
+	}
+	if plan.Contiguous {
+		return plan, nil
+	}
+
+	// One table for every byte of the register that holds a data pin.
+	for shift := uint8(0); shift < PORT_WIDTH; shift += 8 {
+		if plan.Mask>>shift&0xFF == 0 {
+			continue
+		}
+		lane := portLane{shift: shift}
+		for value := range lane.table {
+			for index, pin := range pins {
+				if pin >= shift && pin < shift+8 && value&(1<<(pin-shift)) != 0 {
+					lane.table[value] |= 1 << index
+				}
+			}
+		}
+		plan.lanes = append(plan.lanes, lane)
+	}
+
+	return plan, nil
+}
+
+/*
+ * @brief = Picks the data byte out of a read of the input register.
+ * @param register = Value of the GPIO input register.
+ * @return = Byte with bit i being D[i].
+ */
+func (plan *PortPlan) Remap(register uint32) uint8 {
+	if plan.Contiguous {
+		return uint8(register>>plan.Shift) & uint8(plan.Mask>>plan.Shift)
+	}
+
+	var data uint8
+	for index := range plan.lanes {
+		lane := &plan.lanes[index]
+		data |= lane.table[uint8(register>>lane.shift)]
+	}
+	return data
+}
+
+/*
+ * @brief = Inverse of Remap, spreads a data byte over the register bits of the pins.
+ * @param data = Byte with bit i being D[i].
+ * @return = Register value with only the data pins set.
+ */
+func (plan *PortPlan) Spread(data uint8) uint32 {
+	var register uint32
+	for index, pin := range plan.Pins {
+		if data&(1<<index) != 0 {
+			register |= 1 << pin
+		}
+	}
+	return register
+}
+
+/*
+ * @brief = DataPort that reads a whole input register at once and remaps it with a PortPlan.
+ * @element Plan = Plan of the data pins.
+ * @element Input = Reads the GPIO input register, a fake register on a host.
+ */
+type RegisterPort struct {
+	Plan  *PortPlan
+	Input func() uint32
+}
+
+func (port RegisterPort) Read() uint8 {
+	return port.Plan.Remap(port.Input())
+}
@@ -0,0 +1,64 @@
+package Camera7670
+
+import "testing"
+
+func TestPortPlanRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		pins       []uint8
+		contiguous bool
+	}{
+		{"contiguous", []uint8{2, 3, 4, 5, 6, 7, 8, 9}, true},
+		{"contiguous high", []uint8{24, 25, 26, 27, 28, 29, 30, 31}, true},
+		{"reversed", []uint8{9, 8, 7, 6, 5, 4, 3, 2}, false},
+		{"scattered", []uint8{0, 5, 6, 13, 14, 20, 27, 31}, false},
+		{"scattered in one byte", []uint8{7, 1, 4, 0, 6, 2, 5, 3}, false},
+		{"four pins", []uint8{16, 18, 3, 30}, false},
+	}
+
+	for _, test := range tests {
+		plan, err := CreatePortPlan(test.pins)
+		if err != nil {
+			t.Fatalf("%s: CreatePortPlan: %v", test.name, err)
+		}
+		if plan.Contiguous != test.contiguous {
+			t.Errorf("%s: Contiguous = %t, want %t", test.name, plan.Contiguous, test.contiguous)
+		}
+
+		data_mask := uint8(1<<len(test.pins) - 1)
+		for value := 0; value < 256; value++ {
+			b := uint8(value) & data_mask
+			register := plan.Spread(b)
+			if register&^plan.Mask != 0 {
+				t.Fatalf("%s: Spread(0x%02X) = 0x%08X sets bits outside the mask 0x%08X", test.name, b, register, plan.Mask)
+			}
+			if got := plan.Remap(register); got != b {
+				t.Fatalf("%s: Remap(Spread(0x%02X)) = 0x%02X", test.name, b, got)
+			}
+			// Other GPIOs of the register must not leak into the data.
+			if got := plan.Remap(register | ^plan.Mask); got != b {
+				t.Fatalf("%s: Remap with the other GPIOs high = 0x%02X, want 0x%02X", test.name, got, b)
+			}
+		}
+	}
+}
+
+func TestCreatePortPlanRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		pins []uint8
+	}{
+		{"no pins", nil},
+		{"nine pins", []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8}},
+		{"duplicate", []uint8{2, 3, 4, 5, 6, 7, 8, 2}},
+		{"duplicate neighbour", []uint8{10, 10}},
+		{"out of range", []uint8{26, 27, 28, 29, 30, 31, 32, 33}},
+		{"far out of range", []uint8{0, 255}},
+	}
+
+	for _, test := range tests {
+		if plan, err := CreatePortPlan(test.pins); err == nil {
+			t.Errorf("%s: CreatePortPlan(%v) = %+v, want an error", test.name, test.pins, plan)
+		}
+	}
+}
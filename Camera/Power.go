@@ -0,0 +1,215 @@
+package Camera7670
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+/*
+~ File Description:
+^ Power management: sleep through the PWDN pin or COM2 soft sleep, stopping MCLK and the hardware reset through the RESET pin.
+^ Every register written since the last reset is kept in a shadow so Wake can write the settings back, after a COM7 reset
+^ and in the order Configure writes them.
+*/
+
+// & Timing of the power pins.
+const (
+	RESET_PULSE    = time.Millisecond       // RESET held low.
+	RESET_SETTLE   = 100 * time.Millisecond // Before the first SCCB access after a reset, same as the COM7 reset.
+	POWER_UP_DELAY = 10 * time.Millisecond  // Between releasing PWDN and the first SCCB access.
+)
+
+type SLEEP_STATE int
+
+const (
+	SLEEP_AWAKE      = iota
+	SLEEP_SOFT       // COM2 soft sleep, SCCB and the registers stay alive.
+	SLEEP_POWER_DOWN // PWDN pin held high.
+)
+
+func (s SLEEP_STATE) String() string {
+	switch s {
+	case SLEEP_AWAKE:
+		return "AWAKE"
+	case SLEEP_SOFT:
+		return "SOFT SLEEP"
+	case SLEEP_POWER_DOWN:
+		return "POWER DOWN"
+	}
+
+	return "NOT VALID"
+}
+
+// & OneLine Brief = Keeps the last value written to a register in the shadow, a COM7 reset empties it.
+func (Cam *OV7670) record(reg, val uint8) {
+	if reg == REG_COM7 && ParseCOM7(val).Reset {
+		Cam.shadow = nil
+		return
+	}
+
+	for index := range Cam.shadow {
+		if Cam.shadow[index].Register == reg {
+			Cam.shadow[index].Value = val
+			return
+		}
+	}
+	Cam.shadow = append(Cam.shadow, RegisterWrite{reg, val, MASK_ALL, 0})
+}
+
+// & OneLine Brief = Returns a copy of every register written since the last reset with its last value, in first write order.
+func (Cam *OV7670) Shadow() RegisterSequence {
+	return append(RegisterSequence(nil), Cam.shadow...)
+}
+
+// & Steps of the Wake replay, in the order Initialize and Configure write their sequences.
+const (
+	replay_start_up = iota
+	replay_format
+	replay_resolution
+	replay_window
+	replay_tuning
+)
+
+// & Step of every register written by a sequence, the last sequence that writes a register decides.
+var replaySteps = func() map[uint8]int {
+	steps := map[uint8]int{}
+	add := func(step int, seq RegisterSequence) {
+		for _, write := range seq {
+			steps[write.Register] = step
+		}
+	}
+
+	add(replay_start_up, initializeSequence)
+	for _, seq := range colorSequences {
+		add(replay_format, seq)
+	}
+	for _, matrix := range colorMatrices {
+		add(replay_format, matrix.sequence())
+	}
+	add(replay_format, YUYV.sequence())
+	for _, seq := range resolutionSequences {
+		add(replay_resolution, seq)
+	}
+	_, _, custom := customSequence(SENSOR_WIDTH/3, SENSOR_HEIGHT/3)
+	add(replay_resolution, custom)
+	add(replay_window, windowSequence(frameFormats[VGA], VGA_WINDOW))
+
+	return steps
+}()
+
+// & OneLine Brief = Returns the step of the Wake replay a register is written in, registers of no sequence are tuning.
+func replay_step(reg uint8) int {
+	if step, ok := replaySteps[reg]; ok {
+		return step
+	}
+	return replay_tuning
+}
+
+/*
+ * @brief = Builds the writes that bring a reset sensor back to the shadow.
+ * ^ A COM7 reset comes first, then the shadow sorted into start-up, format, resolution, window and tuning,
+ * ^ so the window lands after the COM7 resolution change that presets it. Inside a step the first write order is kept.
+ * @return = Register sequence with the last value of every register.
+ */
+func (Cam *OV7670) replaySequence() RegisterSequence {
+	shadow := Cam.Shadow()
+	sort.SliceStable(shadow, func(i, j int) bool {
+		return replay_step(shadow[i].Register) < replay_step(shadow[j].Register)
+	})
+
+	return append(append(RegisterSequence(nil), resetSequence...), shadow...)
+}
+
+/*
+* @brief = Resets the sensor through the RESET pin, every register goes back to its power on value.
+* ^ Like Reset, the cached configuration and the shadow go back to the power on state too, Initialize has to follow.
+* @return = returns an error if the RESET pin is not wired or the camera sleeps.
+! Handle Error.
+*/
+func (Cam *OV7670) HardwareReset() error {
+	if Cam.RESET == nil {
+		return fmt.Errorf("No RESET pin wired, use Reset for the COM7 soft reset.")
+	}
+	if Cam.sleep != SLEEP_AWAKE {
+		return fmt.Errorf("The camera is in %s, Wake it before a reset.", Cam.sleep.String())
+	}
+
+	Cam.RESET.Set(false)
+	time.Sleep(RESET_PULSE)
+	Cam.RESET.Set(true)
+	time.Sleep(RESET_SETTLE)
+	Cam.resetState()
+
+	return nil
+}
+
+/*
+* @brief = Puts the camera to sleep, through the PWDN pin when wired or COM2 soft sleep.
+* @param stop_clock = Also stop MCLK, the sensor draws the least current but SCCB does not answer until Wake.
+* @return = *RegisterError of the soft sleep write or the error of the clock source.
+! Handle Error.
+*/
+func (Cam *OV7670) Sleep(stop_clock bool) error {
+	if Cam.sleep != SLEEP_AWAKE {
+		return fmt.Errorf("The camera is already in %s.", Cam.sleep.String())
+	}
+
+	if Cam.PWDN != nil {
+		Cam.PWDN.Set(true)
+		Cam.sleep = SLEEP_POWER_DOWN
+	} else {
+		if err := Cam.ApplySequence(RegisterSequence{{REG_COM2, COM2{SoftSleep: true}.Value(), 0x10, 0}}); err != nil {
+			return err
+		}
+		Cam.sleep = SLEEP_SOFT
+	}
+
+	if stop_clock {
+		if err := Cam.MCLK.Stop(); err != nil {
+			return err
+		}
+		Cam.mclkStopped = true
+	}
+
+	return nil
+}
+
+/*
+* @brief = Wakes the camera up, resets it and writes every register of the shadow back, the settings from before Sleep are restored.
+* ^ The shadow is replayed in the order of Configure, see replaySequence.
+* @return = The error of the clock source or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) Wake() error {
+	if Cam.sleep == SLEEP_AWAKE {
+		return nil
+	}
+
+	if Cam.mclkStopped {
+		if err := Cam.MCLK.Start(Cam.mclk); err != nil {
+			return err
+		}
+		Cam.mclkStopped = false
+	}
+	if Cam.sleep == SLEEP_POWER_DOWN {
+		Cam.PWDN.Set(false)
+		time.Sleep(POWER_UP_DELAY)
+	}
+
+	// The shadow holds the soft sleep bit, it is cleared after the restore.
+	if err := Cam.ApplySequence(Cam.replaySequence()); err != nil {
+		return fmt.Errorf("Failed to restore the registers: %w", err)
+	}
+	if err := Cam.ApplySequence(RegisterSequence{{REG_COM2, COM2{}.Value(), 0x10, 0}}); err != nil {
+		return err
+	}
+	Cam.sleep = SLEEP_AWAKE
+
+	return nil
+}
+
+// & OneLine Brief = Returns how the camera was put to sleep, SLEEP_AWAKE while it runs.
+func (Cam *OV7670) SleepState() SLEEP_STATE {
+	return Cam.sleep
+}
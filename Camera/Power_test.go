@@ -0,0 +1,117 @@
+package Camera7670
+
+import "testing"
+
+// & Output pin that remembers its level.
+type fakeOutput struct{ high bool }
+
+func (p *fakeOutput) Set(high bool) { p.high = high }
+
+// & OneLine Brief = Index of the last write of a register, -1 when it was not written.
+func (b *fakeBus) lastWrite(reg uint8) int {
+	for index := len(b.writes) - 1; index >= 0; index-- {
+		if b.writes[index] == reg {
+			return index
+		}
+	}
+	return -1
+}
+
+// & OneLine Brief = Index of the first write of a register, -1 when it was not written.
+func (b *fakeBus) firstWrite(reg uint8) int {
+	for index, written := range b.writes {
+		if written == reg {
+			return index
+		}
+	}
+	return -1
+}
+
+func TestWakeReplaysInConfigureOrder(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	// Tuning first, so its first write comes before the format and resolution writes.
+	if err := Cam.SetBrightness(20); err != nil {
+		t.Fatalf("SetBrightness: %v", err)
+	}
+	if err := Cam.Configure(RGB, QVGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := Cam.SetWindow(10, 10, 100, 80); err != nil {
+		t.Fatalf("SetWindow: %v", err)
+	}
+	want := bus.registers
+
+	if err := Cam.Sleep(false); err != nil {
+		t.Fatalf("Sleep: %v", err)
+	}
+	// Lose every register, as a power cycle would.
+	identity := newFakeBus()
+	bus.registers, bus.writes = identity.registers, nil
+	if err := Cam.Wake(); err != nil {
+		t.Fatalf("Wake: %v", err)
+	}
+
+	if len(bus.writes) == 0 || bus.writes[0] != REG_COM7 || bus.firstWrite(REG_COM7) != 0 {
+		t.Fatalf("Wake did not start with the COM7 reset")
+	}
+	window := []uint8{REG_HSTART, REG_HSTOP, REG_HREF, REG_VSTRT, REG_VSTOP, REG_VREF}
+	for _, reg := range window {
+		if bus.firstWrite(reg) < bus.lastWrite(REG_COM7) || bus.firstWrite(reg) < bus.lastWrite(REG_COM15) {
+			t.Errorf("%s was written before the format and resolution", RegisterName(reg))
+		}
+		if bus.lastWrite(REG_BRIGHT) < bus.lastWrite(reg) {
+			t.Errorf("BRIGHT was written before the window register %s", RegisterName(reg))
+		}
+	}
+	for reg := range want {
+		if got := bus.registers[reg]; got != want[reg] && uint8(reg) != REG_COM2 {
+			t.Errorf("%s = 0x%02X after Wake, want 0x%02X", RegisterName(uint8(reg)), got, want[reg])
+		}
+	}
+	if Cam.Window() != (Window{X: 10, Y: 10, Width: 100, Height: 80}) || Cam.SleepState() != SLEEP_AWAKE {
+		t.Errorf("state after Wake = window %+v, %s", Cam.Window(), Cam.SleepState())
+	}
+}
+
+func TestHardwareResetClearsState(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	Cam.RESET = &fakeOutput{high: true}
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := Cam.Configure(RGB, QVGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := Cam.SetSaturationHue(150, 30); err != nil {
+		t.Fatalf("SetSaturationHue: %v", err)
+	}
+
+	if err := Cam.Sleep(false); err != nil {
+		t.Fatalf("Sleep: %v", err)
+	}
+	if err := Cam.HardwareReset(); err == nil {
+		t.Errorf("HardwareReset of a sleeping camera = nil, want an error")
+	}
+	if err := Cam.Wake(); err != nil {
+		t.Fatalf("Wake: %v", err)
+	}
+
+	if err := Cam.HardwareReset(); err != nil {
+		t.Fatalf("HardwareReset: %v", err)
+	}
+	if !Cam.RESET.(*fakeOutput).high {
+		t.Errorf("RESET left low after the pulse")
+	}
+	saturation, hue := Cam.SaturationHue()
+	if Cam.ImageType() != GREYSCALED || Cam.Resolution() != VGA || Cam.Window() != VGA_WINDOW || saturation != 100 || hue != 0 {
+		t.Errorf("state after HardwareReset = %s %s %+v %d%% %d, want the power on state", Cam.ImageType(), Cam.Resolution(), Cam.Window(), saturation, hue)
+	}
+	if len(Cam.Shadow()) != 0 {
+		t.Errorf("shadow holds %d registers after HardwareReset, want none", len(Cam.Shadow()))
+	}
+}
@@ -0,0 +1,347 @@
+package Camera7670
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*
+~ File Description:
+^ Named scene presets bundling format, resolution, clock, exposure, gamma and colour matrix settings.
+^ The built-in presets are registered at start up, more can be registered from code or loaded from JSON.
+^ In JSON the enums are written by name, for eg. "Format": "GREYSCALED", "Resolution": "QVGA".
+*/
+
+// & Names of the built-in presets.
+const (
+	PRESET_DOCUMENT        = "document"
+	PRESET_LOW_LIGHT       = "low-light"
+	PRESET_OUTDOOR         = "outdoor"
+	PRESET_HIGH_SPEED_MONO = "high-speed-mono"
+)
+
+/*
+ * @brief = A named set of camera settings applied by ApplyPreset, start from NewPreset to get neutral values.
+ * @element Name = Name the preset is registered under.
+ * @elements Format, Resolution = Passed to Configure.
+ * @elements Width, Height = Size for a CUSTOM Resolution, passed to ConfigureCustom.
+ * @element PCLK = Clock divider passed to SetPCLKSpeed, 0 keeps the one of the resolution.
+ * @element FrameRate = Frames per second passed to SetFrameRate, 0 keeps the clock of PCLK.
+ * @element Banding = Banding filter and night mode.
+ * @elements Exposure, Gain = Manual exposure and gain, 0 leaves them to AEC and AGC.
+ * @element AutoWhiteBalance = Let AWB set the colour channel gains.
+ * @elements Brightness, Contrast = See SetBrightness and SetContrast.
+ * @elements Saturation, Hue = Colour matrix adjustment, see SetSaturationHue.
+ * @element Gamma = See SetGamma, 0 keeps the gamma curve.
+ * @element Registers = Extra register writes applied last.
+ */
+type Preset struct {
+	Name             string
+	Format           IMAGE
+	Resolution       RESOLUTION
+	Width            int `json:",omitempty"`
+	Height           int `json:",omitempty"`
+	PCLK             PCLK_DIVIDER
+	FrameRate        float64
+	Banding          BandingOptions
+	Exposure         uint16
+	Gain             uint16
+	AutoWhiteBalance bool
+	Brightness       int
+	Contrast         int
+	Saturation       int
+	Hue              int
+	Gamma            float64
+	Registers        RegisterSequence `json:",omitempty"`
+}
+
+/*
+ * @brief = Creates a preset with neutral settings: GREYSCALED QVGA, automatic exposure, gain and white balance, no tuning.
+ * @param name = Name of the preset.
+ * @return = The preset, change the fields you need.
+ */
+func NewPreset(name string) Preset {
+	return Preset{Name: name, Format: GREYSCALED, Resolution: QVGA, AutoWhiteBalance: true, Contrast: 100, Saturation: 100}
+}
+
+// & OneLine Brief = Checks every field against the range of the setter it goes to.
+func (p Preset) Validate() error {
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("Preset needs a name.")
+	case p.Format.String() == "NOT VALID" || p.Resolution.String() == "NOT VALID":
+		return fmt.Errorf("Preset %s: invalid format %s or resolution %s.", p.Name, p.Format.String(), p.Resolution.String())
+	case p.Resolution == CUSTOM && (p.Width <= 0 || p.Height <= 0 || p.Width > SENSOR_WIDTH || p.Height > SENSOR_HEIGHT):
+		return fmt.Errorf("Preset %s: custom size %dx%d is outside 1x1 to %dx%d.", p.Name, p.Width, p.Height, SENSOR_WIDTH, SENSOR_HEIGHT)
+	case p.PCLK != 0 && p.PCLK.String() == "NOT VALID":
+		return fmt.Errorf("Preset %s: invalid PCLK divider %d.", p.Name, p.PCLK)
+	case p.FrameRate < 0:
+		return fmt.Errorf("Preset %s: frame rate %.2f is below 0.", p.Name, p.FrameRate)
+	case p.Banding.Filter.String() == "NOT VALID" || p.Banding.Night.String() == "NOT VALID":
+		return fmt.Errorf("Preset %s: invalid banding %s or night mode %s.", p.Name, p.Banding.Filter.String(), p.Banding.Night.String())
+	case p.Gain > MAX_GAIN:
+		return fmt.Errorf("Preset %s: gain 0x%03X is larger than 0x%03X.", p.Name, p.Gain, MAX_GAIN)
+	case p.Brightness < MIN_BRIGHTNESS || p.Brightness > MAX_BRIGHTNESS:
+		return fmt.Errorf("Preset %s: brightness %d is outside %d to %d.", p.Name, p.Brightness, MIN_BRIGHTNESS, MAX_BRIGHTNESS)
+	case p.Contrast < 0 || p.Contrast > MAX_CONTRAST:
+		return fmt.Errorf("Preset %s: contrast %d%% is outside 0 to %d%%.", p.Name, p.Contrast, MAX_CONTRAST)
+	case p.Saturation < 0 || p.Saturation > MAX_SATURATION || p.Hue < -MAX_HUE || p.Hue > MAX_HUE:
+		return fmt.Errorf("Preset %s: saturation %d%% or hue %d is outside 0 to %d%% and -%d to %d.", p.Name, p.Saturation, p.Hue, MAX_SATURATION, MAX_HUE, MAX_HUE)
+	case p.Gamma != 0 && (p.Gamma < MIN_GAMMA || p.Gamma > MAX_GAMMA):
+		return fmt.Errorf("Preset %s: gamma %.2f is outside %.2f to %.2f.", p.Name, p.Gamma, MIN_GAMMA, MAX_GAMMA)
+	}
+
+	return nil
+}
+
+// & Registered presets by name.
+var presets = map[string]Preset{}
+
+func init() {
+	document := NewPreset(PRESET_DOCUMENT)
+	document.Contrast = 160
+	document.Brightness = 16
+	document.Saturation = 0
+	document.Gamma = 1.0
+	document.Banding = BandingOptions{Filter: BANDING_AUTO}
+
+	low_light := NewPreset(PRESET_LOW_LIGHT)
+	low_light.Format = YUV
+	low_light.Banding = BandingOptions{Filter: BANDING_AUTO, Night: NIGHT_MODE_QUARTER_RATE}
+	low_light.Brightness = 24
+	low_light.Saturation = 80
+	low_light.Gamma = 2.2
+
+	outdoor := NewPreset(PRESET_OUTDOOR)
+	outdoor.Format = RGB
+	outdoor.Banding = BandingOptions{ExposureBelowBand: true}
+	outdoor.Contrast = 110
+	outdoor.Saturation = 120
+	outdoor.Gamma = 1.6
+
+	high_speed := NewPreset(PRESET_HIGH_SPEED_MONO)
+	high_speed.Resolution = QQVGA
+	high_speed.FrameRate = 15
+	high_speed.Exposure = 0x0100
+	high_speed.Gain = 0x0040
+	high_speed.Contrast = 120
+	high_speed.Saturation = 0
+
+	for _, preset := range []Preset{document, low_light, outdoor, high_speed} {
+		presets[preset.Name] = preset
+	}
+}
+
+/*
+* @brief = Registers a preset under its name, a preset with the same name is replaced.
+* @param p = Preset to register.
+* @return = returns an error if a field is out of range.
+! Handle Error.
+*/
+func RegisterPreset(p Preset) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	presets[p.Name] = p
+
+	return nil
+}
+
+// & OneLine Brief = Returns the preset registered under name and whether there is one.
+func LookupPreset(name string) (Preset, bool) {
+	p, ok := presets[name]
+	return p, ok
+}
+
+// & OneLine Brief = Returns the names of all registered presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+* @brief = Registers the presets of a JSON array, fields left out keep the values of NewPreset.
+* @param r = Reader of the JSON, for eg. a file on the SD card.
+* @return = Names registered and an error if the JSON can not be read or a preset is not valid, presets before it stay registered.
+! Handle Error.
+*/
+func LoadPresets(r io.Reader) ([]string, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("Failed to read presets: %w", err)
+	}
+
+	var names []string
+	for index, data := range raw {
+		p := NewPreset("")
+		if err := json.Unmarshal(data, &p); err != nil {
+			return names, fmt.Errorf("Failed to read preset %d: %w", index, err)
+		}
+		if err := RegisterPreset(p); err != nil {
+			return names, err
+		}
+		names = append(names, p.Name)
+	}
+
+	return names, nil
+}
+
+/*
+* @brief = Writes presets as a JSON array that LoadPresets reads back.
+* @param w = Writer of the JSON.
+* @param list = Presets to write.
+* @return = The error of the writer.
+! Handle Error.
+*/
+func SavePresets(w io.Writer, list ...Preset) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+/*
+* @brief = Applies every setting of a preset, see Preset for the order of the fields.
+* @param p = Preset to apply.
+* @return = returns an error if the preset is not valid, or wraps the error of the setting that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) ApplyPreset(p Preset) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	var err error
+	if p.Resolution == CUSTOM {
+		err = Cam.ConfigureCustom(p.Format, p.Width, p.Height)
+	} else {
+		err = Cam.Configure(p.Format, p.Resolution)
+	}
+	if err != nil {
+		return fmt.Errorf("Preset %s: %w", p.Name, err)
+	}
+
+	steps := []func() error{
+		func() error {
+			if p.FrameRate > 0 {
+				_, err := Cam.SetFrameRate(p.FrameRate)
+				return err
+			}
+			if p.PCLK != 0 {
+				return Cam.SetPCLKSpeed(p.PCLK)
+			}
+			return nil
+		},
+		func() error {
+			_, err := Cam.SetBanding(p.Banding)
+			return err
+		},
+		func() error { return Cam.SetAutoControls(p.Exposure == 0, p.Gain == 0, p.AutoWhiteBalance) },
+		func() error {
+			if p.Exposure != 0 {
+				return Cam.SetExposure(p.Exposure)
+			}
+			return nil
+		},
+		func() error {
+			if p.Gain != 0 {
+				return Cam.SetGain(p.Gain)
+			}
+			return nil
+		},
+		func() error { return Cam.SetBrightness(p.Brightness) },
+		func() error { return Cam.SetContrast(p.Contrast) },
+		func() error { return Cam.SetSaturationHue(p.Saturation, p.Hue) },
+		func() error {
+			if p.Gamma != 0 {
+				return Cam.SetGamma(p.Gamma)
+			}
+			return nil
+		},
+		func() error { return Cam.ApplySequence(p.Registers) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return fmt.Errorf("Preset %s: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+* @brief = Applies the preset registered under name.
+* @param name = Name of a built-in or registered preset.
+* @return = returns an error if there is no such preset, or the error of ApplyPreset.
+! Handle Error.
+*/
+func (Cam *OV7670) UsePreset(name string) error {
+	p, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("No preset named %q.", name)
+	}
+	return Cam.ApplyPreset(p)
+}
+
+// & OneLine Brief = Finds the value of an enum by its name, used by the UnmarshalText of the enums.
+func parse_enum[T interface {
+	~int
+	String() string
+}](text []byte, limit int) (T, error) {
+	for value := 0; value < limit; value++ {
+		if T(value).String() == string(text) {
+			return T(value), nil
+		}
+	}
+
+	var zero T
+	return zero, fmt.Errorf("%q is not a valid %T.", text, zero)
+}
+
+// & Enums are written by name in presets.
+func (i IMAGE) MarshalText() ([]byte, error)      { return []byte(i.String()), nil }
+func (r RESOLUTION) MarshalText() ([]byte, error) { return []byte(r.String()), nil }
+func (b BANDING) MarshalText() ([]byte, error)    { return []byte(b.String()), nil }
+func (n NIGHT_MODE) MarshalText() ([]byte, error) { return []byte(n.String()), nil }
+
+func (i *IMAGE) UnmarshalText(text []byte) (err error) {
+	*i, err = parse_enum[IMAGE](text, RGB444_RGBX+1)
+	return err
+}
+
+func (r *RESOLUTION) UnmarshalText(text []byte) (err error) {
+	*r, err = parse_enum[RESOLUTION](text, CUSTOM+1)
+	return err
+}
+
+func (b *BANDING) UnmarshalText(text []byte) (err error) {
+	*b, err = parse_enum[BANDING](text, BANDING_AUTO+1)
+	return err
+}
+
+func (n *NIGHT_MODE) UnmarshalText(text []byte) (err error) {
+	*n, err = parse_enum[NIGHT_MODE](text, NIGHT_MODE_EIGHTH_RATE+1)
+	return err
+}
+
+// & PCLK 0 (keep the divider of the resolution) is written as "KEEP".
+func (d PCLK_DIVIDER) MarshalText() ([]byte, error) {
+	if d == 0 {
+		return []byte("KEEP"), nil
+	}
+	return []byte(d.String()), nil
+}
+
+func (d *PCLK_DIVIDER) UnmarshalText(text []byte) (err error) {
+	if string(text) == "KEEP" {
+		*d = 0
+		return nil
+	}
+	*d, err = parse_enum[PCLK_DIVIDER](text, PCLK_DIV4+1)
+	return err
+}
@@ -0,0 +1,138 @@
+package Camera7670
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPresetsRoundTrip(t *testing.T) {
+	custom := NewPreset("test-round-trip")
+	custom.Format = RGB555
+	custom.Resolution = CUSTOM
+	custom.Width, custom.Height = 200, 150
+	custom.PCLK = PCLK_DIV2
+	custom.Banding = BandingOptions{Filter: BANDING_60HZ, Night: NIGHT_MODE_HALF_RATE}
+	custom.Gain = 0x0200
+	custom.Registers = RegisterSequence{{REG_COM16, 0x08, 0x08, 0}}
+	keep := NewPreset("test-keep")
+
+	var buf bytes.Buffer
+	if err := SavePresets(&buf, custom, keep); err != nil {
+		t.Fatalf("SavePresets: %v", err)
+	}
+	for _, text := range []string{`"Format": "RGB555"`, `"Resolution": "CUSTOM"`, `"PCLK": "KEEP"`, `"Filter": "60HZ"`, `"Night": "HALF RATE"`} {
+		if !strings.Contains(buf.String(), text) {
+			t.Errorf("saved presets have no %s:\n%s", text, buf.String())
+		}
+	}
+
+	names, err := LoadPresets(&buf)
+	if err != nil {
+		t.Fatalf("LoadPresets: %v", err)
+	}
+	if len(names) != 2 || names[0] != custom.Name || names[1] != keep.Name {
+		t.Fatalf("LoadPresets registered %v", names)
+	}
+	for _, want := range []Preset{custom, keep} {
+		got, ok := LookupPreset(want.Name)
+		saved, _ := json.Marshal(want)
+		loaded, _ := json.Marshal(got)
+		if !ok || !bytes.Equal(saved, loaded) {
+			t.Errorf("%s after the round trip = %s, want %s", want.Name, loaded, saved)
+		}
+	}
+	if got, _ := LookupPreset(custom.Name); len(got.Registers) != 1 || got.Registers[0] != custom.Registers[0] || got.PCLK != PCLK_DIV2 {
+		t.Errorf("%s lost its PCLK or Registers: %+v", custom.Name, got)
+	}
+
+	// Fields left out keep the values of NewPreset.
+	names, err = LoadPresets(strings.NewReader(`[{"Name": "test-sparse", "Format": "YUV"}]`))
+	if err != nil {
+		t.Fatalf("LoadPresets: %v", err)
+	}
+	if got, _ := LookupPreset("test-sparse"); got.Format != YUV || got.Resolution != QVGA || got.Contrast != 100 || !got.AutoWhiteBalance {
+		t.Errorf("sparse preset = %+v, want the neutral values around YUV", got)
+	}
+}
+
+func TestLoadPresetsRejects(t *testing.T) {
+	cases := map[string]string{
+		"format name":     `[{"Name": "test-bad", "Format": "RGB888"}]`,
+		"resolution name": `[{"Name": "test-bad", "Resolution": "HD"}]`,
+		"PCLK name":       `[{"Name": "test-bad", "PCLK": "DIV9"}]`,
+		"night name":      `[{"Name": "test-bad", "Banding": {"Night": "NOON"}}]`,
+		"no name":         `[{"Format": "RGB"}]`,
+		"not an array":    `{"Name": "test-bad"}`,
+	}
+	for name, text := range cases {
+		if _, err := LoadPresets(strings.NewReader(text)); err == nil {
+			t.Errorf("%s: LoadPresets = nil, want an error", name)
+		}
+	}
+	if _, ok := LookupPreset("test-bad"); ok {
+		t.Errorf("a rejected preset was registered")
+	}
+}
+
+func TestPresetValidate(t *testing.T) {
+	cases := map[string]func(p *Preset){
+		"format":      func(p *Preset) { p.Format = IMAGE(42) },
+		"custom size": func(p *Preset) { p.Resolution, p.Width, p.Height = CUSTOM, SENSOR_WIDTH+1, 100 },
+		"PCLK":        func(p *Preset) { p.PCLK = PCLK_DIVIDER(3) },
+		"frame rate":  func(p *Preset) { p.FrameRate = -1 },
+		"banding":     func(p *Preset) { p.Banding.Filter = BANDING(9) },
+		"gain":        func(p *Preset) { p.Gain = MAX_GAIN + 1 },
+		"brightness":  func(p *Preset) { p.Brightness = MAX_BRIGHTNESS + 1 },
+		"contrast":    func(p *Preset) { p.Contrast = -1 },
+		"saturation":  func(p *Preset) { p.Saturation = MAX_SATURATION + 1 },
+		"hue":         func(p *Preset) { p.Hue = -MAX_HUE - 1 },
+		"gamma":       func(p *Preset) { p.Gamma = MAX_GAMMA + 1 },
+	}
+	if err := NewPreset("test-neutral").Validate(); err != nil {
+		t.Fatalf("neutral preset: %v", err)
+	}
+	for name, change := range cases {
+		p := NewPreset("test-invalid")
+		change(&p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: Validate = nil, want an error", name)
+		}
+		if err := RegisterPreset(p); err == nil {
+			t.Errorf("%s: RegisterPreset = nil, want an error", name)
+		}
+	}
+}
+
+func TestUseBuiltInPresets(t *testing.T) {
+	for _, name := range []string{PRESET_DOCUMENT, PRESET_LOW_LIGHT, PRESET_OUTDOOR, PRESET_HIGH_SPEED_MONO} {
+		p, ok := LookupPreset(name)
+		if !ok {
+			t.Fatalf("built-in preset %s is not registered", name)
+		}
+		bus := newFakeBus()
+		Cam := newFakeCamera(bus, &fakeClock{})
+		if err := Cam.Initialize(20_000_000); err != nil {
+			t.Fatalf("Initialize: %v", err)
+		}
+		if err := Cam.UsePreset(name); err != nil {
+			t.Errorf("UsePreset %s: %v", name, err)
+			continue
+		}
+		if Cam.ImageType() != p.Format || Cam.Resolution() != p.Resolution || Cam.Banding() != p.Banding {
+			t.Errorf("%s: camera runs %s %s %+v, want %s %s %+v", name, Cam.ImageType(), Cam.Resolution(), Cam.Banding(), p.Format, p.Resolution, p.Banding)
+		}
+		if saturation, hue := Cam.SaturationHue(); bus.registers[REG_BRIGHT] != uint8(p.Brightness) || saturation != p.Saturation || hue != p.Hue {
+			t.Errorf("%s: BRIGHT = 0x%02X, saturation %d%%, hue %d, want %d, %d%%, %d", name, bus.registers[REG_BRIGHT], saturation, hue, p.Brightness, p.Saturation, p.Hue)
+		}
+		if p.Gain != 0 {
+			if gain, _ := Cam.Gain(); gain != p.Gain {
+				t.Errorf("%s: gain = 0x%03X, want 0x%03X", name, gain, p.Gain)
+			}
+		}
+	}
+	if err := newFakeCamera(newFakeBus(), &fakeClock{}).UsePreset("no-such-preset"); err == nil {
+		t.Errorf("UsePreset of an unknown name = nil, want an error")
+	}
+}
@@ -0,0 +1,619 @@
+package Camera7670
+
+/*
+~ File Description:
+^ Typed bitfield builders and parsers for the OV7670 registers with packed control bits.
+^ Every field struct knows its register, builds its byte with Value() and is decoded back with its Parse function.
+^ Bits marked reserved in the datasheet are not represented and build as 0.
+*/
+
+/*
+ * @brief = A register value composed from named fields.
+ * @method Register = Address of the register the value belongs to.
+ * @method Value = The byte to write.
+ */
+type RegisterField interface {
+	Register() uint8
+	Value() uint8
+}
+
+// & OneLine Brief = Returns mask when flag is set, else 0.
+func bit(flag bool, mask uint8) uint8 {
+	if flag {
+		return mask
+	}
+	return 0
+}
+
+// * COM7
+
+// & Output resolution bits of COM7.
+type COM7_RESOLUTION uint8
+
+const (
+	COM7_VGA  COM7_RESOLUTION = 0x00
+	COM7_CIF  COM7_RESOLUTION = 0x20
+	COM7_QVGA COM7_RESOLUTION = 0x10
+	COM7_QCIF COM7_RESOLUTION = 0x08
+)
+
+func (r COM7_RESOLUTION) String() string {
+	switch r {
+	case COM7_VGA:
+		return "VGA"
+	case COM7_CIF:
+		return "CIF"
+	case COM7_QVGA:
+		return "QVGA"
+	case COM7_QCIF:
+		return "QCIF"
+	}
+
+	return "NOT VALID"
+}
+
+// & Output format bits of COM7.
+type COM7_FORMAT uint8
+
+const (
+	COM7_YUV             COM7_FORMAT = 0x00
+	COM7_RGB             COM7_FORMAT = 0x04
+	COM7_RAW_BAYER       COM7_FORMAT = 0x01
+	COM7_PROCESSED_BAYER COM7_FORMAT = 0x05
+)
+
+func (f COM7_FORMAT) String() string {
+	switch f {
+	case COM7_YUV:
+		return "YUV"
+	case COM7_RGB:
+		return "RGB"
+	case COM7_RAW_BAYER:
+		return "RAW BAYER"
+	case COM7_PROCESSED_BAYER:
+		return "PROCESSED BAYER"
+	}
+
+	return "NOT VALID"
+}
+
+/*
+ * @brief = COM7 (0x12), common control 7.
+ * @element Reset = SCCB register reset, clears itself.
+ * @element Resolution = Output resolution.
+ * @element ColourBar = Colour bar test pattern.
+ * @element Format = Output format.
+ */
+type COM7 struct {
+	Reset      bool
+	Resolution COM7_RESOLUTION
+	ColourBar  bool
+	Format     COM7_FORMAT
+}
+
+func (c COM7) Register() uint8 { return REG_COM7 }
+
+func (c COM7) Value() uint8 {
+	return bit(c.Reset, 0x80) | uint8(c.Resolution)&0x38 | bit(c.ColourBar, 0x02) | uint8(c.Format)&0x05
+}
+
+func ParseCOM7(v uint8) COM7 {
+	return COM7{
+		Reset:      v&0x80 != 0,
+		Resolution: COM7_RESOLUTION(v & 0x38),
+		ColourBar:  v&0x02 != 0,
+		Format:     COM7_FORMAT(v & 0x05),
+	}
+}
+
+// * COM8
+
+/*
+ * @brief = COM8 (0x13), common control 8.
+ * @element FastAEC = Enable fast AGC/AEC algorithm.
+ * @element AECStepUnlimited = AEC step size has no limit, otherwise limited to vertical blank.
+ * @element BandingFilter = Enable the banding filter.
+ * @elements AGC, AWB, AEC = Enable automatic gain, white balance and exposure.
+ */
+type COM8 struct {
+	FastAEC          bool
+	AECStepUnlimited bool
+	BandingFilter    bool
+	AGC              bool
+	AWB              bool
+	AEC              bool
+}
+
+func (c COM8) Register() uint8 { return REG_COM8 }
+
+func (c COM8) Value() uint8 {
+	return bit(c.FastAEC, 0x80) | bit(c.AECStepUnlimited, 0x40) | bit(c.BandingFilter, 0x20) |
+		bit(c.AGC, 0x04) | bit(c.AWB, 0x02) | bit(c.AEC, 0x01)
+}
+
+func ParseCOM8(v uint8) COM8 {
+	return COM8{
+		FastAEC:          v&0x80 != 0,
+		AECStepUnlimited: v&0x40 != 0,
+		BandingFilter:    v&0x20 != 0,
+		AGC:              v&0x04 != 0,
+		AWB:              v&0x02 != 0,
+		AEC:              v&0x01 != 0,
+	}
+}
+
+// * COM10
+
+/*
+ * @brief = COM10 (0x15), common control 10.
+ * @element HSyncOnHREF = HREF pin outputs HSync instead.
+ * @element PCLKGatedByHREF = PCLK does not toggle during horizontal blank.
+ * @element PCLKReverse = Data is updated on the rising edge of PCLK.
+ * @element HREFReverse = HREF is active low.
+ * @element VSyncFallingEdge = VSync changes on the falling edge of PCLK.
+ * @element VSyncNegative = VSync is active low.
+ * @element HSyncNegative = HSync is active low.
+ */
+type COM10 struct {
+	HSyncOnHREF      bool
+	PCLKGatedByHREF  bool
+	PCLKReverse      bool
+	HREFReverse      bool
+	VSyncFallingEdge bool
+	VSyncNegative    bool
+	HSyncNegative    bool
+}
+
+func (c COM10) Register() uint8 { return REG_COM10 }
+
+func (c COM10) Value() uint8 {
+	return bit(c.HSyncOnHREF, 0x40) | bit(c.PCLKGatedByHREF, 0x20) | bit(c.PCLKReverse, 0x10) |
+		bit(c.HREFReverse, 0x08) | bit(c.VSyncFallingEdge, 0x04) | bit(c.VSyncNegative, 0x02) | bit(c.HSyncNegative, 0x01)
+}
+
+func ParseCOM10(v uint8) COM10 {
+	return COM10{
+		HSyncOnHREF:      v&0x40 != 0,
+		PCLKGatedByHREF:  v&0x20 != 0,
+		PCLKReverse:      v&0x10 != 0,
+		HREFReverse:      v&0x08 != 0,
+		VSyncFallingEdge: v&0x04 != 0,
+		VSyncNegative:    v&0x02 != 0,
+		HSyncNegative:    v&0x01 != 0,
+	}
+}
+
+// * COM11
+
+/*
+ * @brief = COM11 (0x3B), common control 11.
+ * @element NightMode = Enable night mode, the frame rate drops down to NightDivider so the exposure can grow.
+ * @element NightDivider = Lowest frame rate of night mode as a shift of the normal rate, 0 to 3 (1/1 to 1/8).
+ * @element AutoBanding = Detect the 50Hz or 60Hz light frequency and pick the banding filter.
+ * @element Banding50Hz = Use BD50ST as the banding step, otherwise BD60ST.
+ * @element ExposureBelowBand = Allow an exposure shorter than one banding step under strong light.
+ */
+type COM11 struct {
+	NightMode         bool
+	NightDivider      uint8
+	AutoBanding       bool
+	Banding50Hz       bool
+	ExposureBelowBand bool
+}
+
+func (c COM11) Register() uint8 { return REG_COM11 }
+
+func (c COM11) Value() uint8 {
+	return bit(c.NightMode, 0x80) | (c.NightDivider&0x03)<<5 | bit(c.AutoBanding, 0x10) |
+		bit(c.Banding50Hz, 0x08) | bit(c.ExposureBelowBand, 0x02)
+}
+
+func ParseCOM11(v uint8) COM11 {
+	return COM11{
+		NightMode:         v&0x80 != 0,
+		NightDivider:      (v >> 5) & 0x03,
+		AutoBanding:       v&0x10 != 0,
+		Banding50Hz:       v&0x08 != 0,
+		ExposureBelowBand: v&0x02 != 0,
+	}
+}
+
+// * COM2
+
+/*
+ * @brief = COM2 (0x09), common control 2.
+ * @element SoftSleep = Stop the outputs, the registers and SCCB stay alive.
+ * @element OutputDrive = Drive strength of the outputs, 0 to 3 (1x, 2x, 3x, 4x).
+ */
+type COM2 struct {
+	SoftSleep   bool
+	OutputDrive uint8
+}
+
+func (c COM2) Register() uint8 { return REG_COM2 }
+
+func (c COM2) Value() uint8 {
+	return bit(c.SoftSleep, 0x10) | c.OutputDrive&0x03
+}
+
+func ParseCOM2(v uint8) COM2 {
+	return COM2{SoftSleep: v&0x10 != 0, OutputDrive: v & 0x03}
+}
+
+// * COM3
+
+/*
+ * @brief = COM3 (0x0C), common control 3.
+ * @element SwapMSBLSB = Swap the output byte order.
+ * @element TriStateClock = Tri-state clock outputs in power down.
+ * @element TriStateData = Tri-state data outputs in power down.
+ * @element ScaleEnable = Enable the scaler.
+ * @element DCWEnable = Enable down sampling, cropping and windowing.
+ */
+type COM3 struct {
+	SwapMSBLSB    bool
+	TriStateClock bool
+	TriStateData  bool
+	ScaleEnable   bool
+	DCWEnable     bool
+}
+
+func (c COM3) Register() uint8 { return REG_COM3 }
+
+func (c COM3) Value() uint8 {
+	return bit(c.SwapMSBLSB, 0x40) | bit(c.TriStateClock, 0x20) | bit(c.TriStateData, 0x10) |
+		bit(c.ScaleEnable, 0x08) | bit(c.DCWEnable, 0x04)
+}
+
+func ParseCOM3(v uint8) COM3 {
+	return COM3{
+		SwapMSBLSB:    v&0x40 != 0,
+		TriStateClock: v&0x20 != 0,
+		TriStateData:  v&0x10 != 0,
+		ScaleEnable:   v&0x08 != 0,
+		DCWEnable:     v&0x04 != 0,
+	}
+}
+
+// * COM13
+
+/*
+ * @brief = COM13 (0x3D), common control 13.
+ * @element GammaEnable = Enable the gamma curve.
+ * @element UVSaturationAuto = Automatically adjust UV saturation.
+ * @element UVSwap = Swap U and V, used together with TSLB.UVFirst.
+ */
+type COM13 struct {
+	GammaEnable      bool
+	UVSaturationAuto bool
+	UVSwap           bool
+}
+
+func (c COM13) Register() uint8 { return REG_COM13 }
+
+func (c COM13) Value() uint8 {
+	return bit(c.GammaEnable, 0x80) | bit(c.UVSaturationAuto, 0x40) | bit(c.UVSwap, 0x01)
+}
+
+func ParseCOM13(v uint8) COM13 {
+	return COM13{
+		GammaEnable:      v&0x80 != 0,
+		UVSaturationAuto: v&0x40 != 0,
+		UVSwap:           v&0x01 != 0,
+	}
+}
+
+// * COM14
+
+/*
+ * @brief = COM14 (0x3E), common control 14.
+ * @element ScalingPCLK = Divide PCLK for DCW and scaling.
+ * @element ManualScaling = Manual scaling enable for the pre-defined resolutions.
+ * @element PCLKDivider = PCLK divided by 2^PCLKDivider, 0 to 4.
+ */
+type COM14 struct {
+	ScalingPCLK   bool
+	ManualScaling bool
+	PCLKDivider   uint8
+}
+
+func (c COM14) Register() uint8 { return REG_COM14 }
+
+func (c COM14) Value() uint8 {
+	return bit(c.ScalingPCLK, 0x10) | bit(c.ManualScaling, 0x08) | min(c.PCLKDivider, 4)
+}
+
+func ParseCOM14(v uint8) COM14 {
+	return COM14{
+		ScalingPCLK:   v&0x10 != 0,
+		ManualScaling: v&0x08 != 0,
+		PCLKDivider:   v & 0x07,
+	}
+}
+
+// * COM15
+
+// & Output data range bits of COM15.
+type COM15_RANGE uint8
+
+const (
+	COM15_RANGE_10_F0 COM15_RANGE = 0x00
+	COM15_RANGE_01_FE COM15_RANGE = 0x80
+	COM15_RANGE_00_FF COM15_RANGE = 0xC0
+)
+
+func (r COM15_RANGE) String() string {
+	switch r {
+	case COM15_RANGE_10_F0:
+		return "10 - F0"
+	case COM15_RANGE_01_FE:
+		return "01 - FE"
+	case COM15_RANGE_00_FF:
+		return "00 - FF"
+	}
+
+	return "NOT VALID"
+}
+
+// & RGB format bits of COM15.
+type COM15_RGB uint8
+
+const (
+	COM15_RGB_NORMAL COM15_RGB = 0x00
+	COM15_RGB565     COM15_RGB = 0x10
+	COM15_RGB555     COM15_RGB = 0x30
+)
+
+func (f COM15_RGB) String() string {
+	switch f {
+	case COM15_RGB_NORMAL:
+		return "NORMAL"
+	case COM15_RGB565:
+		return "RGB565"
+	case COM15_RGB555:
+		return "RGB555"
+	}
+
+	return "NOT VALID"
+}
+
+/*
+ * @brief = COM15 (0x40), common control 15.
+ * @element Range = Output data range.
+ * @element RGB = RGB output format, RGB565 is also used for RGB444 together with the RGB444 register.
+ */
+type COM15 struct {
+	Range COM15_RANGE
+	RGB   COM15_RGB
+}
+
+func (c COM15) Register() uint8 { return REG_COM15 }
+
+func (c COM15) Value() uint8 {
+	return uint8(c.Range)&0xC0 | uint8(c.RGB)&0x30
+}
+
+func ParseCOM15(v uint8) COM15 {
+	result := COM15{Range: COM15_RANGE(v & 0xC0), RGB: COM15_RGB(v & 0x30)}
+	if result.Range == 0x40 {
+		result.Range = COM15_RANGE_10_F0
+	}
+	if result.RGB == 0x20 {
+		result.RGB = COM15_RGB_NORMAL
+	}
+	return result
+}
+
+// * CLKRC
+
+/*
+ * @brief = CLKRC (0x11), internal clock.
+ * @element External = Use the external clock directly, no prescaling.
+ * @element Prescaler = Internal clock = input clock / (Prescaler + 1), 0 to 63.
+ */
+type CLKRC struct {
+	External  bool
+	Prescaler uint8
+}
+
+func (c CLKRC) Register() uint8 { return REG_CLKRC }
+
+func (c CLKRC) Value() uint8 {
+	return bit(c.External, 0x40) | c.Prescaler&0x3F
+}
+
+func ParseCLKRC(v uint8) CLKRC {
+	return CLKRC{External: v&0x40 != 0, Prescaler: v & 0x3F}
+}
+
+// & OneLine Brief = Returns the division applied to the input clock.
+func (c CLKRC) Divider() int {
+	if c.External {
+		return 1
+	}
+	return int(c.Prescaler) + 1
+}
+
+// * TSLB
+
+/*
+ * @brief = TSLB (0x3A), line buffer test option.
+ * @element NegativeImage = Output a negative image.
+ * @element FixedUV = Use MANU and MANV as the output U and V.
+ * @element UVFirst = Output sequence starts with the chroma byte, used together with COM13.UVSwap.
+ * @element YLast = Reserved bit 2 set by the reference settings (Y last in the output pipe).
+ * @element AutoWindow = Sensor automatically sets the output window when the resolution changes.
+ */
+type TSLB struct {
+	NegativeImage bool
+	FixedUV       bool
+	UVFirst       bool
+	YLast         bool
+	AutoWindow    bool
+}
+
+func (c TSLB) Register() uint8 { return REG_TSLB }
+
+func (c TSLB) Value() uint8 {
+	return bit(c.NegativeImage, 0x20) | bit(c.FixedUV, 0x10) | bit(c.UVFirst, 0x08) | bit(c.YLast, 0x04) | bit(c.AutoWindow, 0x01)
+}
+
+func ParseTSLB(v uint8) TSLB {
+	return TSLB{
+		NegativeImage: v&0x20 != 0,
+		FixedUV:       v&0x10 != 0,
+		UVFirst:       v&0x08 != 0,
+		YLast:         v&0x04 != 0,
+		AutoWindow:    v&0x01 != 0,
+	}
+}
+
+// * MVFP
+
+/*
+ * @brief = MVFP (0x1E), mirror and vertical flip.
+ * @element Mirror = Mirror the image horizontally.
+ * @element VFlip = Flip the image vertically.
+ * @element BlackSun = Enable black sun correction.
+ */
+type MVFP struct {
+	Mirror   bool
+	VFlip    bool
+	BlackSun bool
+}
+
+func (c MVFP) Register() uint8 { return REG_MVFP }
+
+func (c MVFP) Value() uint8 {
+	return bit(c.Mirror, 0x20) | bit(c.VFlip, 0x10) | bit(c.BlackSun, 0x04)
+}
+
+func ParseMVFP(v uint8) MVFP {
+	return MVFP{Mirror: v&0x20 != 0, VFlip: v&0x10 != 0, BlackSun: v&0x04 != 0}
+}
+
+// * SCALING_DCWCTR
+
+/*
+ * @brief = SCALING_DCWCTR (0x72), down sampling control.
+ * @element VerticalAverage = Average the down sampled lines instead of dropping them.
+ * @element VerticalRounding = Vertical rounding option.
+ * @element VerticalRate = Vertical down sampling by 2^VerticalRate, 0 to 3.
+ * @element HorizontalAverage = Average the down sampled pixels instead of dropping them.
+ * @element HorizontalRounding = Horizontal rounding option.
+ * @element HorizontalRate = Horizontal down sampling by 2^HorizontalRate, 0 to 3.
+ */
+type SCALING_DCWCTR struct {
+	VerticalAverage    bool
+	VerticalRounding   bool
+	VerticalRate       uint8
+	HorizontalAverage  bool
+	HorizontalRounding bool
+	HorizontalRate     uint8
+}
+
+func (c SCALING_DCWCTR) Register() uint8 { return REG_SCALING_DCWCTR }
+
+func (c SCALING_DCWCTR) Value() uint8 {
+	return bit(c.VerticalAverage, 0x80) | bit(c.VerticalRounding, 0x40) | (c.VerticalRate&0x03)<<4 |
+		bit(c.HorizontalAverage, 0x08) | bit(c.HorizontalRounding, 0x04) | c.HorizontalRate&0x03
+}
+
+func ParseSCALING_DCWCTR(v uint8) SCALING_DCWCTR {
+	return SCALING_DCWCTR{
+		VerticalAverage:    v&0x80 != 0,
+		VerticalRounding:   v&0x40 != 0,
+		VerticalRate:       (v >> 4) & 0x03,
+		HorizontalAverage:  v&0x08 != 0,
+		HorizontalRounding: v&0x04 != 0,
+		HorizontalRate:     v & 0x03,
+	}
+}
+
+// * SCALING_PCLK_DIV
+
+/*
+ * @brief = SCALING_PCLK_DIV (0x73), clock divider of the DSP scaler. Should follow COM14.PCLKDivider.
+ * @element Bypass = Bypass the divider.
+ * @element Divider = DSP scale clock divided by 2^Divider, 0 to 4.
+ */
+type SCALING_PCLK_DIV struct {
+	Bypass  bool
+	Divider uint8
+}
+
+func (c SCALING_PCLK_DIV) Register() uint8 { return REG_SCALING_PCLK_DIV }
+
+// & OneLine Brief = Upper nibble is reserved and reads back as 0xF.
+func (c SCALING_PCLK_DIV) Value() uint8 {
+	return 0xF0 | bit(c.Bypass, 0x08) | min(c.Divider, 4)
+}
+
+func ParseSCALING_PCLK_DIV(v uint8) SCALING_PCLK_DIV {
+	return SCALING_PCLK_DIV{Bypass: v&0x08 != 0, Divider: v & 0x07}
+}
+
+// * RGB444
+
+/*
+ * @brief = RGB444 (0x8C).
+ * @element Enable = Output RGB444, COM15 must select RGB565.
+ * @element WordFormatRGBx = false gives xR GB, true gives RG Bx.
+ */
+type RGB444 struct {
+	Enable         bool
+	WordFormatRGBx bool
+}
+
+func (c RGB444) Register() uint8 { return REG_RGB444 }
+
+func (c RGB444) Value() uint8 {
+	return bit(c.Enable, 0x02) | bit(c.WordFormatRGBx, 0x01)
+}
+
+func ParseRGB444(v uint8) RGB444 {
+	return RGB444{Enable: v&0x02 != 0, WordFormatRGBx: v&0x01 != 0}
+}
+
+/*
+ * @brief = Decodes a register value into its field struct.
+ * @param reg = Register address.
+ * @param v = Value of the register.
+ * @return = The field struct, nil for registers without named fields.
+ */
+func ParseField(reg, v uint8) RegisterField {
+	switch reg {
+	case REG_COM2:
+		return ParseCOM2(v)
+	case REG_COM3:
+		return ParseCOM3(v)
+	case REG_COM7:
+		return ParseCOM7(v)
+	case REG_COM8:
+		return ParseCOM8(v)
+	case REG_COM10:
+		return ParseCOM10(v)
+	case REG_COM11:
+		return ParseCOM11(v)
+	case REG_COM13:
+		return ParseCOM13(v)
+	case REG_COM14:
+		return ParseCOM14(v)
+	case REG_COM15:
+		return ParseCOM15(v)
+	case REG_CLKRC:
+		return ParseCLKRC(v)
+	case REG_TSLB:
+		return ParseTSLB(v)
+	case REG_MVFP:
+		return ParseMVFP(v)
+	case REG_SCALING_DCWCTR:
+		return ParseSCALING_DCWCTR(v)
+	case REG_SCALING_PCLK_DIV:
+		return ParseSCALING_PCLK_DIV(v)
+	case REG_RGB444:
+		return ParseRGB444(v)
+	}
+
+	return nil
+}
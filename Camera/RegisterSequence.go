@@ -0,0 +1,128 @@
+package Camera7670
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+~ File Description:
+^ Declarative register sequences and the one engine that applies them.
+^ A sequence is a data table of (register, value, mask, delay) entries, the tables themselves live in OV7670Sequences.go.
+^ Every failing write, read or read-back mismatch is reported as a RegisterError naming the register.
+*/
+
+// & Mask that writes the whole register.
+const MASK_ALL uint8 = 0xFF
+
+// & Bits that clear themselves after being written, never compared on read-back.
+var selfClearingBits = map[uint8]uint8{
+	REG_COM7: 0x80,
+}
+
+// & Returned (wrapped in a RegisterError) when a register reads back a different value than written.
+var ErrRegisterMismatch = errors.New("register read-back mismatch")
+
+/*
+ * @brief = One entry of a register sequence.
+ * @element Register = The Desired Register.
+ * @element Value = The Desired value, only the bits in Mask are used.
+ * @element Mask = Bits to change, MASK_ALL writes the value directly, anything else does a read-modify-write.
+ * @element Delay = Time to wait after the write.
+ */
+type RegisterWrite struct {
+	Register uint8
+	Value    uint8
+	Mask     uint8
+	Delay    time.Duration
+}
+
+// & Ordered list of register writes.
+type RegisterSequence []RegisterWrite
+
+/*
+ * @brief = Error of a register operation.
+ * @element Register = The register that failed.
+ * @element Operation = "write", "read" or "verify".
+ * @element Expected = Value (under Mask) that should be in the register.
+ * @element Got = Value read back, only meaningful for "verify".
+ * @element Mask = Bits that were compared.
+ * @element Err = Bus error or ErrRegisterMismatch.
+ */
+type RegisterError struct {
+	Register  uint8
+	Operation string
+	Expected  uint8
+	Got       uint8
+	Mask      uint8
+	Err       error
+}
+
+func (e *RegisterError) Error() string {
+	switch e.Operation {
+	case "verify":
+		return fmt.Sprintf("%s (0x%02X): read back 0x%02X, expected 0x%02X (mask 0x%02X)",
+			RegisterName(e.Register), e.Register, e.Got, e.Expected, e.Mask)
+	case "read":
+		return fmt.Sprintf("%s (0x%02X): read failed: %v", RegisterName(e.Register), e.Register, e.Err)
+	}
+	return fmt.Sprintf("%s (0x%02X): %s of 0x%02X failed: %v", RegisterName(e.Register), e.Register, e.Operation, e.Expected, e.Err)
+}
+
+func (e *RegisterError) Unwrap() error {
+	return e.Err
+}
+
+/*
+* @brief = Applies a register sequence entry by entry.
+* ^ When VerifyWrites is set, every register is read back and compared under its mask.
+* @param seq = The sequence to apply.
+* @return = *RegisterError for the first register that failed, nil if all went fine.
+ */
+func (Cam *OV7670) ApplySequence(seq RegisterSequence) error {
+	for _, entry := range seq {
+		value := entry.Value
+		if entry.Mask != MASK_ALL {
+			current, err := Cam.Read(entry.Register)
+			if err != nil {
+				return &RegisterError{Register: entry.Register, Operation: "read", Expected: entry.Value, Mask: entry.Mask, Err: err}
+			}
+			value = current&^entry.Mask | entry.Value&entry.Mask
+		}
+
+		if err := Cam.Write(entry.Register, value); err != nil {
+			return &RegisterError{Register: entry.Register, Operation: "write", Expected: value, Mask: entry.Mask, Err: err}
+		}
+
+		if entry.Register == REG_CLKRC {
+			Cam.clock = ParseCLKRC(value)
+		}
+
+		if entry.Delay > 0 {
+			time.Sleep(entry.Delay)
+		}
+
+		if Cam.VerifyWrites {
+			if err := Cam.verify(entry.Register, value, entry.Mask); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// & OneLine Brief = Reads a register back and compares it with the written value under mask.
+func (Cam *OV7670) verify(reg, value, mask uint8) error {
+	mask &^= selfClearingBits[reg]
+	got, err := Cam.Read(reg)
+	if err != nil {
+		return &RegisterError{Register: reg, Operation: "read", Expected: value, Mask: mask, Err: err}
+	}
+	if got&mask != value&mask {
+		return &RegisterError{Register: reg, Operation: "verify", Expected: value & mask, Got: got & mask, Mask: mask, Err: ErrRegisterMismatch}
+	}
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package Camera7670
+
+import "fmt"
+
+/*
+~ File Description:
+^ Named register map of OV7670, taken from the OV7670 datasheet and implementation guide.
+^ Registers not listed here are reserved and should not be written.
+*/
+
+// & Register addresses.
+const (
+	REG_GAIN               uint8 = 0x00 // AGC gain bits[7:0], bits[9:8] live in VREF
+	REG_BLUE               uint8 = 0x01 // AWB blue channel gain
+	REG_RED                uint8 = 0x02 // AWB red channel gain
+	REG_VREF               uint8 = 0x03 // Vertical frame control and AGC gain bits[9:8]
+	REG_COM1               uint8 = 0x04 // CCIR656 enable and AEC low bits[1:0]
+	REG_BAVE               uint8 = 0x05 // U/B average level
+	REG_GbAVE              uint8 = 0x06 // Y/Gb average level
+	REG_AECHH              uint8 = 0x07 // Exposure value bits[15:10]
+	REG_RAVE               uint8 = 0x08 // V/R average level
+	REG_COM2               uint8 = 0x09 // Soft sleep and output drive capability
+	REG_PID                uint8 = 0x0A // Product ID MSB (read only)
+	REG_VER                uint8 = 0x0B // Product ID LSB (read only)
+	REG_COM3               uint8 = 0x0C // Output swap, tri-state, scale and DCW enable
+	REG_COM4               uint8 = 0x0D // Average option, must match COM17
+	REG_COM5               uint8 = 0x0E // Reserved
+	REG_COM6               uint8 = 0x0F // Optical black line and reset timing
+	REG_AECH               uint8 = 0x10 // Exposure value bits[9:2]
+	REG_CLKRC              uint8 = 0x11 // Internal clock prescaler
+	REG_COM7               uint8 = 0x12 // SCCB reset, output resolution and format
+	REG_COM8               uint8 = 0x13 // Fast AEC, banding filter, AGC, AWB and AEC enable
+	REG_COM9               uint8 = 0x14 // Automatic gain ceiling
+	REG_COM10              uint8 = 0x15 // VSync, HREF and PCLK options
+	REG_RSVD16             uint8 = 0x16 // Reserved
+	REG_HSTART             uint8 = 0x17 // Output window horizontal start bits[10:3]
+	REG_HSTOP              uint8 = 0x18 // Output window horizontal stop bits[10:3]
+	REG_VSTRT              uint8 = 0x19 // Output window vertical start bits[9:2]
+	REG_VSTOP              uint8 = 0x1A // Output window vertical stop bits[9:2]
+	REG_PSHFT              uint8 = 0x1B // Data format pixel delay
+	REG_MIDH               uint8 = 0x1C // Manufacturer ID MSB (read only)
+	REG_MIDL               uint8 = 0x1D // Manufacturer ID LSB (read only)
+	REG_MVFP               uint8 = 0x1E // Mirror, vertical flip and black sun
+	REG_LAEC               uint8 = 0x1F // Reserved
+	REG_ADCCTR0            uint8 = 0x20 // ADC range and reference
+	REG_ADCCTR1            uint8 = 0x21 // Reserved
+	REG_ADCCTR2            uint8 = 0x22 // Reserved
+	REG_ADCCTR3            uint8 = 0x23 // Reserved
+	REG_AEW                uint8 = 0x24 // AGC/AEC stable operating region upper limit
+	REG_AEB                uint8 = 0x25 // AGC/AEC stable operating region lower limit
+	REG_VPT                uint8 = 0x26 // AGC/AEC fast mode operating region
+	REG_BBIAS              uint8 = 0x27 // B channel signal output bias
+	REG_GbBIAS             uint8 = 0x28 // Gb channel signal output bias
+	REG_RSVD29             uint8 = 0x29 // Reserved
+	REG_EXHCH              uint8 = 0x2A // Dummy pixel insert MSB
+	REG_EXHCL              uint8 = 0x2B // Dummy pixel insert LSB
+	REG_RBIAS              uint8 = 0x2C // R channel signal output bias
+	REG_ADVFL              uint8 = 0x2D // Dummy line LSB
+	REG_ADVFH              uint8 = 0x2E // Dummy line MSB
+	REG_YAVE               uint8 = 0x2F // Y/G channel average value
+	REG_HSYST              uint8 = 0x30 // HSync rising edge delay
+	REG_HSYEN              uint8 = 0x31 // HSync falling edge delay
+	REG_HREF               uint8 = 0x32 // HREF control and window edge bits[2:0]
+	REG_CHLF               uint8 = 0x33 // Array current control
+	REG_ARBLM              uint8 = 0x34 // Array reference control
+	REG_RSVD35             uint8 = 0x35 // Reserved
+	REG_RSVD36             uint8 = 0x36 // Reserved
+	REG_ADC                uint8 = 0x37 // ADC control
+	REG_ACOM               uint8 = 0x38 // ADC and analog common mode control
+	REG_OFON               uint8 = 0x39 // ADC offset control
+	REG_TSLB               uint8 = 0x3A // Line buffer test option and output sequence
+	REG_COM11              uint8 = 0x3B // Night mode and banding filter selection
+	REG_COM12              uint8 = 0x3C // HREF option
+	REG_COM13              uint8 = 0x3D // Gamma enable, UV saturation and UV swap
+	REG_COM14              uint8 = 0x3E // DCW and scaling PCLK enable and divider
+	REG_EDGE               uint8 = 0x3F // Edge enhancement adjustment
+	REG_COM15              uint8 = 0x40 // Output data range and RGB format
+	REG_COM16              uint8 = 0x41 // Edge enhancement, de-noise and AWB gain options
+	REG_COM17              uint8 = 0x42 // DSP colour bar and average option
+	REG_AWBC1              uint8 = 0x43 // AWB control 1
+	REG_AWBC2              uint8 = 0x44 // AWB control 2
+	REG_AWBC3              uint8 = 0x45 // AWB control 3
+	REG_AWBC4              uint8 = 0x46 // AWB control 4
+	REG_AWBC5              uint8 = 0x47 // AWB control 5
+	REG_AWBC6              uint8 = 0x48 // AWB control 6
+	REG_REG4B              uint8 = 0x4B // UV average enable
+	REG_DNSTH              uint8 = 0x4C // De-noise strength
+	REG_MTX1               uint8 = 0x4F // Matrix coefficient 1
+	REG_MTX2               uint8 = 0x50 // Matrix coefficient 2
+	REG_MTX3               uint8 = 0x51 // Matrix coefficient 3
+	REG_MTX4               uint8 = 0x52 // Matrix coefficient 4
+	REG_MTX5               uint8 = 0x53 // Matrix coefficient 5
+	REG_MTX6               uint8 = 0x54 // Matrix coefficient 6
+	REG_BRIGHT             uint8 = 0x55 // Brightness control
+	REG_CONTRAS            uint8 = 0x56 // Contrast control
+	REG_CONTRAS_CENTER     uint8 = 0x57 // Contrast centre
+	REG_MTXS               uint8 = 0x58 // Matrix coefficient sign and auto contrast centre
+	REG_AWBC7              uint8 = 0x59 // AWB control 7
+	REG_AWBC8              uint8 = 0x5A // AWB control 8
+	REG_AWBC9              uint8 = 0x5B // AWB control 9
+	REG_AWBC10             uint8 = 0x5C // AWB control 10
+	REG_AWBC11             uint8 = 0x5D // AWB control 11
+	REG_AWBC12             uint8 = 0x5E // AWB control 12
+	REG_LCC1               uint8 = 0x62 // Lens correction X coordinate
+	REG_LCC2               uint8 = 0x63 // Lens correction Y coordinate
+	REG_LCC3               uint8 = 0x64 // Lens correction factor
+	REG_LCC4               uint8 = 0x65 // Lens correction radius
+	REG_LCC5               uint8 = 0x66 // Lens correction control
+	REG_MANU               uint8 = 0x67 // Manual U value
+	REG_MANV               uint8 = 0x68 // Manual V value
+	REG_GFIX               uint8 = 0x69 // Fix gain control
+	REG_GGAIN              uint8 = 0x6A // G channel AWB gain
+	REG_DBLV               uint8 = 0x6B // PLL control and regulator bypass
+	REG_AWBCTR3            uint8 = 0x6C // AWB control 3
+	REG_AWBCTR2            uint8 = 0x6D // AWB control 2
+	REG_AWBCTR1            uint8 = 0x6E // AWB control 1
+	REG_AWBCTR0            uint8 = 0x6F // AWB control 0
+	REG_SCALING_XSC        uint8 = 0x70 // Horizontal scale factor and test pattern bit 0
+	REG_SCALING_YSC        uint8 = 0x71 // Vertical scale factor and test pattern bit 1
+	REG_SCALING_DCWCTR     uint8 = 0x72 // DCW down sampling control
+	REG_SCALING_PCLK_DIV   uint8 = 0x73 // DSP scale clock divider
+	REG_REG74              uint8 = 0x74 // Digital gain control
+	REG_REG75              uint8 = 0x75 // Edge enhancement lower limit
+	REG_REG76              uint8 = 0x76 // Black and white pixel correction and edge enhancement upper limit
+	REG_REG77              uint8 = 0x77 // De-noise offset
+	REG_SLOP               uint8 = 0x7A // Gamma curve highest segment slope
+	REG_GAM1               uint8 = 0x7B // Gamma curve 1st segment input end point 0x04
+	REG_GAM2               uint8 = 0x7C // Gamma curve 2nd segment input end point 0x08
+	REG_GAM3               uint8 = 0x7D // Gamma curve 3rd segment input end point 0x10
+	REG_GAM4               uint8 = 0x7E // Gamma curve 4th segment input end point 0x20
+	REG_GAM5               uint8 = 0x7F // Gamma curve 5th segment input end point 0x28
+	REG_GAM6               uint8 = 0x80 // Gamma curve 6th segment input end point 0x30
+	REG_GAM7               uint8 = 0x81 // Gamma curve 7th segment input end point 0x38
+	REG_GAM8               uint8 = 0x82 // Gamma curve 8th segment input end point 0x40
+	REG_GAM9               uint8 = 0x83 // Gamma curve 9th segment input end point 0x48
+	REG_GAM10              uint8 = 0x84 // Gamma curve 10th segment input end point 0x50
+	REG_GAM11              uint8 = 0x85 // Gamma curve 11th segment input end point 0x60
+	REG_GAM12              uint8 = 0x86 // Gamma curve 12th segment input end point 0x70
+	REG_GAM13              uint8 = 0x87 // Gamma curve 13th segment input end point 0x90
+	REG_GAM14              uint8 = 0x88 // Gamma curve 14th segment input end point 0xB0
+	REG_GAM15              uint8 = 0x89 // Gamma curve 15th segment input end point 0xD0
+	REG_RGB444             uint8 = 0x8C // RGB444 enable and word format
+	REG_DM_LNL             uint8 = 0x92 // Dummy line LSB
+	REG_DM_LNH             uint8 = 0x93 // Dummy line MSB
+	REG_LCC6               uint8 = 0x94 // Lens correction for G channel
+	REG_LCC7               uint8 = 0x95 // Lens correction for R channel
+	REG_BD50ST             uint8 = 0x9D // 50Hz banding filter value
+	REG_BD60ST             uint8 = 0x9E // 60Hz banding filter value
+	REG_HAECC1             uint8 = 0x9F // Histogram based AEC control 1
+	REG_HAECC2             uint8 = 0xA0 // Histogram based AEC control 2
+	REG_SCALING_PCLK_DELAY uint8 = 0xA2 // Scaling output delay
+	REG_NT_CTRL            uint8 = 0xA4 // Auto frame rate adjustment control
+	REG_BD50MAX            uint8 = 0xA5 // 50Hz banding step limit
+	REG_HAECC3             uint8 = 0xA6 // Histogram based AEC control 3
+	REG_HAECC4             uint8 = 0xA7 // Histogram based AEC control 4
+	REG_HAECC5             uint8 = 0xA8 // Histogram based AEC control 5
+	REG_HAECC6             uint8 = 0xA9 // Histogram based AEC control 6
+	REG_HAECC7             uint8 = 0xAA // Histogram based AEC control 7
+	REG_BD60MAX            uint8 = 0xAB // 60Hz banding step limit
+	REG_STR_OPT            uint8 = 0xAC // Strobe and R/G/B gain control
+	REG_STR_R              uint8 = 0xAD // R gain for LED output frame
+	REG_STR_G              uint8 = 0xAE // G gain for LED output frame
+	REG_STR_B              uint8 = 0xAF // B gain for LED output frame
+	REG_ABLC1              uint8 = 0xB1 // Automatic black level calibration enable
+	REG_THL_ST             uint8 = 0xB3 // ABLC target
+	REG_THL_DLT            uint8 = 0xB5 // ABLC stable range
+	REG_AD_CHB             uint8 = 0xBE // Blue channel black level compensation
+	REG_AD_CHR             uint8 = 0xBF // Red channel black level compensation
+	REG_AD_CHGb            uint8 = 0xC0 // Gb channel black level compensation
+	REG_AD_CHGr            uint8 = 0xC1 // Gr channel black level compensation
+	REG_SATCTR             uint8 = 0xC9 // Saturation control
+)
+
+// & Register names, used to print register dumps.
+var registerNames = map[uint8]string{
+	REG_GAIN:               "GAIN",
+	REG_BLUE:               "BLUE",
+	REG_RED:                "RED",
+	REG_VREF:               "VREF",
+	REG_COM1:               "COM1",
+	REG_BAVE:               "BAVE",
+	REG_GbAVE:              "GbAVE",
+	REG_AECHH:              "AECHH",
+	REG_RAVE:               "RAVE",
+	REG_COM2:               "COM2",
+	REG_PID:                "PID",
+	REG_VER:                "VER",
+	REG_COM3:               "COM3",
+	REG_COM4:               "COM4",
+	REG_COM5:               "COM5",
+	REG_COM6:               "COM6",
+	REG_AECH:               "AECH",
+	REG_CLKRC:              "CLKRC",
+	REG_COM7:               "COM7",
+	REG_COM8:               "COM8",
+	REG_COM9:               "COM9",
+	REG_COM10:              "COM10",
+	REG_RSVD16:             "RSVD16",
+	REG_HSTART:             "HSTART",
+	REG_HSTOP:              "HSTOP",
+	REG_VSTRT:              "VSTRT",
+	REG_VSTOP:              "VSTOP",
+	REG_PSHFT:              "PSHFT",
+	REG_MIDH:               "MIDH",
+	REG_MIDL:               "MIDL",
+	REG_MVFP:               "MVFP",
+	REG_LAEC:               "LAEC",
+	REG_ADCCTR0:            "ADCCTR0",
+	REG_ADCCTR1:            "ADCCTR1",
+	REG_ADCCTR2:            "ADCCTR2",
+	REG_ADCCTR3:            "ADCCTR3",
+	REG_AEW:                "AEW",
+	REG_AEB:                "AEB",
+	REG_VPT:                "VPT",
+	REG_BBIAS:              "BBIAS",
+	REG_GbBIAS:             "GbBIAS",
+	REG_RSVD29:             "RSVD29",
+	REG_EXHCH:              "EXHCH",
+	REG_EXHCL:              "EXHCL",
+	REG_RBIAS:              "RBIAS",
+	REG_ADVFL:              "ADVFL",
+	REG_ADVFH:              "ADVFH",
+	REG_YAVE:               "YAVE",
+	REG_HSYST:              "HSYST",
+	REG_HSYEN:              "HSYEN",
+	REG_HREF:               "HREF",
+	REG_CHLF:               "CHLF",
+	REG_ARBLM:              "ARBLM",
+	REG_RSVD35:             "RSVD35",
+	REG_RSVD36:             "RSVD36",
+	REG_ADC:                "ADC",
+	REG_ACOM:               "ACOM",
+	REG_OFON:               "OFON",
+	REG_TSLB:               "TSLB",
+	REG_COM11:              "COM11",
+	REG_COM12:              "COM12",
+	REG_COM13:              "COM13",
+	REG_COM14:              "COM14",
+	REG_EDGE:               "EDGE",
+	REG_COM15:              "COM15",
+	REG_COM16:              "COM16",
+	REG_COM17:              "COM17",
+	REG_AWBC1:              "AWBC1",
+	REG_AWBC2:              "AWBC2",
+	REG_AWBC3:              "AWBC3",
+	REG_AWBC4:              "AWBC4",
+	REG_AWBC5:              "AWBC5",
+	REG_AWBC6:              "AWBC6",
+	REG_REG4B:              "REG4B",
+	REG_DNSTH:              "DNSTH",
+	REG_MTX1:               "MTX1",
+	REG_MTX2:               "MTX2",
+	REG_MTX3:               "MTX3",
+	REG_MTX4:               "MTX4",
+	REG_MTX5:               "MTX5",
+	REG_MTX6:               "MTX6",
+	REG_BRIGHT:             "BRIGHT",
+	REG_CONTRAS:            "CONTRAS",
+	REG_CONTRAS_CENTER:     "CONTRAS_CENTER",
+	REG_MTXS:               "MTXS",
+	REG_AWBC7:              "AWBC7",
+	REG_AWBC8:              "AWBC8",
+	REG_AWBC9:              "AWBC9",
+	REG_AWBC10:             "AWBC10",
+	REG_AWBC11:             "AWBC11",
+	REG_AWBC12:             "AWBC12",
+	REG_LCC1:               "LCC1",
+	REG_LCC2:               "LCC2",
+	REG_LCC3:               "LCC3",
+	REG_LCC4:               "LCC4",
+	REG_LCC5:               "LCC5",
+	REG_MANU:               "MANU",
+	REG_MANV:               "MANV",
+	REG_GFIX:               "GFIX",
+	REG_GGAIN:              "GGAIN",
+	REG_DBLV:               "DBLV",
+	REG_AWBCTR3:            "AWBCTR3",
+	REG_AWBCTR2:            "AWBCTR2",
+	REG_AWBCTR1:            "AWBCTR1",
+	REG_AWBCTR0:            "AWBCTR0",
+	REG_SCALING_XSC:        "SCALING_XSC",
+	REG_SCALING_YSC:        "SCALING_YSC",
+	REG_SCALING_DCWCTR:     "SCALING_DCWCTR",
+	REG_SCALING_PCLK_DIV:   "SCALING_PCLK_DIV",
+	REG_REG74:              "REG74",
+	REG_REG75:              "REG75",
+	REG_REG76:              "REG76",
+	REG_REG77:              "REG77",
+	REG_SLOP:               "SLOP",
+	REG_GAM1:               "GAM1",
+	REG_GAM2:               "GAM2",
+	REG_GAM3:               "GAM3",
+	REG_GAM4:               "GAM4",
+	REG_GAM5:               "GAM5",
+	REG_GAM6:               "GAM6",
+	REG_GAM7:               "GAM7",
+	REG_GAM8:               "GAM8",
+	REG_GAM9:               "GAM9",
+	REG_GAM10:              "GAM10",
+	REG_GAM11:              "GAM11",
+	REG_GAM12:              "GAM12",
+	REG_GAM13:              "GAM13",
+	REG_GAM14:              "GAM14",
+	REG_GAM15:              "GAM15",
+	REG_RGB444:             "RGB444",
+	REG_DM_LNL:             "DM_LNL",
+	REG_DM_LNH:             "DM_LNH",
+	REG_LCC6:               "LCC6",
+	REG_LCC7:               "LCC7",
+	REG_BD50ST:             "BD50ST",
+	REG_BD60ST:             "BD60ST",
+	REG_HAECC1:             "HAECC1",
+	REG_HAECC2:             "HAECC2",
+	REG_SCALING_PCLK_DELAY: "SCALING_PCLK_DELAY",
+	REG_NT_CTRL:            "NT_CTRL",
+	REG_BD50MAX:            "BD50MAX",
+	REG_HAECC3:             "HAECC3",
+	REG_HAECC4:             "HAECC4",
+	REG_HAECC5:             "HAECC5",
+	REG_HAECC6:             "HAECC6",
+	REG_HAECC7:             "HAECC7",
+	REG_BD60MAX:            "BD60MAX",
+	REG_STR_OPT:            "STR_OPT",
+	REG_STR_R:              "STR_R",
+	REG_STR_G:              "STR_G",
+	REG_STR_B:              "STR_B",
+	REG_ABLC1:              "ABLC1",
+	REG_THL_ST:             "THL_ST",
+	REG_THL_DLT:            "THL_DLT",
+	REG_AD_CHB:             "AD_CHB",
+	REG_AD_CHR:             "AD_CHR",
+	REG_AD_CHGb:            "AD_CHGb",
+	REG_AD_CHGr:            "AD_CHGr",
+	REG_SATCTR:             "SATCTR",
+}
+
+/*
+ * @brief = Returns the datasheet name of a register.
+ * @param reg = Register address.
+ * @return = Name like "COM7", or "RSVD" followed by the address for reserved registers.
+ */
+func RegisterName(reg uint8) string {
+	if name, ok := registerNames[reg]; ok {
+		return name
+	}
+	return fmt.Sprintf("RSVD%02X", reg)
+}
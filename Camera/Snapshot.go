@@ -0,0 +1,352 @@
+package Camera7670
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+~ File Description:
+^ Snapshots of the whole register file 0x00 - 0xC9, to see what the sensor is really configured to and to save known good configurations.
+^ A snapshot serializes to text (one "0x12 COM7 = 0x14" line per register) and to JSON (an object of register names).
+*/
+
+// & Registers held by a snapshot, 0x00 up to SATCTR.
+const SNAPSHOT_REGISTERS = int(REG_SATCTR) + 1
+
+// & Registers the sensor updates itself, Restore does not write them.
+var readOnlyRegisters = map[uint8]bool{
+	REG_PID:   true,
+	REG_VER:   true,
+	REG_MIDH:  true,
+	REG_MIDL:  true,
+	REG_BAVE:  true,
+	REG_GbAVE: true,
+	REG_RAVE:  true,
+}
+
+/*
+ * @brief = Values of every register from 0x00 to 0xC9.
+ * @element Registers = Value of register i at index i.
+ */
+type Snapshot struct {
+	Registers [SNAPSHOT_REGISTERS]uint8
+}
+
+/*
+* @brief = Reads every register from 0x00 to 0xC9.
+* @return = The snapshot or the *RegisterError of the first read that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) Snapshot() (Snapshot, error) {
+	var snap Snapshot
+	for reg := range snap.Registers {
+		val, err := Cam.Read(uint8(reg))
+		if err != nil {
+			return Snapshot{}, &RegisterError{Register: uint8(reg), Operation: "read", Err: err}
+		}
+		snap.Registers[reg] = val
+	}
+
+	return snap, nil
+}
+
+func (snap Snapshot) String() string {
+	text, _ := snap.MarshalText()
+	return string(text)
+}
+
+// & OneLine Brief = Text form of the snapshot, one "0xAA NAME = 0xVV" line per register.
+func (snap Snapshot) MarshalText() ([]byte, error) {
+	var buf bytes.Buffer
+	for reg, val := range snap.Registers {
+		fmt.Fprintf(&buf, "0x%02X %-16s = 0x%02X\n", reg, RegisterName(uint8(reg)), val)
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+ * @brief = Reads the text form back, empty lines and lines starting with # are skipped.
+ * @param text = Lines of "0xAA NAME = 0xVV", the name is only checked against the address.
+ * @return = An error naming the line that can not be read or the first register that is missing.
+ */
+func (snap *Snapshot) UnmarshalText(text []byte) error {
+	var seen [SNAPSHOT_REGISTERS]bool
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) != 4 || fields[2] != "=" {
+			return fmt.Errorf("Snapshot line %d is not \"0xAA NAME = 0xVV\".", line)
+		}
+		reg, err_reg := strconv.ParseUint(fields[0], 0, 8)
+		val, err_val := strconv.ParseUint(fields[3], 0, 8)
+		if err_reg != nil || err_val != nil || int(reg) >= SNAPSHOT_REGISTERS {
+			return fmt.Errorf("Snapshot line %d has an invalid register or value.", line)
+		}
+		if fields[1] != RegisterName(uint8(reg)) {
+			return fmt.Errorf("Snapshot line %d names %s, register 0x%02X is %s.", line, fields[1], reg, RegisterName(uint8(reg)))
+		}
+		snap.Registers[reg] = uint8(val)
+		seen[reg] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return check_complete(seen)
+}
+
+// & OneLine Brief = JSON form of the snapshot, an object of register names to "0xVV" in address order.
+func (snap Snapshot) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for reg, val := range snap.Registers {
+		if reg > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "\n  %q: \"0x%02X\"", RegisterName(uint8(reg)), val)
+	}
+	buf.WriteString("\n}")
+	return buf.Bytes(), nil
+}
+
+// & OneLine Brief = Reads the JSON form back, every register must be present.
+func (snap *Snapshot) UnmarshalJSON(data []byte) error {
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	var seen [SNAPSHOT_REGISTERS]bool
+	for reg := range snap.Registers {
+		text, ok := values[RegisterName(uint8(reg))]
+		if !ok {
+			continue
+		}
+		val, err := strconv.ParseUint(text, 0, 8)
+		if err != nil {
+			return fmt.Errorf("Snapshot value %q of %s is not a byte.", text, RegisterName(uint8(reg)))
+		}
+		snap.Registers[reg] = uint8(val)
+		seen[reg] = true
+	}
+
+	return check_complete(seen)
+}
+
+// & OneLine Brief = Returns an error naming the first register a snapshot file did not give.
+func check_complete(seen [SNAPSHOT_REGISTERS]bool) error {
+	for reg, ok := range seen {
+		if !ok {
+			return fmt.Errorf("Snapshot is missing %s (0x%02X).", RegisterName(uint8(reg)), reg)
+		}
+	}
+	return nil
+}
+
+/*
+ * @brief = A register that differs between two snapshots.
+ * @element Register = Register address.
+ * @elements Before, After = Value in the first and the second snapshot.
+ */
+type RegisterChange struct {
+	Register uint8
+	Before   uint8
+	After    uint8
+}
+
+// & OneLine Brief = "COM7 (0x12): 0x14 -> 0x04", followed by the named fields that changed.
+func (c RegisterChange) String() string {
+	line := fmt.Sprintf("%s (0x%02X): 0x%02X -> 0x%02X", RegisterName(c.Register), c.Register, c.Before, c.After)
+	for _, field := range c.Fields() {
+		line += ", " + field
+	}
+	return line
+}
+
+/*
+ * @brief = Lists the named fields that changed.
+ * @return = One "Name: before -> after" entry per field, nil for registers without named fields.
+ */
+func (c RegisterChange) Fields() []string {
+	before := ParseField(c.Register, c.Before)
+	if before == nil {
+		return nil
+	}
+
+	var fields []string
+	old_value, new_value := reflect.ValueOf(before), reflect.ValueOf(ParseField(c.Register, c.After))
+	for i := 0; i < old_value.NumField(); i++ {
+		was, is := old_value.Field(i).Interface(), new_value.Field(i).Interface()
+		if was != is {
+			fields = append(fields, fmt.Sprintf("%s: %v -> %v", old_value.Type().Field(i).Name, was, is))
+		}
+	}
+	return fields
+}
+
+/*
+ * @brief = Compares two snapshots.
+ * @params before, after = Snapshots to compare.
+ * @return = The registers that differ, in address order.
+ */
+func DiffSnapshots(before, after Snapshot) []RegisterChange {
+	var changes []RegisterChange
+	for reg := range before.Registers {
+		if before.Registers[reg] != after.Registers[reg] {
+			changes = append(changes, RegisterChange{Register: uint8(reg), Before: before.Registers[reg], After: after.Registers[reg]})
+		}
+	}
+	return changes
+}
+
+// & OneLine Brief = Reports whether Restore writes a register, read only and reserved registers are skipped.
+func restorable(reg uint8) bool {
+	_, named := registerNames[reg]
+	return named && !readOnlyRegisters[reg]
+}
+
+/*
+* @brief = Writes a snapshot back, only the registers that differ from the sensor are written.
+* ^ Read only and reserved registers are skipped and a COM7 reset is never written. The writes go in the order of the
+* ^ Wake replay, and when the COM7 resolution changes every window register is written after it, equal or not.
+* ^ The cached configuration is rebuilt from the restored registers afterwards, see Adopt.
+* @param snap = Snapshot to restore.
+* @return = The *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) Restore(snap Snapshot) error {
+	current, err := Cam.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	// A COM7 resolution change presets the window, every window register has to be written after it.
+	resized := ParseCOM7(current.Registers[REG_COM7]).Resolution != ParseCOM7(snap.Registers[REG_COM7]).Resolution
+	var seq RegisterSequence
+	for reg, value := range snap.Registers {
+		if !restorable(uint8(reg)) {
+			continue
+		}
+		if value == current.Registers[reg] && !(resized && replay_step(uint8(reg)) == replay_window) {
+			continue
+		}
+		if uint8(reg) == REG_COM7 {
+			value &^= COM7{Reset: true}.Value()
+		}
+		seq = append(seq, RegisterWrite{uint8(reg), value, MASK_ALL, 0})
+	}
+	sort.SliceStable(seq, func(i, j int) bool {
+		return replay_step(seq[i].Register) < replay_step(seq[j].Register)
+	})
+	if err := Cam.ApplySequence(seq); err != nil {
+		return err
+	}
+	Cam.Adopt(snap)
+
+	return nil
+}
+
+/*
+ * @brief = Rebuilds the cached configuration of the camera from the registers of a snapshot, without writing anything.
+ * ^ ImageType, YUVOrder, Resolution, Frame, Window, Orientation, TestPattern and ClockDivider follow COM7, COM15, RGB444,
+ * ^ TSLB, COM13, the scaler and the window registers. The shadow becomes every register Restore writes, so Wake brings it all back.
+ * ^ GREYSCALED and YUV set the same registers, the camera stays GREYSCALED if it was, else it becomes YUV.
+ * ^ Sizes that match no standard resolution become CUSTOM. Saturation, hue and banding options are kept as they are.
+ * @param snap = Snapshot the sensor holds, for eg. one taken with Snapshot or just written with Restore.
+ */
+func (Cam *OV7670) Adopt(snap Snapshot) {
+	regs := snap.Registers
+	com7 := ParseCOM7(regs[REG_COM7])
+	com15 := ParseCOM15(regs[REG_COM15])
+	rgb444 := ParseRGB444(regs[REG_RGB444])
+	switch {
+	case com7.Format&COM7_RAW_BAYER != 0:
+		Cam.imageType = BAYER
+	case com7.Format == COM7_RGB && com15.RGB == COM15_RGB565 && rgb444.Enable && rgb444.WordFormatRGBx:
+		Cam.imageType = RGB444_RGBX
+	case com7.Format == COM7_RGB && com15.RGB == COM15_RGB565 && rgb444.Enable:
+		Cam.imageType = RGB444_XRGB
+	case com7.Format == COM7_RGB && com15.RGB == COM15_RGB555:
+		Cam.imageType = RGB555
+	case com7.Format == COM7_RGB:
+		Cam.imageType = RGB
+	case Cam.imageType != GREYSCALED:
+		Cam.imageType = YUV
+	}
+
+	uvFirst, uvSwap := ParseTSLB(regs[REG_TSLB]).UVFirst, ParseCOM13(regs[REG_COM13]).UVSwap
+	for _, order := range []YUV_ORDER{YUYV, YVYU, UYVY, VYUY} {
+		if (order == UYVY || order == VYUY) == uvFirst && (order == YVYU || order == VYUY) == uvSwap {
+			Cam.yuvOrder = order
+		}
+	}
+
+	mvfp := ParseMVFP(regs[REG_MVFP])
+	Cam.orientation = Orientation{Mirror: mvfp.Mirror, Flip: mvfp.VFlip}
+	Cam.testPattern = ParseTestPattern(regs[REG_SCALING_XSC], regs[REG_SCALING_YSC], regs[REG_COM17])
+	Cam.clock = ParseCLKRC(regs[REG_CLKRC])
+
+	// Same decoding as the sensor: COM7 size, down sampler, fractional scaler and then the window.
+	var h_rate, v_rate uint8
+	com3 := ParseCOM3(regs[REG_COM3])
+	if com3.DCWEnable && ParseCOM14(regs[REG_COM14]).ManualScaling {
+		dcw := ParseSCALING_DCWCTR(regs[REG_SCALING_DCWCTR])
+		h_rate, v_rate = dcw.HorizontalRate, dcw.VerticalRate
+	}
+	Cam.frame = ScaledFormat(com7.Resolution, h_rate, v_rate)
+	if com3.ScaleEnable {
+		Cam.frame = Cam.frame.Zoomed(regs[REG_SCALING_XSC], regs[REG_SCALING_YSC])
+	}
+	Cam.resolution = CUSTOM
+	for _, res := range []RESOLUTION{VGA, QVGA, QQVGA, CIF, QCIF, QQCIF} {
+		if frameFormats[res] == Cam.frame {
+			Cam.resolution = res
+		}
+	}
+	Cam.window = snap.window(Cam.frame.Oriented(Cam.orientation))
+
+	Cam.shadow = nil
+	for reg, value := range regs {
+		if restorable(uint8(reg)) {
+			if uint8(reg) == REG_COM7 {
+				value &^= COM7{Reset: true}.Value()
+			}
+			Cam.shadow = append(Cam.shadow, RegisterWrite{uint8(reg), value, MASK_ALL, 0})
+		}
+	}
+}
+
+/*
+ * @brief = Reads the window back out of HSTART/HSTOP/HREF and VSTRT/VSTOP/VREF, the inverse of windowSequence.
+ * @param format = Whole frame the window is cut out of, in the orientation of the readout.
+ * @return = Window in output pixels, clipped to the frame.
+ */
+func (snap Snapshot) window(format FrameFormat) Window {
+	regs := snap.Registers
+	href, vref := int(regs[REG_HREF]), int(regs[REG_VREF])
+	hstart := int(regs[REG_HSTART])<<3 | href&0x07
+	hstop := int(regs[REG_HSTOP])<<3 | (href>>3)&0x07
+	vstart := int(regs[REG_VSTRT])<<2 | vref&0x03
+	vstop := int(regs[REG_VSTOP])<<2 | (vref>>2)&0x03
+
+	hspan := (hstop - hstart + SENSOR_LINE_PIXELS) % SENSOR_LINE_PIXELS
+	vspan := max(vstop-vstart, 0)
+
+	var win Window
+	win.X = min(max(hstart-format.OriginX, 0)*format.Width/format.SpanX, format.Width)
+	win.Y = min(max(vstart-format.OriginY, 0)*format.Height/format.SpanY, format.Height)
+	win.Width = min(hspan*format.Width/format.SpanX, format.Width-win.X)
+	win.Height = min(vspan*format.Height/format.SpanY, format.Height-win.Y)
+	return win
+}
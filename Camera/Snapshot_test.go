@@ -0,0 +1,234 @@
+package Camera7670
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// & OneLine Brief = Cached configuration of a camera, to compare it before and after a Restore.
+type cachedState struct {
+	image       IMAGE
+	order       YUV_ORDER
+	resolution  RESOLUTION
+	frame       FrameFormat
+	window      Window
+	orientation Orientation
+	clock       CLKRC
+}
+
+func stateOf(Cam *OV7670) cachedState {
+	return cachedState{Cam.ImageType(), Cam.YUVOrder(), Cam.Resolution(), Cam.Frame(), Cam.Window(), Cam.Orientation(), Cam.ClockDivider()}
+}
+
+func TestRestoreRebuildsState(t *testing.T) {
+	setups := map[string]func(Cam *OV7670) error{
+		"RGB QVGA window mirrored": func(Cam *OV7670) error {
+			if err := Cam.Configure(RGB, QVGA); err != nil {
+				return err
+			}
+			if err := Cam.SetOrientation(true, false); err != nil {
+				return err
+			}
+			if err := Cam.SetPCLKSpeed(PCLK_DIV2); err != nil {
+				return err
+			}
+			return Cam.SetWindow(20, 16, 160, 120)
+		},
+		"YUV UYVY QCIF": func(Cam *OV7670) error {
+			if err := Cam.SetYUVOrder(UYVY); err != nil {
+				return err
+			}
+			return Cam.Configure(YUV, QCIF)
+		},
+		"RGB444 custom": func(Cam *OV7670) error {
+			return Cam.ConfigureCustom(RGB444_RGBX, 200, 150)
+		},
+	}
+
+	for name, setup := range setups {
+		bus := newFakeBus()
+		Cam := newFakeCamera(bus, &fakeClock{})
+		if err := Cam.Initialize(20_000_000); err != nil {
+			t.Fatalf("Initialize: %v", err)
+		}
+		if err := setup(Cam); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := stateOf(Cam)
+		snap, err := Cam.Snapshot()
+		if err != nil {
+			t.Fatalf("%s: Snapshot: %v", name, err)
+		}
+
+		if err := Cam.Configure(RGB555, QQVGA); err != nil {
+			t.Fatalf("%s: Configure: %v", name, err)
+		}
+		if err := Cam.Restore(snap); err != nil {
+			t.Fatalf("%s: Restore: %v", name, err)
+		}
+		if got := stateOf(Cam); got != want {
+			t.Errorf("%s: state after Restore = %+v, want %+v", name, got, want)
+		}
+		if len(Cam.Shadow()) < len(initializeSequence) {
+			t.Errorf("%s: shadow holds %d registers after Restore, want the whole snapshot", name, len(Cam.Shadow()))
+		}
+	}
+}
+
+func TestRestoreWritesWindowAfterCOM7(t *testing.T) {
+	bus := newFakeBus()
+	Cam := newFakeCamera(bus, &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := Cam.Configure(RGB, QCIF); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	snap, err := Cam.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := Cam.Configure(RGB, VGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	// Equal on the bus before the restore, the sensor presets it on the COM7 write all the same.
+	bus.registers[REG_VSTRT] = snap.Registers[REG_VSTRT]
+
+	bus.writes = nil
+	if err := Cam.Restore(snap); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	com7 := bus.lastWrite(REG_COM7)
+	if com7 < 0 {
+		t.Fatalf("Restore did not write COM7")
+	}
+	for _, reg := range []uint8{REG_HSTART, REG_HSTOP, REG_HREF, REG_VSTRT, REG_VSTOP, REG_VREF} {
+		if bus.firstWrite(reg) < com7 {
+			t.Errorf("%s written at %d, before COM7 at %d", RegisterName(reg), bus.firstWrite(reg), com7)
+		}
+	}
+	if bus.firstWrite(REG_COM15) > com7 {
+		t.Errorf("COM15 written after COM7, want the format first")
+	}
+
+	// Without a resolution change only the registers that differ are written.
+	bus.writes = nil
+	if err := Cam.Restore(snap); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if len(bus.writes) != 0 {
+		t.Errorf("Restore of the snapshot the sensor holds wrote %d registers", len(bus.writes))
+	}
+}
+
+// & OneLine Brief = Snapshot of a camera configured for RGB at QVGA.
+func configuredSnapshot(t *testing.T) Snapshot {
+	t.Helper()
+	Cam := newFakeCamera(newFakeBus(), &fakeClock{})
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := Cam.Configure(RGB, QVGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	snap, err := Cam.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	return snap
+}
+
+func TestSnapshotText(t *testing.T) {
+	snap := configuredSnapshot(t)
+	text, err := snap.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "0x12 COM7             = 0x04\n") {
+		t.Errorf("text form has no COM7 line of RGB VGA:\n%s", text)
+	}
+
+	var back Snapshot
+	if err := back.UnmarshalText(append([]byte("# saved configuration\n\n"), text...)); err != nil {
+		t.Fatalf("UnmarshalText: %v", err)
+	}
+	if back != snap {
+		t.Errorf("text round trip changed %v", DiffSnapshots(snap, back))
+	}
+
+	lines := strings.Split(string(text), "\n")
+	missing := strings.Join(append(append([]string(nil), lines[:0x12]...), lines[0x13:]...), "\n")
+	if err := back.UnmarshalText([]byte(missing)); err == nil || !strings.Contains(err.Error(), "missing COM7") {
+		t.Errorf("UnmarshalText without COM7 = %v, want a missing COM7 error", err)
+	}
+	renamed := strings.Replace(string(text), "0x12 COM7 ", "0x12 COM8 ", 1)
+	if err := back.UnmarshalText([]byte(renamed)); err == nil || !strings.Contains(err.Error(), "names COM8") {
+		t.Errorf("UnmarshalText of COM8 at 0x12 = %v, want a name error", err)
+	}
+	if err := back.UnmarshalText([]byte("0x12 COM7 0x04\n")); err == nil {
+		t.Errorf("UnmarshalText of a line without = returned nil")
+	}
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	snap := configuredSnapshot(t)
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back Snapshot
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if back != snap {
+		t.Errorf("JSON round trip changed %v", DiffSnapshots(snap, back))
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatal(err)
+	}
+	if values["COM7"] != "0x04" {
+		t.Errorf("JSON COM7 = %q, want \"0x04\"", values["COM7"])
+	}
+	delete(values, "COM7")
+	missing, _ := json.Marshal(values)
+	if err := json.Unmarshal(missing, &back); err == nil || !strings.Contains(err.Error(), "missing COM7") {
+		t.Errorf("UnmarshalJSON without COM7 = %v, want a missing COM7 error", err)
+	}
+	values["COM7"] = "0x104"
+	wide, _ := json.Marshal(values)
+	if err := json.Unmarshal(wide, &back); err == nil {
+		t.Errorf("UnmarshalJSON of COM7 = 0x104 returned nil")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	before := configuredSnapshot(t)
+	after := before
+	after.Registers[REG_COM7] = COM7{Resolution: COM7_QVGA, Format: COM7_RGB}.Value()
+	after.Registers[REG_MVFP] = before.Registers[REG_MVFP] | MVFP{Mirror: true}.Value()
+	after.Registers[REG_BRIGHT] = 0x20
+
+	changes := DiffSnapshots(before, after)
+	if len(changes) != 3 || changes[0].Register != REG_COM7 || changes[1].Register != REG_MVFP || changes[2].Register != REG_BRIGHT {
+		t.Fatalf("DiffSnapshots = %v, want COM7, MVFP and BRIGHT in address order", changes)
+	}
+	if got := changes[0].String(); got != "COM7 (0x12): 0x04 -> 0x14, Resolution: VGA -> QVGA" {
+		t.Errorf("COM7 change = %q", got)
+	}
+	if fields := changes[1].Fields(); len(fields) != 1 || fields[0] != "Mirror: false -> true" {
+		t.Errorf("MVFP fields = %v, want only Mirror", fields)
+	}
+	if fields := changes[2].Fields(); fields != nil {
+		t.Errorf("BRIGHT fields = %v, want none for a register without named fields", fields)
+	}
+	if got := changes[2].String(); got != "BRIGHT (0x55): 0x00 -> 0x20" {
+		t.Errorf("BRIGHT change = %q", got)
+	}
+	if DiffSnapshots(before, before) != nil {
+		t.Errorf("DiffSnapshots of equal snapshots is not empty")
+	}
+}
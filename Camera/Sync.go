@@ -0,0 +1,150 @@
+package Camera7670
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/*
+~ File Description:
+^ Waits on VSync, HSync (HREF) and PCLK that give up when a context is cancelled or its deadline passes.
+^ The context is only checked every DEADLINE_POLLS polls so a wait that is answered right away costs one pin read more than the plain WaitFor functions.
+^ The deadline is compared with the time directly, TinyGO does not run the context timer while the capture loop spins.
+*/
+
+// & Capture timeouts.
+const (
+	DEFAULT_CAPTURE_TIMEOUT = 10 * time.Second // Longer than a frame at the slowest clock with night mode.
+	DEADLINE_POLLS          = 256              // Pin polls between two checks of the context.
+)
+
+// & Sensor outputs the driver waits on.
+type SIGNAL int
+
+const (
+	SIGNAL_VSYNC = iota
+	SIGNAL_HREF
+	SIGNAL_PCLK
+)
+
+func (s SIGNAL) String() string {
+	switch s {
+	case SIGNAL_VSYNC:
+		return "VSYNC"
+	case SIGNAL_HREF:
+		return "HREF"
+	case SIGNAL_PCLK:
+		return "PCLK"
+	}
+
+	return "NOT VALID"
+}
+
+/*
+ * @brief = Error of a wait that did not see its signal in time.
+ * @element Signal = Signal that stalled.
+ * @element Level = Level that was waited for, true for high.
+ * @element Err = context.DeadlineExceeded or context.Canceled.
+ */
+type TimeoutError struct {
+	Signal SIGNAL
+	Level  bool
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	level := "low"
+	if e.Level {
+		level = "high"
+	}
+	return fmt.Sprintf("%s did not go %s: %v", e.Signal.String(), level, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+/*
+ * @brief = Creates the context of one capture from Timeout.
+ * @return = A context with a deadline Timeout from now, or one without a deadline when Timeout is 0, and its cancel function.
+ */
+func (Cam *OV7670) CaptureContext() (context.Context, context.CancelFunc) {
+	if Cam.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), Cam.Timeout)
+}
+
+// & OneLine Brief = Returns the input pin of a signal.
+func (Cam *OV7670) signal_pin(sig SIGNAL) InputPin {
+	switch sig {
+	case SIGNAL_VSYNC:
+		return Cam.VSync
+	case SIGNAL_HREF:
+		return Cam.HSync
+	}
+	return Cam.PCLK
+}
+
+/*
+ * @brief = Checks if a wait has to give up, for loops that poll more than one signal.
+ * @params sig, level = Signal and level waited for, they name the *TimeoutError.
+ * @return = The *TimeoutError when ctx is cancelled or past its deadline, else nil.
+ */
+func Expired(ctx context.Context, sig SIGNAL, level bool) error {
+	if err := ctx.Err(); err != nil {
+		return &TimeoutError{Signal: sig, Level: level, Err: err}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Now().After(deadline) {
+		return &TimeoutError{Signal: sig, Level: level, Err: context.DeadlineExceeded}
+	}
+	return nil
+}
+
+/*
+* @brief = Halts the processor till a signal is at a level, or ctx ends.
+* @param sig = Signal to watch.
+* @param level = Level to wait for, true for high.
+* @return = *TimeoutError naming the signal if ctx is cancelled or its deadline passes first.
+! Handle Error.
+*/
+func (Cam *OV7670) WaitForSignal(ctx context.Context, sig SIGNAL, level bool) error {
+	pin := Cam.signal_pin(sig)
+	for polls := 1; pin.Get() != level; polls++ {
+		if polls%DEADLINE_POLLS == 0 {
+			if err := Expired(ctx, sig, level); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// & OneLine Brief = WaitForNewFrame that gives up with a *TimeoutError when ctx ends.
+func (Cam *OV7670) WaitForNewFrameContext(ctx context.Context) error {
+	if err := Cam.WaitForSignal(ctx, SIGNAL_VSYNC, false); err != nil {
+		return err
+	}
+	return Cam.WaitForSignal(ctx, SIGNAL_VSYNC, true)
+}
+
+// & OneLine Brief = WaitForPixelClockLow (PCLK becomes High) that gives up with a *TimeoutError when ctx ends.
+func (Cam *OV7670) WaitForPixelClockLowContext(ctx context.Context) error {
+	return Cam.WaitForSignal(ctx, SIGNAL_PCLK, true)
+}
+
+// & OneLine Brief = WaitForPixelClockHigh (PCLK becomes Low) that gives up with a *TimeoutError when ctx ends.
+func (Cam *OV7670) WaitForPixelClockHighContext(ctx context.Context) error {
+	return Cam.WaitForSignal(ctx, SIGNAL_PCLK, false)
+}
+
+// & OneLine Brief = WaitForHorizontalSyncHigh (HSync becomes Low) that gives up with a *TimeoutError when ctx ends.
+func (Cam *OV7670) WaitForHorizontalSyncHighContext(ctx context.Context) error {
+	return Cam.WaitForSignal(ctx, SIGNAL_HREF, false)
+}
+
+// & OneLine Brief = WaitForHorizontalSyncLow (HSync becomes High) that gives up with a *TimeoutError when ctx ends.
+func (Cam *OV7670) WaitForHorizontalSyncLowContext(ctx context.Context) error {
+	return Cam.WaitForSignal(ctx, SIGNAL_HREF, true)
+}
@@ -0,0 +1,156 @@
+package Camera7670
+
+import "fmt"
+
+/*
+~ File Description:
+^ Built-in test patterns of the sensor, selected through SCALING_XSC[7], SCALING_YSC[7] and COM17[3].
+^ The patterns replace the image after the readout, they are not mirrored or flipped by MVFP.
+*/
+
+type TEST_PATTERN int
+
+const (
+	TEST_PATTERN_OFF           = iota
+	TEST_PATTERN_SHIFTING_ONES // Every data byte has a single bit set that walks across D[7:0].
+	TEST_PATTERN_COLOR_BARS    // 8 vertical bars.
+	TEST_PATTERN_FADE_TO_GREY  // 8 vertical bars fading to grey towards the bottom.
+	TEST_PATTERN_DSP_BARS      // 8 vertical bars inserted by the DSP (COM17).
+)
+
+func (p TEST_PATTERN) String() string {
+	switch p {
+	case TEST_PATTERN_OFF:
+		return "OFF"
+	case TEST_PATTERN_SHIFTING_ONES:
+		return "SHIFTING ONES"
+	case TEST_PATTERN_COLOR_BARS:
+		return "COLOR BARS"
+	case TEST_PATTERN_FADE_TO_GREY:
+		return "FADE TO GREY"
+	case TEST_PATTERN_DSP_BARS:
+		return "DSP BARS"
+	}
+
+	return "NOT VALID"
+}
+
+// & Colours of the 8 bars from left to right: white, yellow, cyan, green, magenta, red, blue, black.
+var COLOR_BARS = [8][3]uint8{
+	{0xFF, 0xFF, 0xFF},
+	{0xFF, 0xFF, 0x00},
+	{0x00, 0xFF, 0xFF},
+	{0x00, 0xFF, 0x00},
+	{0xFF, 0x00, 0xFF},
+	{0xFF, 0x00, 0x00},
+	{0x00, 0x00, 0xFF},
+	{0x00, 0x00, 0x00},
+}
+
+// & OneLine Brief = Index of the bar at pixel x of a whole frame of the given width.
+func ColorBar(x, width int) int {
+	return min(max(x*len(COLOR_BARS)/width, 0), len(COLOR_BARS)-1)
+}
+
+/*
+ * @brief = Gets the colour of a bar pattern at a pixel of the whole frame.
+ * @params x, y = Pixel in the whole frame.
+ * @params width, height = Size of the whole frame.
+ * @return = r, g, b values, black for the patterns that are not bars.
+ */
+func (p TEST_PATTERN) Pixel(x, y, width, height int) (uint8, uint8, uint8) {
+	bar := COLOR_BARS[ColorBar(x, width)]
+	switch p {
+	case TEST_PATTERN_COLOR_BARS, TEST_PATTERN_DSP_BARS:
+		return bar[0], bar[1], bar[2]
+	case TEST_PATTERN_FADE_TO_GREY:
+		fade := func(c uint8) uint8 {
+			return uint8(int(c) + (0x80-int(c))*y/max(height-1, 1))
+		}
+		return fade(bar[0]), fade(bar[1]), fade(bar[2])
+	}
+
+	return 0, 0, 0
+}
+
+/*
+ * @brief = Gets a byte of the shifting ones pattern.
+ * ^ The layout is counted from the first byte of the first line of the whole frame, a window only shows a part of it.
+ * ^ This is the layout the driver expects, it is not confirmed on hardware: with D[7:0] known to be wired in order,
+ * ^ a calibration whose Order is rotated by the same amount on every line means the sensor starts the walk at another bit.
+ * @param index = Byte index inside the line of the whole frame.
+ * @param row = Line of the whole frame.
+ * @return = A byte with bit (index + row) % 8 set.
+ */
+func ShiftingOnes(index, row int) uint8 {
+	return 1 << ((index + row) % 8)
+}
+
+/*
+ * @brief = Writes of the pattern select bits, the scale factors and the rest of COM17 are kept.
+ * ^ SCALING_XSC[7] and SCALING_YSC[7] form a 2 bit code: 00 off, 01 shifting ones, 10 colour bars, 11 fade to grey.
+ */
+func (p TEST_PATTERN) sequence() RegisterSequence {
+	var code, dsp uint8
+	switch p {
+	case TEST_PATTERN_SHIFTING_ONES:
+		code = 0x01
+	case TEST_PATTERN_COLOR_BARS:
+		code = 0x02
+	case TEST_PATTERN_FADE_TO_GREY:
+		code = 0x03
+	case TEST_PATTERN_DSP_BARS:
+		dsp = 0x08
+	}
+
+	return RegisterSequence{
+		{REG_SCALING_XSC, (code & 0x01) << 7, 0x80, 0},
+		{REG_SCALING_YSC, (code & 0x02) << 6, 0x80, 0},
+		{REG_COM17, dsp, 0x08, 0},
+	}
+}
+
+/*
+ * @brief = Decodes the active test pattern from the registers.
+ * @params xsc, ysc, com17 = Values of SCALING_XSC, SCALING_YSC and COM17.
+ * @return = The test pattern, the XSC/YSC code wins over the DSP bars.
+ */
+func ParseTestPattern(xsc, ysc, com17 uint8) TEST_PATTERN {
+	switch xsc>>7 | (ysc>>7)<<1 {
+	case 0x01:
+		return TEST_PATTERN_SHIFTING_ONES
+	case 0x02:
+		return TEST_PATTERN_COLOR_BARS
+	case 0x03:
+		return TEST_PATTERN_FADE_TO_GREY
+	}
+	if com17&0x08 != 0 {
+		return TEST_PATTERN_DSP_BARS
+	}
+
+	return TEST_PATTERN_OFF
+}
+
+/*
+* @brief = Replaces the image with a test pattern, to check the wiring, the data pin order and the PCLK divider.
+* @param kind = Pattern to output, TEST_PATTERN_OFF goes back to the image.
+* @return = returns an error if the pattern is not valid or the *RegisterError of the register that failed.
+! Handle Error.
+*/
+func (Cam *OV7670) SetTestPattern(kind TEST_PATTERN) error {
+	if kind.String() == "NOT VALID" {
+		return fmt.Errorf("Not a valid test pattern. Value = %d", kind)
+	}
+
+	if err := Cam.ApplySequence(kind.sequence()); err != nil {
+		return err
+	}
+	Cam.testPattern = kind
+
+	return nil
+}
+
+// & OneLine Brief = Returns the test pattern the camera outputs instead of the image.
+func (Cam *OV7670) TestPattern() TEST_PATTERN {
+	return Cam.testPattern
+}
@@ -0,0 +1,119 @@
+//go:build tinygo
+
+package Camera7670
+
+import (
+	"fmt"
+	"machine"
+)
+
+/*
+~ File Description:
+^ TinyGO adapters that wrap the machine types into the interfaces of Hardware.go.
+*/
+
+// & Compile time checks that the machine types satisfy the driver interfaces.
+var (
+	_ RegisterBus = (*machine.I2C)(nil)
+	_ InputPin    = machine.Pin(0)
+	_ OutputPin   = machine.Pin(0)
+	_ DataPort    = (*PArray)(nil)
+	_ ClockSource = (*PWMClock)(nil)
+)
+
+// & Subset of the machine PWM Group used to generate MCLK.
+type pwmPeripheral interface {
+	Configure(config machine.PWMConfig) error
+	Channel(pin machine.Pin) (uint8, error)
+	Top() uint32
+	Set(channel uint8, value uint32)
+}
+
+/*
+ * @brief = ClockSource which generates MCLK from a PWM Enabled Pin.
+ * @element Pin = PWM Enabled Pin connected to XCLK of OV7670.
+ */
+type PWMClock struct {
+	Pin machine.Pin
+}
+
+/*
+ * @brief = Creates a pointer to a PWMClock.
+ * @param pin = PWM Enabled Pin connected to XCLK of OV7670.
+ * @return = pointer of a PWMClock Object.
+ */
+func CreatePWMClock(pin machine.Pin) *PWMClock {
+	return &PWMClock{Pin: pin}
+}
+
+// & OneLine Brief = PWM slice driving the pin, nil if the pin has none.
+func (clk *PWMClock) peripheral() pwmPeripheral {
+	slice, _ := machine.PWMPeripheral(clk.Pin)
+	switch slice {
+	case 0:
+		return machine.PWM0
+	case 1:
+		return machine.PWM1
+	case 2:
+		return machine.PWM2
+	case 3:
+		return machine.PWM3
+	case 4:
+		return machine.PWM4
+	case 5:
+		return machine.PWM5
+	case 6:
+		return machine.PWM6
+	case 7:
+		return machine.PWM7
+	}
+
+	return nil
+}
+
+/*
+ * @brief = Starts a 50% duty cycle clock on the pin.
+ * @param frequency = Frequency on the PWM pin. Directly changes the speed of camera.
+ * @return = returns an error if the pin has no PWM slice or the slice can not be configured.
+ */
+func (clk *PWMClock) Start(frequency uint64) error {
+	if frequency == 0 {
+		return fmt.Errorf("Invalid MCLK Frequency - Failed to create MCLK Signal.")
+	}
+
+	pwm := clk.peripheral()
+	if pwm == nil {
+		return fmt.Errorf("Invalid PWM Pin - Failed to create MCLK Signal.")
+	}
+
+	if err := pwm.Configure(machine.PWMConfig{Period: uint64(1e9 / frequency)}); err != nil {
+		return err
+	}
+
+	ch, err := pwm.Channel(clk.Pin)
+	if err != nil {
+		return err
+	}
+	pwm.Set(ch, pwm.Top()/2)
+
+	return nil
+}
+
+/*
+ * @brief = Stops the clock, the pin stays low with a 0% duty cycle until Start.
+ * @return = returns an error if the pin has no PWM slice.
+ */
+func (clk *PWMClock) Stop() error {
+	pwm := clk.peripheral()
+	if pwm == nil {
+		return fmt.Errorf("Invalid PWM Pin - Failed to stop MCLK Signal.")
+	}
+
+	ch, err := pwm.Channel(clk.Pin)
+	if err != nil {
+		return err
+	}
+	pwm.Set(ch, 0)
+
+	return nil
+}
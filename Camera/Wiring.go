@@ -0,0 +1,222 @@
+package Camera7670
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+/*
+~ File Description:
+^ Bring-up checks of the wiring: the data pins are 8 distinct GPIOs that no other signal uses, and D[7:0] are in order.
+^ The order is found from the shifting ones test pattern: every byte should have bit (index + row) % 8 set, the bit that is set instead tells where that data line is wired.
+^ Works on GPIO numbers only, PArray passes its pins in on target and the simulator on a host.
+*/
+
+// & Lines captured for a calibration, 8 puts every data line on every byte index.
+const CALIBRATION_LINES = 8
+
+/*
+ * @brief = A GPIO used by a signal of the camera or the board.
+ * @element Name = Name of the signal, "VSYNC", "PCLK", "SDA" ...
+ * @element GPIO = GPIO number.
+ */
+type PinUse struct {
+	Name string
+	GPIO uint8
+}
+
+/*
+ * @brief = Error of a GPIO that two signals are wired to.
+ * @element GPIO = GPIO number.
+ * @elements First, Second = Names of the signals, data pins are named D0 to D7.
+ */
+type PinConflictError struct {
+	GPIO   uint8
+	First  string
+	Second string
+}
+
+func (e *PinConflictError) Error() string {
+	return fmt.Sprintf("GPIO%d is used by both %s and %s.", e.GPIO, e.First, e.Second)
+}
+
+/*
+* @brief = Checks the data pins: exactly 8 distinct GPIOs, none of them used by another signal.
+* @param data = GPIO number of every data pin, data[i] is D[i].
+* @param others = GPIOs of the other signals, VSync, HREF, PCLK, MCLK, the SCCB bus ...
+* @return = An error if there are not 8 pins, or the *PinConflictError of the first GPIO used twice.
+! Handle Error.
+*/
+func CheckDataPins(data []uint8, others ...PinUse) error {
+	if len(data) != 8 {
+		return fmt.Errorf("The data port needs exactly 8 pins (D0 - D7), got %d.", len(data))
+	}
+
+	used := make(map[uint8]string, len(data)+len(others))
+	for index, gpio := range data {
+		name := fmt.Sprintf("D%d", index)
+		if first, ok := used[gpio]; ok {
+			return &PinConflictError{GPIO: gpio, First: first, Second: name}
+		}
+		used[gpio] = name
+	}
+	for _, other := range others {
+		if first, ok := used[other.GPIO]; ok {
+			return &PinConflictError{GPIO: other.GPIO, First: first, Second: other.Name}
+		}
+		used[other.GPIO] = other.Name
+	}
+
+	return nil
+}
+
+/*
+ * @brief = Result of a calibration with the shifting ones test pattern.
+ * @element Bytes = Bytes compared.
+ * @element Order = Order[i] is the bit of the read byte where sensor line D[i] arrives, -1 if it was never seen.
+ * @element StuckLow = Bits of the read byte that never went high.
+ * @element StuckHigh = Bits of the read byte that were high in every byte.
+ * @element Mismatches = Bytes that do not match the pattern even with Order applied.
+ */
+type PinCalibration struct {
+	Bytes      int
+	Order      [8]int
+	StuckLow   uint8
+	StuckHigh  uint8
+	Mismatches int
+}
+
+// & OneLine Brief = Reports whether every data line arrives on its own bit, with a few mismatches allowed for noise.
+func (c PinCalibration) Mapped() bool {
+	var seen uint8
+	for _, bit := range c.Order {
+		if bit < 0 || seen&(1<<bit) != 0 {
+			return false
+		}
+		seen |= 1 << bit
+	}
+	return c.StuckLow == 0 && c.StuckHigh == 0 && c.Mismatches*100 < c.Bytes
+}
+
+// & OneLine Brief = Reports whether the pins are wired in order and every byte matched.
+func (c PinCalibration) Correct() bool {
+	for line, bit := range c.Order {
+		if bit != line {
+			return false
+		}
+	}
+	return c.Mapped()
+}
+
+func (c PinCalibration) String() string {
+	if c.Correct() {
+		return fmt.Sprintf("D0 - D7 in order, %d bytes matched", c.Bytes)
+	}
+
+	var parts []string
+	first := [8]int{-1, -1, -1, -1, -1, -1, -1, -1}
+	for line, bit := range c.Order {
+		switch {
+		case bit < 0:
+			parts = append(parts, fmt.Sprintf("D%d not seen", line))
+		case first[bit] >= 0:
+			parts = append(parts, fmt.Sprintf("D%d and D%d both arrive on bit %d", first[bit], line, bit))
+		case bit != line:
+			parts = append(parts, fmt.Sprintf("D%d arrives on bit %d", line, bit))
+		}
+		if bit >= 0 && first[bit] < 0 {
+			first[bit] = line
+		}
+	}
+	if c.StuckLow != 0 {
+		parts = append(parts, fmt.Sprintf("bits %08b stuck low", c.StuckLow))
+	}
+	if c.StuckHigh != 0 {
+		parts = append(parts, fmt.Sprintf("bits %08b stuck high", c.StuckHigh))
+	}
+	return fmt.Sprintf("%s, %d of %d bytes do not match", strings.Join(parts, ", "), c.Mismatches, c.Bytes)
+}
+
+/*
+ * @brief = Works out the order of the data lines from lines captured with TEST_PATTERN_SHIFTING_ONES.
+ * ^ Experimental, it relies on the layout of ShiftingOnes that is not confirmed on hardware.
+ * @param lines = Raw bytes of the lines as read, every byte of the line clocked (both bytes of a pixel).
+ * @param first_row = Frame row of lines[0], every line has to start at the first byte of the frame line.
+ * @return = The calibration, Order holds the read bit every sensor line was seen on most.
+ */
+func CalibratePins(lines [][]byte, first_row int) PinCalibration {
+	var c PinCalibration
+	var votes [8][8]int
+	var expected [8]int
+	var high [8]int
+
+	for offset, line := range lines {
+		for index, read := range line {
+			line_bit := bits.TrailingZeros8(ShiftingOnes(index, first_row+offset))
+			expected[line_bit]++
+			for bit := 0; bit < 8; bit++ {
+				if read&(1<<bit) != 0 {
+					votes[line_bit][bit]++
+					high[bit]++
+				}
+			}
+			c.Bytes++
+		}
+	}
+
+	for bit := 0; bit < 8; bit++ {
+		switch {
+		case high[bit] == 0:
+			c.StuckLow |= 1 << bit
+		case c.Bytes > 0 && high[bit] == c.Bytes:
+			c.StuckHigh |= 1 << bit
+		}
+	}
+
+	for line := range c.Order {
+		c.Order[line] = -1
+		best := 0
+		for bit := 0; bit < 8; bit++ {
+			if c.StuckHigh&(1<<bit) == 0 && votes[line][bit] > best {
+				c.Order[line], best = bit, votes[line][bit]
+			}
+		}
+		// A line has to win most of its bytes, else it is noise or another fault.
+		if best*2 <= expected[line] {
+			c.Order[line] = -1
+		}
+	}
+
+	for offset, line := range lines {
+		for index, read := range line {
+			line_bit := bits.TrailingZeros8(ShiftingOnes(index, first_row+offset))
+			if c.Order[line_bit] < 0 || read != 1<<c.Order[line_bit] {
+				c.Mismatches++
+			}
+		}
+	}
+
+	return c
+}
+
+/*
+ * @brief = Suggests the pin list that puts the data lines in order.
+ * @param c = Calibration made with pins.
+ * @param pins = Pins in the order they were given, pins[i] read into bit i.
+ * @return = The pins with result[i] being the pin D[i] is wired to, or an error if the calibration did not map every line.
+ */
+func ReorderPins[T any](c PinCalibration, pins []T) ([]T, error) {
+	if !c.Mapped() {
+		return nil, fmt.Errorf("Calibration did not map every data line: %s", c.String())
+	}
+	if len(pins) != len(c.Order) {
+		return nil, fmt.Errorf("Calibration is for 8 pins, got %d.", len(pins))
+	}
+
+	ordered := make([]T, len(pins))
+	for line, bit := range c.Order {
+		ordered[line] = pins[bit]
+	}
+	return ordered, nil
+}
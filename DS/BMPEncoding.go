@@ -1,112 +1,129 @@
 package DataStructures
 
-import Camera7670 "PICO_OV7670/Camera"
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"fmt"
+)
 
 /*
  * @brief = ImageStream is a object created to easily get an bmp encoded image from CameraImage which stores raw image data.
  * @element data = Stores the raw image data.
  * @element current_index = Used to determine the data we want to send.
  * @elements Resolution, ImageType = Used to determine the type of image.
+ * @element YUVOrder = Byte order of the pixels when ImageType is YUV.
+ * @element Orientation = Orientation of the image, tagged in the BMP header (see BmpOrientation).
+ * @element header = BMP header matching the size of the image.
+ * @element width = Pixels per row, every row is padded to BmpRowStride bytes.
  * @element eof = Determines whether the image has ended or still has data.
+ * @element err = Why the stream ended early, the format can not be encoded.
  */
 type ImageStream struct {
 	data          []byte
 	current_index int64
 	Resolution    Camera7670.RESOLUTION
 	ImageType     Camera7670.IMAGE
+	YUVOrder      Camera7670.YUV_ORDER
+	Orientation   Camera7670.Orientation
+	header        []byte
+	width         int
 	eof           bool
+	err           error
 }
 
-// * Necessary BMPHeaders
-var BmpHeader160x120 = []byte{
-	0x42, 0x4D,
-	0x36, 0x2C, 0x00, 0x00,
-	0x00, 0x00,
-	0x00, 0x00,
-	0x36, 0x00, 0x00, 0x00,
-	0x28, 0x00, 0x00, 0x00,
-	0xA0, 0x00, 0x00, 0x00,
-	0x78, 0x00, 0x00, 0x00,
-	0x01, 0x00,
-	0x18, 0x00,
-	0x00, 0x00, 0x00, 0x00,
-	0x00, 0x2C, 0x00, 0x00,
-	0x13, 0x0B, 0x00, 0x00,
-	0x13, 0x0B, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00,
+// & Size of the BMP file and info headers.
+const BMP_HEADER_SIZE = 54
+
+// & OneLine Brief = Bytes of a 24 bit BMP row, width*3 padded to a multiple of 4.
+func BmpRowStride(width int) int {
+	return (width*3 + 3) &^ 3
 }
 
-var BmpHeader320x240 = []byte{
-	0x42, 0x4D,
-	0xDE, 0x82, 0x03, 0x00,
-	0x00, 0x00,
-	0x00, 0x00,
-	0x36, 0x00, 0x00, 0x00,
-	0x28, 0x00, 0x00, 0x00,
-	0x40, 0x01, 0x00, 0x00,
-	0xF0, 0x00, 0x00, 0x00,
-	0x01, 0x00,
-	0x18, 0x00,
-	0x00, 0x00, 0x00, 0x00,
-	0x00, 0x82, 0x03, 0x00,
-	0x13, 0x0B, 0x00, 0x00,
-	0x13, 0x0B, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00,
+/*
+ * @brief = Builds the 24 bit BMP header of an image.
+ * @params width, height = Size of the image in pixels.
+ * @return = The 54 header bytes, the sizes count the padded rows of BmpRowStride bytes like GetNextPixel sends them.
+ */
+func CreateBmpHeader(width, height int) []byte {
+	image_size := uint32(BmpRowStride(width) * height)
+	header := make([]byte, BMP_HEADER_SIZE)
+	header[0], header[1] = 'B', 'M'
+	put_uint32(header[2:], image_size+BMP_HEADER_SIZE)
+	put_uint32(header[10:], BMP_HEADER_SIZE)
+	put_uint32(header[14:], 40)
+	put_uint32(header[18:], uint32(width))
+	put_uint32(header[22:], uint32(height))
+	header[26] = 0x01
+	header[28] = 0x18
+	put_uint32(header[34:], image_size)
+	put_uint32(header[38:], 0x0B13)
+	put_uint32(header[42:], 0x0B13)
+
+	return header
 }
 
-var BmpHeader640x480 = []byte{
-	0x42, 0x4D,
-	0x36, 0x6C, 0x0B, 0x00,
-	0x00, 0x00,
-	0x00, 0x00,
-	0x36, 0x00, 0x00, 0x00,
-	0x28, 0x00, 0x00, 0x00,
-	0x80, 0x02, 0x00, 0x00,
-	0xE0, 0x01, 0x00, 0x00,
-	0x01, 0x00,
-	0x18, 0x00,
-	0x00, 0x00, 0x00, 0x00,
-	0x00, 0x6C, 0x0B, 0x00,
-	0x13, 0x0B, 0x00, 0x00,
-	0x13, 0x0B, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00,
-	0x00, 0x00, 0x00, 0x00,
+// & Bits of the first reserved word of the BMP file header (offset 6) that tag the Orientation of the sensor.
+const (
+	BMP_TAG_MIRROR uint8 = 0x01
+	BMP_TAG_FLIP   uint8 = 0x02
+)
+
+/*
+ * @brief = Tags the Orientation the sensor applied in the reserved word of a BMP header, viewers ignore it.
+ * @param header = Header of CreateBmpHeader, it is changed in place.
+ * @param o = Mirror and flip of the sensor.
+ */
+func TagBmpOrientation(header []byte, o Camera7670.Orientation) {
+	header[6] &^= BMP_TAG_MIRROR | BMP_TAG_FLIP
+	if o.Mirror {
+		header[6] |= BMP_TAG_MIRROR
+	}
+	if o.Flip {
+		header[6] |= BMP_TAG_FLIP
+	}
 }
 
+// & OneLine Brief = Orientation tagged by TagBmpOrientation in a BMP header, for the receiver of a stream.
+func BmpOrientation(header []byte) Camera7670.Orientation {
+	return Camera7670.Orientation{Mirror: header[6]&BMP_TAG_MIRROR != 0, Flip: header[6]&BMP_TAG_FLIP != 0}
+}
+
+// & OneLine Brief = Writes v in little endian to the first 4 bytes of b.
+func put_uint32(b []byte, v uint32) {
+	b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+}
+
+// * Necessary BMPHeaders
+var BmpHeader160x120 = CreateBmpHeader(160, 120)
+
+var BmpHeader320x240 = CreateBmpHeader(320, 240)
+
+var BmpHeader640x480 = CreateBmpHeader(640, 480)
+
 /*
  * @brief = Creates a ImageStream object.
  * @param __image__ = A pointer to a CameraImage object.
  * @return = Returns a pointer to an instance of ImageStream Object.
  */
 func EncodeImage(__image__ *CameraImage) *ImageStream {
-	return &ImageStream{data: __image__.ImageData, current_index: 0, Resolution: __image__.Resolution, ImageType: __image__.ImageType, eof: false}
+	W, H := __image__.Window.Width, __image__.Window.Height
+	if __image__.Window.Pixels() == 0 {
+		W, H = get_dimensions(__image__.Resolution)
+	}
+	header := CreateBmpHeader(W, H)
+	TagBmpOrientation(header, __image__.Orientation)
+	return &ImageStream{data: __image__.ImageData, current_index: 0, Resolution: __image__.Resolution, ImageType: __image__.ImageType, YUVOrder: __image__.YUVOrder, Orientation: __image__.Orientation, header: header, width: W, eof: false}
 }
 
 /*
  * @brief = Returns a formatted pixel from the ImageStream object for later use.
- * @return = []byte{} object which contains the pixel data in RGB888 Format.
+ * @return = []byte{} object which contains the pixel data in RGB888 Format followed by the padding of the row after its last pixel, nil once a format that can not be encoded ends the stream (see Err).
  */
 func (stream *ImageStream) GetNextPixel() []byte {
 	increment_flag := true
 	var result []byte
 	if stream.current_index < 54 {
-		switch stream.Resolution {
-		case Camera7670.VGA:
-			result = []byte{BmpHeader640x480[stream.current_index]}
-			break
-		case Camera7670.QVGA:
-			result = []byte{BmpHeader320x240[stream.current_index]}
-			break
-		case Camera7670.QQVGA:
-			result = []byte{BmpHeader160x120[stream.current_index]}
-			break
-		default:
-			increment_flag = false
-			break
-		}
-	} else if stream.current_index >= 54 && stream.current_index < int64(len(stream.data)) {
+		result = []byte{stream.header[stream.current_index]}
+	} else if stream.current_index >= 54 && stream.current_index-54 < int64(len(stream.data)) {
 		switch stream.ImageType {
 		case Camera7670.GREYSCALED:
 			result = []byte{
@@ -115,13 +132,8 @@ func (stream *ImageStream) GetNextPixel() []byte {
 				stream.data[stream.current_index-54],
 			}
 			break
-		case Camera7670.RGB:
-			var item uint16 = uint16(stream.data[stream.current_index+1])<<8 | uint16(stream.data[stream.current_index])
-			var r8, g8, b8 uint8
-
-			r8 = byte(item >> 11 & 0x1F)
-			g8 = byte(item >> 5 & 0x3F)
-			b8 = byte(item & 0x1F)
+		case Camera7670.RGB, Camera7670.RGB555, Camera7670.RGB444_XRGB, Camera7670.RGB444_RGBX:
+			r8, g8, b8 := UnpackRGBPixel(stream.ImageType, stream.data[stream.current_index-54], stream.data[stream.current_index-53])
 
 			result = []byte{
 				r8,
@@ -129,11 +141,33 @@ func (stream *ImageStream) GetNextPixel() []byte {
 				b8,
 			}
 			break
-		case Camera7670.BAYER:
-			increment_flag = false // ! NOT IMPLEMENTED
+		case Camera7670.YUV:
+			// Both pixels of a 4 byte group share its U and V.
+			offset := stream.current_index - 54
+			if offset&^3+4 > int64(len(stream.data)) {
+				// A last pixel without its pair has no V, the image ends there.
+				stream.eof = true
+				return nil
+			}
+			group := stream.data[offset&^3 : offset&^3+4]
+			y0, u, y1, v := stream.YUVOrder.Offsets()
+			y := group[y0]
+			if offset%4 != 0 {
+				y = group[y1]
+			}
+			r8, g8, b8 := YUVToRGB888(y, group[u], group[v])
+
+			result = []byte{
+				r8,
+				g8,
+				b8,
+			}
 			break
 		default:
-			increment_flag = false
+			// BAYER needs demosaicing, it is not encoded.
+			stream.eof = true
+			stream.err = fmt.Errorf("Can not encode a %s Image to BMP.", stream.ImageType.String())
+			return nil
 		}
 	} else {
 		increment_flag = false
@@ -141,18 +175,28 @@ func (stream *ImageStream) GetNextPixel() []byte {
 	}
 
 	if increment_flag {
-		if stream.ImageType == Camera7670.RGB {
-			stream.current_index += 2
+		if stream.current_index >= 54 {
+			pixel := int(stream.current_index-54) / get_image_type(stream.ImageType)
+			if stream.width > 0 && (pixel+1)%stream.width == 0 {
+				result = append(result, make([]byte, BmpRowStride(stream.width)-stream.width*3)...)
+			}
+			stream.current_index += int64(get_image_type(stream.ImageType))
 		} else {
 			stream.current_index++
 		}
 		return result
 	}
 
-	return []byte{0x00}
+	// Past the last pixel, nothing is left so the file matches the sizes of the header.
+	return nil
 }
 
 // & OneLine Brief = Checks if the image has ended or not.
 func (stream *ImageStream) GetEOF() bool {
 	return stream.eof
 }
+
+// & OneLine Brief = Returns the error that ended the stream early, nil if the whole image was encoded.
+func (stream *ImageStream) Err() error {
+	return stream.err
+}
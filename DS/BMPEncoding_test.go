@@ -0,0 +1,89 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"bytes"
+	"testing"
+)
+
+// & OneLine Brief = Reads a whole ImageStream, failing the test if it does not end.
+func drainStream(t *testing.T, stream *ImageStream) []byte {
+	t.Helper()
+	var out []byte
+	for calls := 0; !stream.GetEOF(); calls++ {
+		if calls > 1<<20 {
+			t.Fatalf("ImageStream of a %s Image never ended", stream.ImageType)
+		}
+		out = append(out, stream.GetNextPixel()...)
+	}
+	return out
+}
+
+func TestImageStreamYUV(t *testing.T) {
+	// Two pixel pairs in UYVY order: a grey pair and a pair with strong red chroma.
+	image, _ := CreateWindowedImage(Camera7670.YUV, Camera7670.QQVGA, Camera7670.Window{Width: 4, Height: 1})
+	image.YUVOrder = Camera7670.UYVY
+	copy(image.ImageData, []byte{128, 100, 128, 200, 90, 80, 240, 90})
+
+	out := drainStream(t, EncodeImage(image))
+	if len(out) < BMP_HEADER_SIZE {
+		t.Fatalf("stream of %d bytes has no header", len(out))
+	}
+
+	var want []byte
+	for _, pixel := range [][3]uint8{{100, 128, 128}, {200, 128, 128}, {80, 90, 240}, {90, 90, 240}} {
+		r, g, b := YUVToRGB888(pixel[0], pixel[1], pixel[2])
+		want = append(want, r, g, b)
+	}
+	if pixels := out[BMP_HEADER_SIZE : BMP_HEADER_SIZE+len(want)]; !bytes.Equal(pixels, want) {
+		t.Errorf("pixels = %v, want %v", pixels, want)
+	}
+}
+
+func TestImageStreamUnsupported(t *testing.T) {
+	image, _ := CreateWindowedImage(Camera7670.BAYER, Camera7670.QQVGA, Camera7670.Window{Width: 4, Height: 2})
+	stream := EncodeImage(image)
+	drainStream(t, stream)
+	if stream.Err() == nil {
+		t.Errorf("BAYER stream ended without an error")
+	}
+}
+
+func TestImageStreamRowPadding(t *testing.T) {
+	// 3 pixels are 9 bytes, every row needs 3 bytes of padding.
+	const width, height = 3, 2
+	image, _ := CreateWindowedImage(Camera7670.GREYSCALED, Camera7670.QQVGA, Camera7670.Window{Width: width, Height: height})
+	copy(image.ImageData, []byte{1, 2, 3, 4, 5, 6})
+
+	out := drainStream(t, EncodeImage(image))
+	le := func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 | int(b[3])<<24 }
+	if stride := BmpRowStride(width); stride != 12 {
+		t.Fatalf("BmpRowStride(%d) = %d, want 12", width, stride)
+	}
+	if size := le(out[2:]); size != len(out) || size != BMP_HEADER_SIZE+2*12 {
+		t.Errorf("file size in header = %d, stream = %d bytes, want %d", size, len(out), BMP_HEADER_SIZE+2*12)
+	}
+	if size := le(out[34:]); size != 2*12 {
+		t.Errorf("image size in header = %d, want 24", size)
+	}
+
+	want := []byte{1, 1, 1, 2, 2, 2, 3, 3, 3, 0, 0, 0, 4, 4, 4, 5, 5, 5, 6, 6, 6, 0, 0, 0}
+	if !bytes.Equal(out[BMP_HEADER_SIZE:], want) {
+		t.Errorf("rows = %v, want %v", out[BMP_HEADER_SIZE:], want)
+	}
+}
+
+func TestImageStreamOrientation(t *testing.T) {
+	for _, o := range []Camera7670.Orientation{{}, {Mirror: true}, {Flip: true}, {Mirror: true, Flip: true}} {
+		image, _ := CreateWindowedImage(Camera7670.GREYSCALED, Camera7670.QQVGA, Camera7670.Window{Width: 2, Height: 1})
+		image.Orientation = o
+
+		out := drainStream(t, EncodeImage(image))
+		if got := BmpOrientation(out[:BMP_HEADER_SIZE]); got != o {
+			t.Errorf("header tags %s, want %s", got, o)
+		}
+		if !bytes.Equal(out[7:10], []byte{0, 0, 0}) {
+			t.Errorf("%s: reserved header bytes = %v, want only the tag bits", o, out[6:10])
+		}
+	}
+}
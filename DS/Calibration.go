@@ -0,0 +1,89 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"context"
+)
+
+/*
+~ File Description:
+^ Captures the shifting ones test pattern and works out the order of the data lines with Camera7670.CalibratePins.
+^ Run it at bring-up, a wrong order shows up as D lines arriving on other bits and ReorderPins gives the pin list to use instead.
+^ The pattern is laid out over the whole frame (see Camera7670.ShiftingOnes), so the window is opened up to the whole frame while it runs.
+^ The calibration is experimental until that layout is confirmed on hardware.
+*/
+
+/*
+* @brief = Calibrates the data pin order with the shifting ones test pattern, gives up after Cam.Timeout.
+* ^ EXPERIMENTAL: the layout of the pattern (see Camera7670.ShiftingOnes) is not confirmed on hardware, the simulator sends
+* ^ the layout the driver expects. Check the result against a board wired in known order before changing pins on its word.
+* @param Cam = pointer to the OV7670 Object, configured with the format of the capture, its window is put back after.
+* @return = The calibration, and a *CaptureError if a signal stalled or the *RegisterError of the pattern or window select.
+! Handle Error.
+*/
+func CalibrateDataPins(Cam *Camera7670.OV7670) (Camera7670.PinCalibration, error) {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return CalibrateDataPinsContext(ctx, Cam)
+}
+
+/*
+* @brief = Calibrates the data pin order with the shifting ones test pattern, till ctx ends.
+* ^ Reads the first CALIBRATION_LINES lines of the whole frame with every byte clocked, the previous test pattern and window are put back after.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
+* @param Cam = pointer to the OV7670 Object, configured with the format of the capture, its window is put back after.
+* @return = The calibration, and a *CaptureError if a signal stalled or the *RegisterError of the pattern or window select.
+! Handle Error.
+*/
+func CalibrateDataPinsContext(ctx context.Context, Cam *Camera7670.OV7670) (calibration Camera7670.PinCalibration, err error) {
+	previous := Cam.TestPattern()
+	if err := Cam.SetTestPattern(Camera7670.TEST_PATTERN_SHIFTING_ONES); err != nil {
+		return calibration, err
+	}
+	defer func() {
+		if restore := Cam.SetTestPattern(previous); err == nil {
+			err = restore
+		}
+	}()
+
+	// Row 0 and byte 0 of the capture are then the corner of the frame the pattern is laid out from.
+	window, frame := Cam.Window(), Cam.Frame()
+	if err := Cam.SetWindow(0, 0, frame.Width, frame.Height); err != nil {
+		return calibration, err
+	}
+	defer func() {
+		if restore := Cam.SetWindow(window.X, window.Y, window.Width, window.Height); err == nil {
+			err = restore
+		}
+	}()
+
+	// The pattern may only start with the next frame, one whole frame is skipped.
+	for skip := 0; skip < 2; skip++ {
+		if err := Cam.WaitForNewFrameContext(ctx); err != nil {
+			return calibration, capture_error(-1, 0, 0, err)
+		}
+	}
+
+	clocked := clocked_bytes(Cam.ImageType())
+	line_bytes := Cam.Window().Width * clocked
+	lines := make([][]byte, 0, Camera7670.CALIBRATION_LINES)
+	vsync_low := false
+	for row := 0; row < min(Camera7670.CALIBRATION_LINES, Cam.Window().Height); row++ {
+		early, err := wait_for_href(ctx, Cam, &vsync_low)
+		if err != nil {
+			return calibration, capture_error(row, 0, row*line_bytes, err)
+		}
+		if early {
+			break
+		}
+
+		line := make([]byte, line_bytes)
+		count, err := read_line(ctx, Cam, line, 1)
+		if err != nil {
+			return calibration, capture_error(row, count/clocked, row*line_bytes+count, err)
+		}
+		lines = append(lines, line[:min(count, line_bytes)])
+	}
+
+	return Camera7670.CalibratePins(lines, 0), nil
+}
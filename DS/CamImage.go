@@ -2,69 +2,89 @@ package DataStructures
 
 import (
 	Camera7670 "PICO_OV7670/Camera"
+	"context"
 	"fmt"
-	"machine"
-	"time"
-
-	"tinygo.org/x/drivers/sdcard"
+	"io"
 )
 
 // & Special Settings
 const (
-	OVERRIDE_SIZE = false
+	OVERRIDE_SIZE   = false
+	MAX_IMAGE_BYTES = 320 * 240 * 2 // A QVGA RGB565 frame is the largest that fits next to the program in RAM.
 )
 
 /*
  * @brief = Is a type of data structure that is made to store image data.
  * @element ImageType = Stores the format of image.
- * @element Resolution = Stores the size of image.
+ * @element YUVOrder = Byte order of the pixels when ImageType is YUV.
+ * @element Orientation = Mirror and flip the sensor applied while taking the image, EncodeImage tags it in the BMP header.
+ * @element Resolution = Stores the resolution the image was taken at.
+ * @element Window = Region of the sensor output stored, its Width and Height are the size of image.
  * @element ImageData = stores the actual raw data of image.
  */
 type CameraImage struct {
-	ImageType  Camera7670.IMAGE
-	Resolution Camera7670.RESOLUTION
-	ImageData  []byte
+	ImageType   Camera7670.IMAGE
+	YUVOrder    Camera7670.YUV_ORDER
+	Orientation Camera7670.Orientation
+	Resolution  Camera7670.RESOLUTION
+	Window      Camera7670.Window
+	ImageData   []byte
 }
 
 /*
  * @brief = Similar to CameraImage but uses a fixed size queue in an attempt that maybe I am able to use the second core to format the Image data to foreg. PNG or JPEG format
  * @element ImageType = Stores the format of image.
- * @element Resolution = Stores the size of image.
+ * @element YUVOrder = Byte order of the pixels when ImageType is YUV.
+ * @element Orientation = Mirror and flip the sensor applied while taking the image.
+ * @element Resolution = Stores the resolution the image was taken at.
+ * @element Window = Region of the sensor output stored, its Width and Height are the size of image.
  * @element ImageData = stores the actual raw data of image.
  */
 type QueuedCameraImage struct {
-	ImageType  Camera7670.IMAGE
-	Resolution Camera7670.RESOLUTION
-	ImageData  *Queue[byte]
+	ImageType   Camera7670.IMAGE
+	YUVOrder    Camera7670.YUV_ORDER
+	Orientation Camera7670.Orientation
+	Resolution  Camera7670.RESOLUTION
+	Window      Camera7670.Window
+	ImageData   *Queue[byte]
 }
 
 /*
  * @brief = Gets the dimension according to the available Resolution Mode.
  * @param image_res = Image Resolution Mode
- * @return = Tuple of integers representing image size.
+ * @return = Tuple of integers representing image size, 0x0 for CUSTOM as only the camera knows that size.
  */
 func get_dimensions(image_res Camera7670.RESOLUTION) (int, int) {
-	var W, H int
-	switch image_res {
-	case Camera7670.VGA:
-		W = 640
-		H = 480
-		break
-	case Camera7670.QVGA:
-		W = 320
-		H = 240
-		break
-	case Camera7670.QQVGA:
-		W = 160
-		H = 120
-		break
-	default:
-		W = 640
-		H = 480
-		break
+	return image_res.Dimensions()
+}
+
+/*
+ * @brief = Gets the dimension of the frames a camera outputs at a resolution.
+ * @param Cam = pointer to the OV7670 Object.
+ * @param image_res = Image Resolution Mode
+ * @return = The window of the camera if it is configured to image_res, else the full image_res size.
+ */
+func get_frame_dimensions(Cam *Camera7670.OV7670, image_res Camera7670.RESOLUTION) (int, int) {
+	if Cam.Resolution() == image_res {
+		return Cam.Window().Width, Cam.Window().Height
 	}
+	return get_dimensions(image_res)
+}
 
-	return W, H
+/*
+ * @brief = Checks that an image fits in RAM.
+ * @param win = Window of the image.
+ * @param bytes_per_pixel = Bytes stored per pixel.
+ * @return = error if the image has no pixels (CUSTOM without a camera) or is larger than MAX_IMAGE_BYTES and OVERRIDE_SIZE is not set.
+ */
+func check_size(win Camera7670.Window, bytes_per_pixel int) error {
+	if win.Width <= 0 || win.Height <= 0 {
+		return fmt.Errorf("Image of %dx%d Size has no pixels, create CUSTOM images from the camera.", win.Width, win.Height)
+	}
+	if win.Pixels()*bytes_per_pixel > MAX_IMAGE_BYTES && !OVERRIDE_SIZE {
+		return fmt.Errorf("Impossible to store %dx%d Size Image in RAM.", win.Width, win.Height)
+	}
+	return nil
 }
 
 /*
@@ -78,7 +98,7 @@ func get_image_type(image_type Camera7670.IMAGE) int {
 	case Camera7670.GREYSCALED, Camera7670.BAYER:
 		bytes_per_pixel = 1
 		break
-	case Camera7670.RGB:
+	case Camera7670.RGB, Camera7670.YUV, Camera7670.RGB555, Camera7670.RGB444_XRGB, Camera7670.RGB444_RGBX:
 		bytes_per_pixel = 2
 		break
 	default:
@@ -97,54 +117,82 @@ func get_image_type(image_type Camera7670.IMAGE) int {
 ! Handle Error.
 */
 func CreateImage(image_type Camera7670.IMAGE, image_res Camera7670.RESOLUTION) (*CameraImage, error) {
-	bytes_per_pixel := get_image_type(image_type)
 	W, H := get_dimensions(image_res)
-	if image_res == Camera7670.VGA && !OVERRIDE_SIZE {
-		return nil, fmt.Errorf("Impossible to store VGA Size Image in RAM.")
+	return CreateWindowedImage(image_type, image_res, Camera7670.Window{Width: W, Height: H})
+}
+
+/*
+* @brief = Creates the CameraImage DataStructure for a region of interest.
+* @param image_type = The format of image.
+* @param image_res = The resolution the window is taken at.
+* @param win = The region of interest, sizes the buffer.
+* @return = An instance of CameraImage with all the maths sorted out.
+! Handle Error.
+*/
+func CreateWindowedImage(image_type Camera7670.IMAGE, image_res Camera7670.RESOLUTION, win Camera7670.Window) (*CameraImage, error) {
+	bytes_per_pixel := get_image_type(image_type)
+	if err := check_size(win, bytes_per_pixel); err != nil {
+		return nil, err
 	}
-	Data := make([]uint8, W*H*bytes_per_pixel)
-	return &CameraImage{ImageType: image_type, Resolution: image_res, ImageData: Data}, nil
+	Data := make([]uint8, win.Pixels()*bytes_per_pixel)
+	return &CameraImage{ImageType: image_type, Resolution: image_res, Window: win, ImageData: Data}, nil
 }
 
 /*
-* @brief = Reads Data from Camera (OV7670 Object) in its ImageData buffer.
+* @brief = Creates the CameraImage DataStructure sized for the active configuration of a camera.
+* @param Cam = pointer to a configured OV7670 Object.
+* @return = An instance of CameraImage matching the format, resolution, window and orientation of the camera.
+! Handle Error.
+*/
+func CreateImageFromCamera(Cam *Camera7670.OV7670) (*CameraImage, error) {
+	CamImage, err := CreateWindowedImage(Cam.ImageType(), Cam.Resolution(), Cam.Window())
+	if err != nil {
+		return nil, err
+	}
+	CamImage.YUVOrder = Cam.YUVOrder()
+	CamImage.Orientation = Cam.Orientation()
+
+	return CamImage, nil
+}
+
+/*
+* @brief = Reads Data from Camera (OV7670 Object) in its ImageData buffer, gives up after Cam.Timeout.
+* @param Cam = pointer to the OV7670 Object.
+* @return = *CorruptedImageError if the frame ended before the image was full, a *CaptureError if a signal stalled, else nil.
+! Handle Error.
+*/
+func (CamImage *CameraImage) ReadImage(Cam *Camera7670.OV7670) error {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return CamImage.ReadImageContext(ctx, Cam)
+}
+
+/*
+* @brief = Reads Data from Camera (OV7670 Object) in its ImageData buffer till ctx ends.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
 * @param Cam = pointer to the OV7670 Object.
-* @param SafeMode = Whether to check for image corruption or not if found a corruption raise an error.
-* @return = error if found corruption else nil.
+* @return = *CorruptedImageError if the frame ended before the image was full, a *CaptureError if a signal stalled, else nil.
 ! Handle Error.
 */
-func (CamImage *CameraImage) ReadImage(Cam *Camera7670.OV7670, SafeMode bool) error {
+func (CamImage *CameraImage) ReadImageContext(ctx context.Context, Cam *Camera7670.OV7670) error {
 	bytesPerPixel := get_image_type(CamImage.ImageType)
-	width, height := get_dimensions(CamImage.Resolution)
-	if CamImage.Resolution == Camera7670.VGA && !OVERRIDE_SIZE {
-		return fmt.Errorf("Impossible to store VGA Size Image in RAM.")
+	width, height := CamImage.Window.Width, CamImage.Window.Height
+	if len(CamImage.ImageData) < width*height*bytesPerPixel {
+		return fmt.Errorf("ImageData is too small for a %dx%d Image.", width, height)
 	}
-	DataCounter := 0
+	lineSize := width * bytesPerPixel
 
-	Cam.WaitForNewFrame() // Wait for VSync high then low
+	backend, stride, err := start_frame(ctx, Cam, width, CamImage.ImageType)
+	if err != nil {
+		return err
+	}
+	defer backend.EndFrame(Cam)
 
 	for row := 0; row < height; row++ {
-		if SafeMode {
-			Cam.WaitForHorizontalSyncLow()
-			for !Cam.HSync.Get() {
-				if Cam.VSync.Get() {
-					return fmt.Errorf("Corrupted Image. Image till Height = %d is done", row)
-				}
-			}
-		}
-
-		for column := 0; column < width; column++ {
-			Cam.WaitForPixelClockLow()
-			CamImage.ImageData[DataCounter] = Cam.ReadPins()
-			DataCounter++
-			Cam.WaitForPixelClockHigh()
-
-			Cam.WaitForPixelClockLow()
-			if bytesPerPixel == 2 {
-				CamImage.ImageData[DataCounter] = Cam.ReadPins()
-				DataCounter++
-			}
-			Cam.WaitForPixelClockHigh()
+		line := CamImage.ImageData[row*lineSize : (row+1)*lineSize]
+		if count, err := backend.ReadLine(ctx, Cam, line, stride); err != nil {
+			column := count / stride / bytesPerPixel
+			return line_error(row, height, column, row*lineSize+column*bytesPerPixel, err)
 		}
 	}
 
@@ -159,52 +207,86 @@ func (CamImage *CameraImage) ReadImage(Cam *Camera7670.OV7670, SafeMode bool) er
 ! Handle Error.
 */
 func CreateQueuedImage(image_type Camera7670.IMAGE, image_res Camera7670.RESOLUTION) (*QueuedCameraImage, error) {
-	bytes_per_pixel := get_image_type(image_type)
 	W, H := get_dimensions(image_res)
-	if image_res == Camera7670.VGA && !OVERRIDE_SIZE {
-		return nil, fmt.Errorf("Impossible to store VGA Size Image in RAM.")
+	return CreateWindowedQueuedImage(image_type, image_res, Camera7670.Window{Width: W, Height: H})
+}
+
+/*
+* @brief = Creates the QueuedCameraImage DataStructure for a region of interest.
+* @param image_type = The format of image.
+* @param image_res = The resolution the window is taken at.
+* @param win = The region of interest, sizes the queue.
+* @return = An instance of QueuedCameraImage with all the maths sorted out.
+! Handle Error.
+*/
+func CreateWindowedQueuedImage(image_type Camera7670.IMAGE, image_res Camera7670.RESOLUTION, win Camera7670.Window) (*QueuedCameraImage, error) {
+	bytes_per_pixel := get_image_type(image_type)
+	if err := check_size(win, bytes_per_pixel); err != nil {
+		return nil, err
 	}
 
-	Data := NewQueue[byte](W * H * bytes_per_pixel)
-	return &QueuedCameraImage{ImageType: image_type, Resolution: image_res, ImageData: Data}, nil
+	Data := NewQueue[byte](win.Pixels() * bytes_per_pixel)
+	return &QueuedCameraImage{ImageType: image_type, Resolution: image_res, Window: win, ImageData: Data}, nil
 }
 
 /*
-* @brief = Reads Data from Camera (OV7670 Object) in its ImageData buffer.
+* @brief = Creates the QueuedCameraImage DataStructure sized for the active configuration of a camera.
+* @param Cam = pointer to a configured OV7670 Object.
+* @return = An instance of QueuedCameraImage matching the format, resolution, window and orientation of the camera.
+! Handle Error.
+*/
+func CreateQueuedImageFromCamera(Cam *Camera7670.OV7670) (*QueuedCameraImage, error) {
+	CamImage, err := CreateWindowedQueuedImage(Cam.ImageType(), Cam.Resolution(), Cam.Window())
+	if err != nil {
+		return nil, err
+	}
+	CamImage.YUVOrder = Cam.YUVOrder()
+	CamImage.Orientation = Cam.Orientation()
+
+	return CamImage, nil
+}
+
+/*
+* @brief = Reads Data from Camera (OV7670 Object) in its ImageData buffer, gives up after Cam.Timeout.
+* @param Cam = pointer to the OV7670 Object.
+* @return = *CorruptedImageError if the frame ended before the image was full, a *CaptureError if a signal stalled, else nil.
+! Handle Error.
+*/
+func (CamImage *QueuedCameraImage) ReadImage(Cam *Camera7670.OV7670) error {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return CamImage.ReadImageContext(ctx, Cam)
+}
+
+/*
+* @brief = Reads Data from Camera (OV7670 Object) in its ImageData buffer till ctx ends.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
 * @param Cam = pointer to the OV7670 Object.
-* @param SafeMode = Whether to check for image corruption or not if found a corruption raise an error.
-* @return = error if found corruption else nil.
+* @return = *CorruptedImageError if the frame ended before the image was full, a *CaptureError if a signal stalled, else nil.
 ! Handle Error.
 */
-func (CamImage *QueuedCameraImage) ReadImage(Cam *Camera7670.OV7670, SafeMode bool) error {
+func (CamImage *QueuedCameraImage) ReadImageContext(ctx context.Context, Cam *Camera7670.OV7670) error {
 	bytesPerPixel := get_image_type(CamImage.ImageType)
-	width, height := get_dimensions(CamImage.Resolution)
+	width, height := CamImage.Window.Width, CamImage.Window.Height
 
-	if CamImage.Resolution == Camera7670.VGA && !OVERRIDE_SIZE {
-		return fmt.Errorf("Impossible to store VGA Size Image in RAM.")
+	if err := check_size(CamImage.Window, bytesPerPixel); err != nil {
+		return err
 	}
 
-	Cam.WaitForNewFrame() // Checks for VSync pin to go high then low.
-	for row := 0; row < height; row++ {
-		if SafeMode {
-			Cam.WaitForHorizontalSyncLow()
-			for !Cam.HSync.Get() {
-				if Cam.VSync.Get() {
-					return fmt.Errorf("Corrupted Image. Image till Height = %d is done", row)
-				}
-			}
-		}
+	line := make([]byte, width*bytesPerPixel)
 
-		for column := 0; column < width; column++ {
-			Cam.WaitForPixelClockLow()
-			CamImage.ImageData.Enqueue(Cam.ReadPins())
-			Cam.WaitForPixelClockHigh()
+	backend, stride, err := start_frame(ctx, Cam, width, CamImage.ImageType)
+	if err != nil {
+		return err
+	}
+	defer backend.EndFrame(Cam)
 
-			Cam.WaitForPixelClockLow()
-			if bytesPerPixel == 2 {
-				CamImage.ImageData.Enqueue(Cam.ReadPins())
-			}
-			Cam.WaitForPixelClockHigh()
+	for row := 0; row < height; row++ {
+		if count, err := backend.ReadLine(ctx, Cam, line, stride); err != nil {
+			return line_error(row, height, count/stride/bytesPerPixel, CamImage.ImageData.Len(), err)
+		}
+		for _, value := range line {
+			CamImage.ImageData.Enqueue(value)
 		}
 	}
 
@@ -212,114 +294,51 @@ func (CamImage *QueuedCameraImage) ReadImage(Cam *Camera7670.OV7670, SafeMode bo
 }
 
 /*
-* @brief = Flash an image to the USB Interface of pico.
+* @brief = Flash an image to the UART Interface of pico, gives up after Cam.Timeout.
+* @param UART = Any byte writer, *machine.UART on target.
 * @param Cam = A pointer to a OV7670 Driver Object.
 * @param ImageType = Stores the format of image.
 * @param Resolution = Stores the size of image.
-* @param SafeMode = Check for image corruption.
-* @return = Error if caught any.
+* @return = Error if caught any, a *CaptureError if a signal stalled, a *SinkError if writing failed.
 ! Handle Error.
 */
-func FlashImage(Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION, SafeMode bool) error {
-	if Resolution == Camera7670.VGA && !OVERRIDE_SIZE {
-		return fmt.Errorf("Impossible to store VGA Size Image in RAM.")
-	}
-
-	CamImage, _ := CreateImage(ImageType, Resolution)
-	if err := CamImage.ReadImage(Cam, SafeMode); err != nil {
-		for _, item := range CamImage.ImageData {
-			machine.USBCDC.WriteByte(item)
-			time.Sleep(time.Microsecond)
-		}
-
-		clear(CamImage.ImageData)
-		CamImage = nil
-
-		return nil
-	} else {
-		return err
-	}
+func FlashImageToUART(UART io.ByteWriter, Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION) error {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return FlashImageToUARTContext(ctx, UART, Cam, ImageType, Resolution)
 }
 
 /*
-* @brief = Flash an image to the UART Interface of pico.
-* @param Cam = A pointer to a OV7670 Driver Object.
-* @param ImageType = Stores the format of image.
-* @param Resolution = Stores the size of image.
-* @param SafeMode = Check for image corruption.
-* @return = Error if caught any.
+* @brief = Flash an image to the UART Interface of pico till ctx ends.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
+* @params UART, Cam, ImageType, Resolution = See FlashImageToUART.
+* @return = Error if caught any, a *CaptureError if a signal stalled, a *SinkError if writing failed.
 ! Handle Error.
 */
-func FlashImageToUART(UART *machine.UART, Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION, SafeMode bool) error {
-	bytesPerPixel := get_image_type(ImageType)
-	width, height := get_dimensions(Resolution)
-
-	Cam.WaitForNewFrame() // Checks for VSync pin to go high then low.
-	for row := 0; row < height; row++ {
-		if SafeMode {
-			Cam.WaitForHorizontalSyncLow()
-			for !Cam.HSync.Get() {
-				if Cam.VSync.Get() {
-					return fmt.Errorf("Corrupted Image. Image till Height = %d is done", row)
-				}
-			}
-		}
-
-		for column := 0; column < width; column++ {
-			Cam.WaitForPixelClockLow()
-			UART.WriteByte(Cam.ReadPins())
-			Cam.WaitForPixelClockHigh()
-
-			Cam.WaitForPixelClockLow()
-			if bytesPerPixel >= 2 {
-				UART.WriteByte(Cam.ReadPins())
-			}
-			Cam.WaitForPixelClockHigh()
-		}
-	}
-
-	return nil
+func FlashImageToUARTContext(ctx context.Context, UART io.ByteWriter, Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION) error {
+	return StreamImageContext(ctx, UARTSink{UART: UART}, Cam, ImageType, Resolution)
 }
 
 /*
-* @brief = Store an image in a SD Card if possible.
+* @brief = Store an image in a SD Card if possible, gives up after Cam.Timeout.
+* @param SDCard = Any io.WriterAt, *sdcard.Device on target.
+* @param Address = Byte offset of the first row, row n is stored at Address + n * Width * Bytes Per Pixel.
+* @return = Error if caught any, a *CaptureError if a signal stalled, a *SinkError if writing failed.
 ! Handle Error.
 */
-func StoreImage(Cam *Camera7670.OV7670, SDCard *sdcard.Device, Address int64, Resolution Camera7670.RESOLUTION, ImageType Camera7670.IMAGE, SafeMode bool) error {
-	width, height := get_dimensions(Resolution)
-	bytesPerPixel := get_image_type(ImageType)
-	row_buffer := make([]byte, width*bytesPerPixel)
-	DataCounter := 0
-
-	Cam.WaitForNewFrame() // Wait for VSync high then low
-
-	for row := 0; row < height; row++ {
-		if SafeMode {
-			Cam.WaitForHorizontalSyncLow()
-			for !Cam.HSync.Get() {
-				if Cam.VSync.Get() {
-					return fmt.Errorf("Corrupted Image. Image till Height = %d is done", row)
-				}
-			}
-		}
-
-		for column := 0; column < width; column++ {
-			Cam.WaitForPixelClockLow()
-			row_buffer[DataCounter] = Cam.ReadPins()
-			DataCounter++
-			Cam.WaitForPixelClockHigh()
-
-			Cam.WaitForPixelClockLow()
-			if bytesPerPixel >= 2 {
-				row_buffer[DataCounter] = Cam.ReadPins()
-				DataCounter++
-			}
-			Cam.WaitForPixelClockHigh()
-		}
-
-		SDCard.WriteAt(row_buffer, Address+int64(row*width))
-		DataCounter = 0
-	}
+func StoreImage(Cam *Camera7670.OV7670, SDCard io.WriterAt, Address int64, Resolution Camera7670.RESOLUTION, ImageType Camera7670.IMAGE) error {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return StoreImageContext(ctx, Cam, SDCard, Address, Resolution, ImageType)
+}
 
-	return nil
+/*
+* @brief = Store an image in a SD Card if possible till ctx ends.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
+* @params Cam, SDCard, Address, Resolution, ImageType = See StoreImage.
+* @return = Error if caught any, a *CaptureError if a signal stalled, a *SinkError if writing failed.
+! Handle Error.
+*/
+func StoreImageContext(ctx context.Context, Cam *Camera7670.OV7670, SDCard io.WriterAt, Address int64, Resolution Camera7670.RESOLUTION, ImageType Camera7670.IMAGE) error {
+	return StreamImageContext(ctx, WriterAtSink{Writer: SDCard, Address: Address}, Cam, ImageType, Resolution)
 }
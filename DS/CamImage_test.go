@@ -0,0 +1,141 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+/*
+~ File Description:
+^ Host tests of ReadImage with fake input pins and a fake DataPort playing a small frame, no register bus is needed.
+^ Every Get of a pin moves the waveform one tick, like polling a real sensor.
+*/
+
+// & Ticks of the parts of the fake frame.
+const (
+	fake_half_clock = 4  // Half a PCLK period.
+	fake_sync       = 16 // VSync pulse, porches and HREF blanking.
+)
+
+/*
+ * @brief = Waveform of a frame of lineBytes x lines, repeated forever.
+ * @element stall = Tick after which every output stays where it is, 0 never stalls.
+ */
+type fakeWave struct {
+	lineBytes int
+	lines     int
+	tick      int
+	stall     int
+}
+
+// & OneLine Brief = Byte a fake frame clocks out at a position.
+func fakeByte(row, column int) uint8 {
+	return uint8(row*31 + column*7 + 1)
+}
+
+// & OneLine Brief = Levels of VSync, HREF and PCLK and the data at the current tick.
+func (w *fakeWave) levels() (bool, bool, bool, uint8) {
+	line := w.lineBytes*2*fake_half_clock + fake_sync
+	frame := 2*fake_sync + w.lines*line + fake_sync
+	tick := w.tick % frame
+	if tick < fake_sync {
+		return true, false, false, 0
+	}
+	tick -= 2 * fake_sync
+	if tick < 0 || tick >= w.lines*line {
+		return false, false, false, 0
+	}
+	row, position := tick/line, tick%line
+	if position >= w.lineBytes*2*fake_half_clock {
+		return false, false, false, 0
+	}
+	column := position / (2 * fake_half_clock)
+	return false, true, position%(2*fake_half_clock) >= fake_half_clock, fakeByte(row, column)
+}
+
+// & OneLine Brief = Samples a signal and moves the waveform on.
+func (w *fakeWave) get(signal int) bool {
+	vsync, href, pclk, _ := w.levels()
+	if w.stall == 0 || w.tick < w.stall {
+		w.tick++
+	}
+	return [3]bool{vsync, href, pclk}[signal]
+}
+
+type fakeSignal struct {
+	wave   *fakeWave
+	signal int
+}
+
+func (s fakeSignal) Get() bool { return s.wave.get(s.signal) }
+
+type fakeDataPort struct{ wave *fakeWave }
+
+func (p fakeDataPort) Read() uint8 {
+	_, _, _, data := p.wave.levels()
+	return data
+}
+
+// & OneLine Brief = Camera whose pins and DataPort play a fake frame.
+func newFakeFrameCamera(wave *fakeWave) *Camera7670.OV7670 {
+	return Camera7670.CreateOV7670(nil, fakeSignal{wave, Camera7670.SIGNAL_VSYNC}, fakeSignal{wave, Camera7670.SIGNAL_HREF}, nil, fakeSignal{wave, Camera7670.SIGNAL_PCLK}, fakeDataPort{wave})
+}
+
+func TestReadImageFakePort(t *testing.T) {
+	const width, height = 4, 3
+	tests := []struct {
+		image Camera7670.IMAGE
+		keep  func(row, column int) uint8 // Byte column of row of the image.
+	}{
+		{Camera7670.RGB, func(row, column int) uint8 { return fakeByte(row, column) }},
+		{Camera7670.GREYSCALED, func(row, column int) uint8 { return fakeByte(row, 2*column) }},
+	}
+
+	for _, test := range tests {
+		wave := &fakeWave{lineBytes: 2 * width, lines: height}
+		Cam := newFakeFrameCamera(wave)
+		image, err := CreateWindowedImage(test.image, Camera7670.QQVGA, Camera7670.Window{Width: width, Height: height})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := image.ReadImage(Cam); err != nil {
+			t.Fatalf("%s: ReadImage: %v", test.image, err)
+		}
+
+		want := make([]byte, 0, len(image.ImageData))
+		for row := 0; row < height; row++ {
+			for column := 0; column < len(image.ImageData)/height; column++ {
+				want = append(want, test.keep(row, column))
+			}
+		}
+		if !bytes.Equal(image.ImageData, want) {
+			t.Errorf("%s: ImageData = %v, want %v", test.image, image.ImageData, want)
+		}
+	}
+}
+
+func TestReadImageStall(t *testing.T) {
+	// The pins freeze in the middle of row 1 of the second frame, the first one is skipped waiting for VSync.
+	wave := &fakeWave{lineBytes: 8, lines: 3}
+	line := 8*2*fake_half_clock + fake_sync
+	wave.stall = (3*fake_sync + 3*line) + 2*fake_sync + line + 5*2*fake_half_clock
+	Cam := newFakeFrameCamera(wave)
+	image, _ := CreateWindowedImage(Camera7670.RGB, Camera7670.QQVGA, Camera7670.Window{Width: 4, Height: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := image.ReadImageContext(ctx, Cam)
+
+	var capture *CaptureError
+	var timeout *Camera7670.TimeoutError
+	if !errors.As(err, &capture) || !errors.As(err, &timeout) {
+		t.Fatalf("ReadImageContext = %v, want a *CaptureError wrapping a *TimeoutError", err)
+	}
+	if capture.Row != 1 || timeout.Signal != Camera7670.SIGNAL_PCLK {
+		t.Errorf("stopped at row %d waiting on %v, want row 1 waiting on PCLK", capture.Row, timeout.Signal)
+	}
+}
@@ -0,0 +1,89 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"context"
+	"errors"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Pieces shared by the capture functions: starting a frame on the capture backend and reporting where a capture stopped.
+*/
+
+/*
+ * @brief = Error of a capture that stalled, tells where in the frame it stopped.
+ * @element Row = Line of the window being captured, -1 while waiting for the frame to start.
+ * @element Column = Pixel of the line being captured.
+ * @element Bytes = Bytes captured before it stopped.
+ * @element Err = The *Camera7670.TimeoutError naming the signal that stalled.
+ */
+type CaptureError struct {
+	Row    int
+	Column int
+	Bytes  int
+	Err    error
+}
+
+func (e *CaptureError) Error() string {
+	if e.Row < 0 {
+		return fmt.Sprintf("Capture stopped before the frame started: %v", e.Err)
+	}
+	return fmt.Sprintf("Capture stopped at row %d, column %d after %d bytes: %v", e.Row, e.Column, e.Bytes, e.Err)
+}
+
+func (e *CaptureError) Unwrap() error {
+	return e.Err
+}
+
+/*
+ * @brief = Error of a capture when VSync starts the next frame before all lines were read.
+ * @element Row = Lines read before the frame ended.
+ * @element Lost = Lines of the image that never arrived, for BitBang lines skipped while the caller was busy with a row.
+ */
+type CorruptedImageError struct {
+	Row  int
+	Lost int
+}
+
+func (e *CorruptedImageError) Error() string {
+	return fmt.Sprintf("Corrupted Image. Image till Height = %d is done, %d rows lost", e.Row, e.Lost)
+}
+
+// & OneLine Brief = Wraps a *TimeoutError with the position of the capture, other errors are returned as they are.
+func capture_error(row, column, bytes int, err error) error {
+	var timeout *Camera7670.TimeoutError
+	if errors.As(err, &timeout) {
+		return &CaptureError{Row: row, Column: column, Bytes: bytes, Err: err}
+	}
+	return err
+}
+
+/*
+ * @brief = Error of a line the capture backend did not deliver.
+ * @params row, column, bytes = Position of the capture, see CaptureError.
+ * @param height = Lines of the image.
+ * @return = *CorruptedImageError if the frame ended before the line, else the error of capture_error.
+ */
+func line_error(row, height, column, bytes int, err error) error {
+	if errors.Is(err, Camera7670.ErrFrameEnded) {
+		return &CorruptedImageError{Row: row, Lost: height - row}
+	}
+	return capture_error(row, column, bytes, err)
+}
+
+/*
+ * @brief = Starts a frame on the backend of the camera for lines of an image type.
+ * @param width = Pixels per line.
+ * @param image_type = Format of the image, sets the bytes clocked and kept per pixel.
+ * @return = The backend, the clocked bytes per kept byte (stride) and a *CaptureError if the frame did not start.
+ */
+func start_frame(ctx context.Context, Cam *Camera7670.OV7670, width int, image_type Camera7670.IMAGE) (Camera7670.CaptureBackend, int, error) {
+	backend := Cam.CaptureBackend()
+	stride := clocked_bytes(image_type) / get_image_type(image_type)
+	if err := backend.StartFrame(ctx, Cam, width*clocked_bytes(image_type)); err != nil {
+		return nil, stride, capture_error(-1, 0, 0, err)
+	}
+	return backend, stride, nil
+}
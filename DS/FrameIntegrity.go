@@ -0,0 +1,181 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"context"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Capture that follows HREF instead of counting on the size of the image: every line is read till HREF falls.
+^ The PCLK edges of every line and the lines of the frame are counted so short or long lines and an early VSync are found.
+^ The result is a FrameReport, downstream code drops or repairs a frame that is not Usable.
+*/
+
+/*
+ * @brief = What a checked capture received.
+ * @element LinesExpected = Lines of the image window.
+ * @element LinesReceived = Lines HREF went high for before the image was full or VSync ended the frame.
+ * @element LineBytes = Bytes every line should have, 2 per pixel (1 for BAYER).
+ * @element FirstBadLine = First line that was short or long, -1 if there is none.
+ * @elements ShortLines, LongLines = Lines with fewer or more PCLK edges than LineBytes.
+ * @element PixelsLost = Pixels of the image that were not received, their bytes are 0.
+ * @element EarlyVSync = VSync started the next frame before the image was full.
+ */
+type FrameReport struct {
+	LinesExpected int
+	LinesReceived int
+	LineBytes     int
+	FirstBadLine  int
+	ShortLines    int
+	LongLines     int
+	PixelsLost    int
+	EarlyVSync    bool
+}
+
+// & OneLine Brief = Reports whether every line arrived with the right number of bytes.
+func (r FrameReport) Usable() bool {
+	return r.LinesReceived == r.LinesExpected && r.ShortLines == 0 && r.LongLines == 0 && !r.EarlyVSync
+}
+
+func (r FrameReport) String() string {
+	if r.Usable() {
+		return fmt.Sprintf("%d of %d lines, usable", r.LinesReceived, r.LinesExpected)
+	}
+	return fmt.Sprintf("%d of %d lines, first bad line %d, %d short, %d long, %d pixels lost, early VSync %t", r.LinesReceived, r.LinesExpected, r.FirstBadLine, r.ShortLines, r.LongLines, r.PixelsLost, r.EarlyVSync)
+}
+
+// & OneLine Brief = Marks a line as bad.
+func (r *FrameReport) bad_line(row int) {
+	if r.FirstBadLine < 0 {
+		r.FirstBadLine = row
+	}
+}
+
+// & OneLine Brief = Bytes clocked out for every pixel, BAYER sends 1, every other format 2.
+func clocked_bytes(image_type Camera7670.IMAGE) int {
+	if image_type == Camera7670.BAYER {
+		return 1
+	}
+	return 2
+}
+
+/*
+ * @brief = Waits for HREF to start a line, watching VSync for the start of the next frame.
+ * @param vsync_low = VSync was seen low since the frame started, updated in place.
+ * @return = true if VSync started the next frame first, and the *TimeoutError if ctx ended.
+ */
+func wait_for_href(ctx context.Context, Cam *Camera7670.OV7670, vsync_low *bool) (bool, error) {
+	for polls := 1; !Cam.HSync.Get(); polls++ {
+		if Cam.VSync.Get() {
+			if *vsync_low {
+				return true, nil
+			}
+		} else {
+			*vsync_low = true
+		}
+		if polls%Camera7670.DEADLINE_POLLS == 0 {
+			if err := Camera7670.Expired(ctx, Camera7670.SIGNAL_HREF, true); err != nil {
+				return false, err
+			}
+		}
+	}
+	return false, nil
+}
+
+/*
+ * @brief = Reads a line till HREF falls, keeping the bytes that fit.
+ * @param line = Destination of the stored bytes of the line.
+ * @param stride = Clocked bytes per stored byte, 2 keeps only the first byte of every pixel (GREYSCALED).
+ * @return = Bytes clocked while HREF was high and the *TimeoutError if ctx ended.
+ */
+func read_line(ctx context.Context, Cam *Camera7670.OV7670, line []byte, stride int) (int, error) {
+	clocked := 0
+	high := false
+	for polls := 1; Cam.HSync.Get(); polls++ {
+		pclk := Cam.PCLK.Get()
+		if pclk && !high {
+			if clocked%stride == 0 && clocked/stride < len(line) {
+				line[clocked/stride] = Cam.ReadPins()
+			}
+			clocked++
+		}
+		high = pclk
+
+		if polls%Camera7670.DEADLINE_POLLS == 0 {
+			if err := Camera7670.Expired(ctx, Camera7670.SIGNAL_HREF, false); err != nil {
+				return clocked, err
+			}
+		}
+	}
+	return clocked, nil
+}
+
+/*
+* @brief = Reads a frame line by line following HREF and reports its integrity, gives up after Cam.Timeout.
+* @param Cam = pointer to the OV7670 Object.
+* @return = The report of the frame and a *CaptureError if a signal stalled.
+! Handle Error.
+*/
+func (CamImage *CameraImage) ReadImageChecked(Cam *Camera7670.OV7670) (FrameReport, error) {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return CamImage.ReadImageCheckedContext(ctx, Cam)
+}
+
+/*
+* @brief = Reads a frame line by line following HREF and reports its integrity, till ctx ends.
+* ^ Missing bytes of short lines and missing lines are left at 0, extra bytes of long lines are dropped.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
+* @param Cam = pointer to the OV7670 Object.
+* @return = The report of the frame and a *CaptureError if a signal stalled.
+! Handle Error.
+*/
+func (CamImage *CameraImage) ReadImageCheckedContext(ctx context.Context, Cam *Camera7670.OV7670) (FrameReport, error) {
+	stored := get_image_type(CamImage.ImageType)
+	clocked := clocked_bytes(CamImage.ImageType)
+	width, height := CamImage.Window.Width, CamImage.Window.Height
+	report := FrameReport{LinesExpected: height, LineBytes: width * clocked, FirstBadLine: -1}
+	if len(CamImage.ImageData) < width*height*stored {
+		return report, fmt.Errorf("ImageData is too small for a %dx%d Image.", width, height)
+	}
+	clear(CamImage.ImageData)
+
+	if err := Cam.WaitForNewFrameContext(ctx); err != nil {
+		return report, capture_error(-1, 0, 0, err)
+	}
+
+	vsync_low := false
+	line_size := width * stored
+	for row := 0; row < height; row++ {
+		early, err := wait_for_href(ctx, Cam, &vsync_low)
+		if err != nil {
+			return report, capture_error(row, 0, row*line_size, err)
+		}
+		if early {
+			report.EarlyVSync = true
+			report.PixelsLost += (height - row) * width
+			report.bad_line(row)
+			break
+		}
+
+		count, err := read_line(ctx, Cam, CamImage.ImageData[row*line_size:(row+1)*line_size], clocked/stored)
+		if err != nil {
+			return report, capture_error(row, count/clocked, row*line_size+count/clocked*stored, err)
+		}
+		report.LinesReceived++
+
+		switch {
+		case count < report.LineBytes:
+			report.ShortLines++
+			report.PixelsLost += width - count/clocked
+			report.bad_line(row)
+		case count > report.LineBytes:
+			report.LongLines++
+			report.bad_line(row)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,75 @@
+package DataStructures
+
+import Camera7670 "PICO_OV7670/Camera"
+
+/*
+~ File Description:
+^ Unpacks the 2 byte RGB formats of OV7670 (RGB565, RGB555, RGB444 xR GB and RG Bx) to 8 bit channels.
+^ The two bytes are given in the order they leave the sensor, the first one carries red.
+*/
+
+// & OneLine Brief = Widens a 4, 5 or 6 bit channel to 8 bits by repeating its top bits.
+func widen(value uint8, bits uint) uint8 {
+	value <<= 8 - bits
+	return value | value>>bits
+}
+
+// & OneLine Brief = RGB565, first byte R4-R0 G5-G3, second byte G2-G0 B4-B0.
+func UnpackRGB565(first, second uint8) (uint8, uint8, uint8) {
+	item := uint16(first)<<8 | uint16(second)
+	return widen(uint8(item>>11)&0x1F, 5), widen(uint8(item>>5)&0x3F, 6), widen(uint8(item)&0x1F, 5)
+}
+
+// & OneLine Brief = RGB555, first byte x R4-R0 G4-G3, second byte G2-G0 B4-B0.
+func UnpackRGB555(first, second uint8) (uint8, uint8, uint8) {
+	item := uint16(first)<<8 | uint16(second)
+	return widen(uint8(item>>10)&0x1F, 5), widen(uint8(item>>5)&0x1F, 5), widen(uint8(item)&0x1F, 5)
+}
+
+// & OneLine Brief = RGB444 xR GB, first byte xxxx R3-R0, second byte G3-G0 B3-B0.
+func UnpackRGB444XRGB(first, second uint8) (uint8, uint8, uint8) {
+	return widen(first&0x0F, 4), widen(second>>4, 4), widen(second&0x0F, 4)
+}
+
+// & OneLine Brief = RGB444 RG Bx, first byte R3-R0 G3-G0, second byte B3-B0 xxxx.
+func UnpackRGB444RGBX(first, second uint8) (uint8, uint8, uint8) {
+	return widen(first>>4, 4), widen(first&0x0F, 4), widen(second>>4, 4)
+}
+
+/*
+ * @brief = Unpacks one pixel of any of the 2 byte RGB formats.
+ * @param image_type = RGB (RGB565), RGB555, RGB444_XRGB or RGB444_RGBX.
+ * @params first, second = The two bytes of the pixel in sensor order.
+ * @return = r, g, b values, black for formats which are not RGB.
+ */
+func UnpackRGBPixel(image_type Camera7670.IMAGE, first, second uint8) (uint8, uint8, uint8) {
+	switch image_type {
+	case Camera7670.RGB:
+		return UnpackRGB565(first, second)
+	case Camera7670.RGB555:
+		return UnpackRGB555(first, second)
+	case Camera7670.RGB444_XRGB:
+		return UnpackRGB444XRGB(first, second)
+	case Camera7670.RGB444_RGBX:
+		return UnpackRGB444RGBX(first, second)
+	}
+
+	return 0, 0, 0
+}
+
+/*
+* @brief = Converts 2 byte RGB data to RGB888 (3 bytes per pixel, R first).
+* @param dst = Destination, must hold at least len(src) / 2 * 3 bytes.
+* @param src = Raw image bytes.
+* @param image_type = Format of src.
+* @return = Number of bytes written to dst.
+ */
+func ConvertRGBToRGB888(dst, src []byte, image_type Camera7670.IMAGE) int {
+	count := 0
+	for i := 0; i+1 < len(src) && count+2 < len(dst); i += 2 {
+		dst[count], dst[count+1], dst[count+2] = UnpackRGBPixel(image_type, src[i], src[i+1])
+		count += 3
+	}
+
+	return count
+}
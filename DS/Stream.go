@@ -0,0 +1,141 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"context"
+	"fmt"
+	"io"
+)
+
+/*
+~ File Description:
+^ Streaming capture: every line is handed to a RowSink as soon as it is read, so only one line of the frame is in RAM.
+^ Frames too large for a CameraImage (VGA) can go to UART, a SD Card, an encoder or a reducer this way.
+^ The sink runs between two lines, with BitBang it has the blanking of HREF to return before the next line is missed.
+*/
+
+/*
+ * @brief = Layout of the rows of a streamed image.
+ * @element ImageType = Format of the image.
+ * @elements Width, Height = Size of the image in pixels.
+ * @element BytesPerPixel = Bytes stored per pixel, a row is Width * BytesPerPixel bytes.
+ */
+type RowFormat struct {
+	ImageType     Camera7670.IMAGE
+	Width         int
+	Height        int
+	BytesPerPixel int
+}
+
+// & OneLine Brief = Bytes of a row.
+func (f RowFormat) RowBytes() int {
+	return f.Width * f.BytesPerPixel
+}
+
+/*
+ * @brief = Receiver of the rows of a streamed capture.
+ * @method WriteRow = Takes row index (0 is the top) of the image, line is reused for the next row so it is copied if kept.
+ * ^ An error stops the capture and is returned in a *SinkError.
+ */
+type RowSink interface {
+	WriteRow(index int, format RowFormat, line []byte) error
+}
+
+// & RowSink made of a function, for per-row callbacks.
+type RowSinkFunc func(index int, format RowFormat, line []byte) error
+
+func (f RowSinkFunc) WriteRow(index int, format RowFormat, line []byte) error {
+	return f(index, format, line)
+}
+
+/*
+ * @brief = Error of a RowSink that stopped a capture.
+ * @element Row = Row the sink failed on.
+ * @element Err = Error of the sink.
+ */
+type SinkError struct {
+	Row int
+	Err error
+}
+
+func (e *SinkError) Error() string {
+	return fmt.Sprintf("Row sink failed at row %d: %v", e.Row, e.Err)
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// & RowSink writing the rows one byte at a time, *machine.UART on target.
+type UARTSink struct {
+	UART io.ByteWriter
+}
+
+func (s UARTSink) WriteRow(index int, format RowFormat, line []byte) error {
+	for _, value := range line {
+		if err := s.UART.WriteByte(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// & RowSink writing row n at Address + n * RowBytes, *sdcard.Device on target.
+type WriterAtSink struct {
+	Writer  io.WriterAt
+	Address int64
+}
+
+func (s WriterAtSink) WriteRow(index int, format RowFormat, line []byte) error {
+	_, err := s.Writer.WriteAt(line, s.Address+int64(index)*int64(format.RowBytes()))
+	return err
+}
+
+/*
+* @brief = Streams an image row by row to a RowSink, gives up after Cam.Timeout.
+* @param Sink = Receiver of the rows.
+* @param Cam = A pointer to a OV7670 Driver Object.
+* @param ImageType = Stores the format of image.
+* @param Resolution = Stores the size of image.
+* @return = *CorruptedImageError if the frame ended before the image was full, a *CaptureError if a signal stalled, a *SinkError if the sink failed.
+! Handle Error.
+*/
+func StreamImage(Sink RowSink, Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION) error {
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	return StreamImageContext(ctx, Sink, Cam, ImageType, Resolution)
+}
+
+/*
+* @brief = Streams an image row by row to a RowSink till ctx ends.
+* @param ctx = Context of the capture, its deadline or cancel stops the capture.
+* @params Sink, Cam, ImageType, Resolution = See StreamImage.
+* @return = *CorruptedImageError if the frame ended before the image was full, a *CaptureError if a signal stalled, a *SinkError if the sink failed.
+! Handle Error.
+*/
+func StreamImageContext(ctx context.Context, Sink RowSink, Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION) error {
+	width, height := get_frame_dimensions(Cam, Resolution)
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("Image of %dx%d Size has no pixels, stream CUSTOM images at the resolution of the camera.", width, height)
+	}
+	format := RowFormat{ImageType: ImageType, Width: width, Height: height, BytesPerPixel: get_image_type(ImageType)}
+	line := make([]byte, format.RowBytes())
+
+	backend, stride, err := start_frame(ctx, Cam, width, ImageType)
+	if err != nil {
+		return err
+	}
+	defer backend.EndFrame(Cam)
+
+	for row := 0; row < height; row++ {
+		if count, err := backend.ReadLine(ctx, Cam, line, stride); err != nil {
+			column := count / stride / format.BytesPerPixel
+			return line_error(row, height, column, row*len(line)+column*format.BytesPerPixel, err)
+		}
+		if err := Sink.WriteRow(row, format, line); err != nil {
+			return &SinkError{Row: row, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Checks a captured image of a sensor test pattern, see Camera7670.SetTestPattern.
+^ Shifting ones is compared byte for byte and shows swapped or stuck data pins, the bars are compared per pixel.
+*/
+
+// & Largest difference per colour channel accepted on the bar patterns, covers the rounding of RGB444 and YUV.
+const PATTERN_TOLERANCE = 24
+
+/*
+ * @brief = Result of a failed pattern check.
+ * @element Pattern = Pattern that was expected.
+ * @elements X, Y = First byte (shifting ones) or pixel (bars) of the image that did not match.
+ * @elements Expected, Got = Value at X, Y, a byte or 0xRRGGBB.
+ * @element Mismatches = Bytes or pixels that did not match.
+ * @element Checked = Bytes or pixels that were compared.
+ */
+type TestPatternError struct {
+	Pattern    Camera7670.TEST_PATTERN
+	X          int
+	Y          int
+	Expected   uint32
+	Got        uint32
+	Mismatches int
+	Checked    int
+}
+
+func (e *TestPatternError) Error() string {
+	if e.Pattern == Camera7670.TEST_PATTERN_SHIFTING_ONES {
+		return fmt.Sprintf("%s: %d of %d bytes differ, first at byte %d of line %d: expected %08b, got %08b", e.Pattern.String(), e.Mismatches, e.Checked, e.X, e.Y, e.Expected, e.Got)
+	}
+	return fmt.Sprintf("%s: %d of %d pixels differ, first at (%d, %d): expected %06X, got %06X", e.Pattern.String(), e.Mismatches, e.Checked, e.X, e.Y, e.Expected, e.Got)
+}
+
+// & OneLine Brief = Records a mismatch, the position of the first one is kept.
+func (e *TestPatternError) add(x, y int, expected, got uint32) {
+	if e.Mismatches == 0 {
+		e.X, e.Y, e.Expected, e.Got = x, y, expected, got
+	}
+	e.Mismatches++
+}
+
+// & OneLine Brief = Absolute difference of two bytes.
+func byte_distance(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+/*
+* @brief = Checks the image against the test pattern the camera outputs.
+* @param Cam = pointer to the OV7670 Object the image was captured from, gives the pattern and the whole frame.
+* @return = nil if the image matches, a *TestPatternError describing the differences, or an error if there is nothing to check.
+! Handle Error.
+*/
+func (CamImage *CameraImage) VerifyTestPattern(Cam *Camera7670.OV7670) error {
+	// Bayer sends 1 byte per pixel, ReadImage clocks 2 so the image does not hold the pattern.
+	if CamImage.ImageType == Camera7670.BAYER {
+		return fmt.Errorf("Test patterns can not be checked on a BAYER Image.")
+	}
+
+	pattern := Cam.TestPattern()
+	switch pattern {
+	case Camera7670.TEST_PATTERN_OFF:
+		return fmt.Errorf("The camera is not outputting a test pattern.")
+	case Camera7670.TEST_PATTERN_SHIFTING_ONES:
+		return CamImage.verify_shifting_ones()
+	}
+
+	return CamImage.verify_bars(pattern, Cam.Frame())
+}
+
+// & OneLine Brief = Compares every byte against the shifting ones pattern, laid out over the whole frame the window was cut from.
+func (CamImage *CameraImage) verify_shifting_ones() error {
+	result := &TestPatternError{Pattern: Camera7670.TEST_PATTERN_SHIFTING_ONES}
+	stored := get_image_type(CamImage.ImageType)
+	line_bytes := CamImage.Window.Width * stored
+	offset_x, offset_y := CamImage.Window.X*clocked_bytes(CamImage.ImageType), CamImage.Window.Y
+
+	// GREYSCALED keeps the first of the 2 bytes clocked for every pixel.
+	step := 1
+	if stored == 1 {
+		step = 2
+	}
+
+	for index, got := range CamImage.ImageData {
+		x, y := index%line_bytes, index/line_bytes
+		expected := Camera7670.ShiftingOnes(offset_x+x*step, offset_y+y)
+		if got != expected {
+			result.add(x, y, uint32(expected), uint32(got))
+		}
+		result.Checked++
+	}
+
+	if result.Mismatches != 0 {
+		return result
+	}
+	return nil
+}
+
+/*
+ * @brief = Compares every pixel against a bar pattern, pixels next to the edge of a bar are skipped.
+ * @param pattern = One of the bar patterns.
+ * @param frame = Whole frame the image window was cut from.
+ * @return = nil or the *TestPatternError.
+ */
+func (CamImage *CameraImage) verify_bars(pattern Camera7670.TEST_PATTERN, frame Camera7670.FrameFormat) error {
+	result := &TestPatternError{Pattern: pattern}
+	win := CamImage.Window
+
+	var rgb []byte
+	switch CamImage.ImageType {
+	case Camera7670.GREYSCALED:
+		rgb = CamImage.ImageData
+	default:
+		converted, err := CamImage.ToRGB888()
+		if err != nil {
+			return err
+		}
+		rgb = converted
+	}
+
+	for y := 0; y < win.Height; y++ {
+		for x := 0; x < win.Width; x++ {
+			fx, fy := x+win.X, y+win.Y
+			bar := Camera7670.ColorBar(fx, frame.Width)
+			if Camera7670.ColorBar(fx-1, frame.Width) != bar || Camera7670.ColorBar(fx+1, frame.Width) != bar {
+				continue
+			}
+
+			r, g, b := pattern.Pixel(fx, fy, frame.Width, frame.Height)
+			expected := uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+			index := y*win.Width + x
+
+			var got uint32
+			var matches bool
+			switch CamImage.ImageType {
+			case Camera7670.GREYSCALED:
+				luma := uint8((77*int(r) + 150*int(g) + 29*int(b)) >> 8)
+				expected = uint32(luma) * 0x010101
+				got = uint32(rgb[index]) * 0x010101
+				matches = byte_distance(rgb[index], luma) <= PATTERN_TOLERANCE
+			default:
+				pixel := rgb[3*index : 3*index+3]
+				got = uint32(pixel[0])<<16 | uint32(pixel[1])<<8 | uint32(pixel[2])
+				matches = byte_distance(pixel[0], r) <= PATTERN_TOLERANCE && byte_distance(pixel[1], g) <= PATTERN_TOLERANCE && byte_distance(pixel[2], b) <= PATTERN_TOLERANCE
+			}
+
+			if !matches {
+				result.add(x, y, expected, got)
+			}
+			result.Checked++
+		}
+	}
+
+	if result.Mismatches != 0 {
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build tinygo
+
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"machine"
+	"time"
+)
+
+/*
+* @brief = Flash an image to the USB Interface of pico.
+* @param Cam = A pointer to a OV7670 Driver Object.
+* @param ImageType = Stores the format of image.
+* @param Resolution = Stores the size of image.
+* @return = Error if caught any.
+! Handle Error.
+*/
+func FlashImage(Cam *Camera7670.OV7670, ImageType Camera7670.IMAGE, Resolution Camera7670.RESOLUTION) error {
+	W, H := get_frame_dimensions(Cam, Resolution)
+	CamImage, err := CreateWindowedImage(ImageType, Resolution, Camera7670.Window{Width: W, Height: H})
+	if err != nil {
+		return err
+	}
+
+	if err := CamImage.ReadImage(Cam); err != nil {
+		return err
+	}
+
+	for _, item := range CamImage.ImageData {
+		machine.USBCDC.WriteByte(item)
+		time.Sleep(time.Microsecond)
+	}
+
+	clear(CamImage.ImageData)
+	CamImage = nil
+
+	return nil
+}
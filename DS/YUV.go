@@ -0,0 +1,113 @@
+package DataStructures
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Helpers for YUV422 images: extracting the Y (luma) plane and converting to RGB888.
+^ ToRGB888 also accepts the RGB formats, unpacked by RGB.go.
+^ Every pair of pixels is 4 bytes holding Y0, Y1 and a shared U and V, placed according to the YUV_ORDER of the image.
+*/
+
+/*
+ * @brief = Converts one YUV pixel to RGB using full range BT.601 maths.
+ * @params y, u, v = Luma and the two chroma values, chroma centred on 128.
+ * @return = r, g, b values.
+ */
+func YUVToRGB888(y, u, v uint8) (uint8, uint8, uint8) {
+	Y, U, V := int(y), int(u)-128, int(v)-128
+	r := Y + (359*V)>>8
+	g := Y - (88*U+183*V)>>8
+	b := Y + (454*U)>>8
+
+	return clamp_byte(r), clamp_byte(g), clamp_byte(b)
+}
+
+// & OneLine Brief = Clamps an integer to the 0 - 255 range.
+func clamp_byte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+/*
+* @brief = Copies the Y values of a YUV image into a plane of one byte per pixel.
+* @param dst = Destination, must hold at least len(src) / 2 bytes.
+* @param src = Raw YUV422 bytes.
+* @param order = Byte order of src.
+* @return = Number of bytes written to dst.
+ */
+func ExtractYPlane(dst, src []byte, order Camera7670.YUV_ORDER) int {
+	y0, _, y1, _ := order.Offsets()
+	count := 0
+	for i := 0; i+3 < len(src) && count+1 < len(dst); i += 4 {
+		dst[count] = src[i+y0]
+		dst[count+1] = src[i+y1]
+		count += 2
+	}
+
+	return count
+}
+
+/*
+* @brief = Converts YUV422 bytes to RGB888 (3 bytes per pixel, R first).
+* @param dst = Destination, must hold at least len(src) / 2 * 3 bytes.
+* @param src = Raw YUV422 bytes.
+* @param order = Byte order of src.
+* @return = Number of bytes written to dst.
+ */
+func ConvertYUVToRGB888(dst, src []byte, order Camera7670.YUV_ORDER) int {
+	y0, u, y1, v := order.Offsets()
+	count := 0
+	for i := 0; i+3 < len(src) && count+5 < len(dst); i += 4 {
+		dst[count], dst[count+1], dst[count+2] = YUVToRGB888(src[i+y0], src[i+u], src[i+v])
+		dst[count+3], dst[count+4], dst[count+5] = YUVToRGB888(src[i+y1], src[i+u], src[i+v])
+		count += 6
+	}
+
+	return count
+}
+
+/*
+* @brief = Returns the luma plane of a YUV CameraImage, GREYSCALED images already are one.
+* @return = Width * Height bytes, and an error if the image is not YUV or GREYSCALED.
+! Handle Error.
+*/
+func (CamImage *CameraImage) YPlane() ([]byte, error) {
+	switch CamImage.ImageType {
+	case Camera7670.GREYSCALED:
+		return CamImage.ImageData, nil
+	case Camera7670.YUV:
+		plane := make([]byte, len(CamImage.ImageData)/2)
+		ExtractYPlane(plane, CamImage.ImageData, CamImage.YUVOrder)
+		return plane, nil
+	}
+
+	return nil, fmt.Errorf("Can not extract a Y plane from a %s Image.", CamImage.ImageType.String())
+}
+
+/*
+* @brief = Converts a YUV or 2 byte RGB CameraImage to RGB888.
+* @return = Width * Height * 3 bytes, and an error if the image is neither.
+! Handle Error.
+*/
+func (CamImage *CameraImage) ToRGB888() ([]byte, error) {
+	rgb := make([]byte, len(CamImage.ImageData)/2*3)
+	switch CamImage.ImageType {
+	case Camera7670.YUV:
+		ConvertYUVToRGB888(rgb, CamImage.ImageData, CamImage.YUVOrder)
+	case Camera7670.RGB, Camera7670.RGB555, Camera7670.RGB444_XRGB, Camera7670.RGB444_RGBX:
+		ConvertRGBToRGB888(rgb, CamImage.ImageData, CamImage.ImageType)
+	default:
+		return nil, fmt.Errorf("Can not convert a %s Image to RGB888.", CamImage.ImageType.String())
+	}
+
+	return rgb, nil
+}
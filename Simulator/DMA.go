@@ -0,0 +1,207 @@
+package Sim7670
+
+import Camera7670 "PICO_OV7670/Camera"
+
+/*
+~ File Description:
+^ DMA of the emulated RP2040: channels that move one word per cycle when their data request is up, and chain to each other.
+^ Memory is the buffers handed to Address, a write outside of them is a Fault instead of corrupting anything.
+^ Covered: word transfers from the RX FIFOs of the PIO or memory, TRANS_COUNT reload, CHAIN_TO, INTR and CHAN_ABORT.
+*/
+
+// & Base address of the first emulated buffer, buffers are spaced so an overrun never lands in the next one.
+const (
+	MEMORY_BASE = 0x20000000
+	MEMORY_GAP  = 0x1000
+)
+
+// & TREQ_SEL value of a channel that transfers as fast as it can.
+const dma_treq_permanent = 0x3F
+
+// & One emulated DMA channel.
+type dmaChannel struct {
+	read   uint32
+	write  uint32
+	reload uint32
+	count  uint32
+	ctrl   uint32
+	busy   bool
+}
+
+// & A buffer in the emulated memory.
+type memoryRegion struct {
+	base  uint32
+	words []uint32
+}
+
+// & Emulated DMA with its memory.
+type dmaController struct {
+	channels [Camera7670.DMA_CHANNELS]dmaChannel
+	intr     uint32
+	memory   []memoryRegion
+	next     uint32
+}
+
+// & OneLine Brief = Starts a channel with its TRANS_COUNT reload value.
+func (d *dmaController) trigger(channel int) {
+	c := &d.channels[channel]
+	if c.ctrl&Camera7670.DMA_CTRL_EN == 0 {
+		return
+	}
+	c.count, c.busy = c.reload, c.reload > 0
+}
+
+// & OneLine Brief = Word at a memory address, nil outside of every buffer.
+func (d *dmaController) word(address uint32) *uint32 {
+	for _, region := range d.memory {
+		if address >= region.base && address < region.base+uint32(len(region.words))*4 && address%4 == 0 {
+			return &region.words[(address-region.base)/4]
+		}
+	}
+	return nil
+}
+
+// & OneLine Brief = Moves one word on every busy channel whose data request is up.
+func (d *dmaController) step(e *PIOEmulator) {
+	for index := range d.channels {
+		c := &d.channels[index]
+		if !c.busy {
+			continue
+		}
+		if (c.ctrl>>2)&0x03 != 2 {
+			e.fault("DMA: channel %d only word transfers are emulated", index)
+			c.busy = false
+			continue
+		}
+
+		treq := (c.ctrl >> Camera7670.DMA_CTRL_TREQ_SEL_Pos) & 0x3F
+		var value uint32
+		switch {
+		case treq >= Camera7670.DMA_DREQ_PIO0_RX0 && treq < Camera7670.DMA_DREQ_PIO0_RX0+4:
+			machine := &e.machines[treq-Camera7670.DMA_DREQ_PIO0_RX0]
+			if len(machine.rx) == 0 {
+				continue
+			}
+			if c.read != e.PIOAddress(Camera7670.PIO_RXF0+4*(treq-Camera7670.DMA_DREQ_PIO0_RX0)) {
+				e.fault("DMA: channel %d is paced by the RX FIFO of SM%d but reads 0x%08X", index, treq-Camera7670.DMA_DREQ_PIO0_RX0, c.read)
+				c.busy = false
+				continue
+			}
+			value, _ = machine.pop()
+		case treq == dma_treq_permanent:
+			source := d.word(c.read)
+			if source == nil {
+				e.fault("DMA: channel %d reads 0x%08X outside of memory", index, c.read)
+				c.busy = false
+				continue
+			}
+			value = *source
+		default:
+			continue
+		}
+
+		destination := d.word(c.write)
+		if destination == nil {
+			e.fault("DMA: channel %d writes 0x%08X outside of memory", index, c.write)
+			c.busy = false
+			continue
+		}
+		*destination = value
+		if c.ctrl&Camera7670.DMA_CTRL_INCR_READ != 0 {
+			c.read += 4
+		}
+		if c.ctrl&Camera7670.DMA_CTRL_INCR_WRITE != 0 {
+			c.write += 4
+		}
+
+		c.count--
+		if c.count == 0 {
+			c.busy = false
+			if c.ctrl&(1<<21) == 0 { // IRQ_QUIET
+				d.intr |= 1 << index
+			}
+			if chain := int(c.ctrl>>Camera7670.DMA_CTRL_CHAIN_TO_Pos) & 0x0F; chain != index {
+				d.trigger(chain)
+			}
+		}
+	}
+}
+
+func (e *PIOEmulator) ReadDMA(offset uint32) uint32 {
+	switch offset {
+	case Camera7670.DMA_INTR:
+		return e.dma.intr
+	case Camera7670.DMA_CHAN_ABORT:
+		return 0
+	}
+	if offset >= Camera7670.DMA_CHANNELS*Camera7670.DMA_CHANNEL_STRIDE {
+		return 0
+	}
+
+	c := &e.dma.channels[offset/Camera7670.DMA_CHANNEL_STRIDE]
+	switch offset % Camera7670.DMA_CHANNEL_STRIDE {
+	case Camera7670.DMA_READ_ADDR:
+		return c.read
+	case Camera7670.DMA_WRITE_ADDR:
+		return c.write
+	case Camera7670.DMA_TRANS_COUNT:
+		return c.count
+	case Camera7670.DMA_CTRL_TRIG, Camera7670.DMA_AL1_CTRL:
+		if c.busy {
+			return c.ctrl | Camera7670.DMA_CTRL_BUSY
+		}
+		return c.ctrl
+	}
+	return 0
+}
+
+func (e *PIOEmulator) WriteDMA(offset uint32, value uint32) {
+	switch offset {
+	case Camera7670.DMA_INTR:
+		e.dma.intr &^= value
+		return
+	case Camera7670.DMA_CHAN_ABORT:
+		for index := range e.dma.channels {
+			if value&(1<<index) != 0 {
+				e.dma.channels[index].busy = false
+			}
+		}
+		return
+	}
+	if offset >= Camera7670.DMA_CHANNELS*Camera7670.DMA_CHANNEL_STRIDE {
+		return
+	}
+
+	channel := int(offset / Camera7670.DMA_CHANNEL_STRIDE)
+	c := &e.dma.channels[channel]
+	switch offset % Camera7670.DMA_CHANNEL_STRIDE {
+	case Camera7670.DMA_READ_ADDR:
+		c.read = value
+	case Camera7670.DMA_WRITE_ADDR:
+		c.write = value
+	case Camera7670.DMA_TRANS_COUNT:
+		c.reload = value
+	case Camera7670.DMA_CTRL_TRIG:
+		c.ctrl = value &^ Camera7670.DMA_CTRL_BUSY
+		e.dma.trigger(channel)
+	case Camera7670.DMA_AL1_CTRL:
+		c.ctrl = value &^ Camera7670.DMA_CTRL_BUSY
+	}
+}
+
+// & OneLine Brief = Address of a buffer in the emulated memory, a buffer gets the same address every time.
+func (e *PIOEmulator) Address(buffer []uint32) uint32 {
+	d := &e.dma
+	for _, region := range d.memory {
+		if len(region.words) > 0 && len(buffer) > 0 && &region.words[0] == &buffer[0] {
+			return region.base
+		}
+	}
+	if d.next == 0 {
+		d.next = MEMORY_BASE
+	}
+	region := memoryRegion{base: d.next, words: buffer}
+	d.memory = append(d.memory, region)
+	d.next += (uint32(len(buffer))*4 + MEMORY_GAP) &^ (MEMORY_GAP - 1)
+	return region.base
+}
@@ -0,0 +1,104 @@
+package Sim7670
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	DataStructures "PICO_OV7670/DS"
+	"bytes"
+	"context"
+	"testing"
+)
+
+/*
+~ File Description:
+^ Host tests of the checked capture: glitches on PCLK and a window taller than the frame give FrameReports of known values.
+*/
+
+/*
+ * @brief = PCLK with a glitch on some bytes of one line, as a noisy wire would have.
+ * @element row = Active row of the glitch.
+ * @elements from, to = Bytes of the row the glitch covers.
+ * @element extra = Dip in the high half of every byte, an extra edge each, instead of holding PCLK low and losing the edges.
+ */
+type glitchPCLK struct {
+	s        *Sensor
+	row      int
+	from, to int
+	extra    bool
+}
+
+func (p glitchPCLK) Get() bool {
+	high := p.s.PCLK().Get()
+	row, column := p.s.activeRow()
+	if row != p.row || column < p.from || column >= p.to {
+		return high
+	}
+	if !p.extra {
+		return false
+	}
+	// 2 ticks wide so the capture loop, 2 ticks a poll, samples it.
+	half := p.s.byteTicks() / 2
+	dip := p.s.tick%p.s.byteTicks() - half
+	return high && (dip < half/4 || dip >= half/4+2)
+}
+
+// & Width of the window the checked captures read, narrow to keep the tests fast.
+const checkedWidth = 40
+
+// & OneLine Brief = Checked capture of an image of height lines, the report and the captured image.
+func checkedCapture(t *testing.T, Cam *Camera7670.OV7670, height int) (DataStructures.FrameReport, *DataStructures.CameraImage) {
+	t.Helper()
+	frame, err := DataStructures.CreateWindowedImage(Camera7670.RGB, Camera7670.QQVGA, Camera7670.Window{Width: checkedWidth, Height: height})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := frame.ReadImageCheckedContext(context.Background(), Cam)
+	if err != nil {
+		t.Fatalf("ReadImageCheckedContext: %v", err)
+	}
+	return report, frame
+}
+
+func TestReadImageChecked(t *testing.T) {
+	cases := []struct {
+		name   string
+		pclk   *glitchPCLK
+		height int
+		want   DataStructures.FrameReport
+	}{
+		{"clean", nil, 120, DataStructures.FrameReport{LinesExpected: 120, LinesReceived: 120, LineBytes: 2 * checkedWidth, FirstBadLine: -1}},
+		// 4 edges lost are 2 pixels.
+		{"short line", &glitchPCLK{row: 37, from: 20, to: 24}, 120, DataStructures.FrameReport{LinesExpected: 120, LinesReceived: 120, LineBytes: 2 * checkedWidth, FirstBadLine: 37, ShortLines: 1, PixelsLost: 2}},
+		{"long line", &glitchPCLK{row: 50, from: 10, to: 13, extra: true}, 120, DataStructures.FrameReport{LinesExpected: 120, LinesReceived: 120, LineBytes: 2 * checkedWidth, FirstBadLine: 50, LongLines: 1}},
+		// The sensor sends 120 lines, the image wants 125.
+		{"early VSync", nil, 125, DataStructures.FrameReport{LinesExpected: 125, LinesReceived: 120, LineBytes: 2 * checkedWidth, FirstBadLine: 120, PixelsLost: 5 * checkedWidth, EarlyVSync: true}},
+	}
+
+	for _, c := range cases {
+		s, Cam := newBarCamera(t, Camera7670.RGB)
+		if err := Cam.SetWindow(0, 0, checkedWidth, 120); err != nil {
+			t.Fatalf("SetWindow: %v", err)
+		}
+		if c.pclk != nil {
+			// A byte lasts 16 ticks, the dip of an extra edge fits in its high half.
+			s.PollsPerClock = 8
+			c.pclk.s = s
+			Cam.PCLK = c.pclk
+		}
+		golden := s.Frame()
+
+		report, frame := checkedCapture(t, Cam, c.height)
+		if report != c.want {
+			t.Errorf("%s: report = %s, want %s", c.name, report, c.want)
+		}
+		if report.Usable() != (c.name == "clean") {
+			t.Errorf("%s: Usable = %t", c.name, report.Usable())
+		}
+		// Lines before the first bad one are whole.
+		if good := max(min(report.FirstBadLine, 120), 0) * 2 * checkedWidth; report.FirstBadLine >= 0 && !bytes.Equal(frame.ImageData[:good], golden[:good]) {
+			t.Errorf("%s: lines before line %d differ from the frame of the sensor", c.name, report.FirstBadLine)
+		}
+		if c.name == "clean" && !bytes.Equal(frame.ImageData, golden) {
+			t.Errorf("clean: captured frame differs from the frame of the sensor")
+		}
+	}
+}
@@ -0,0 +1,600 @@
+package Sim7670
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"fmt"
+	"math/bits"
+)
+
+/*
+~ File Description:
+^ Emulator of a PIO block of the RP2040 with the DMA (DMA.go), reached through the same register offsets as the chip.
+^ It runs the PIO programs the driver generates one instruction per cycle against a GPIO input register, so the program,
+^ its state machine configuration and the DMA chaining are checked on a host.
+^ Covered: all instructions except OUT/MOV to EXEC and side-set, autopush, FIFO joins, wrap, SMx_INSTR, FDEBUG and FSTAT.
+^ Output pins, the clock divider fraction and the interrupts to the CPU are not emulated.
+*/
+
+// & Compile time check that the emulator is a PIOHardware.
+var _ Camera7670.PIOHardware = (*PIOEmulator)(nil)
+
+// & Depth of one FIFO of a state machine, twice that when joined.
+const PIO_FIFO_DEPTH = 4
+
+// & Base address of PIO0 in the emulated bus.
+const PIO0_BASE = 0x50200000
+
+// & One state machine of the emulated PIO block.
+type pioMachine struct {
+	index     uint8
+	clkdiv    uint32
+	execctrl  uint32
+	shiftctrl uint32
+	pinctrl   uint32
+
+	pc       uint8
+	x, y     uint32
+	isr, osr uint32
+	isrCount int
+	osrCount int
+	delay    int
+	divider  int
+	irqWait  bool
+	tx, rx   []uint32
+}
+
+/*
+ * @brief = Emulated PIO block with its DMA.
+ * @element Input = Returns the GPIO input register, bit n being GPIOn.
+ * @element Program = Instruction memory.
+ * @element Cycles = Cycles run by Step.
+ * @element Fault = First thing the emulator could not do, an unsupported instruction or a DMA write outside every buffer.
+ */
+type PIOEmulator struct {
+	Input   func() uint32
+	Program [Camera7670.PIO_INSTR_COUNT]uint16
+	Cycles  uint64
+	Fault   error
+
+	enabled  uint8
+	fdebug   uint32
+	irq      uint8
+	machines [4]pioMachine
+	dma      dmaController
+}
+
+/*
+ * @brief = Creates an emulated PIO block in its reset state.
+ * @param input = Source of the GPIO input register.
+ * @return = pointer of a PIOEmulator Object.
+ */
+func CreatePIOEmulator(input func() uint32) *PIOEmulator {
+	e := &PIOEmulator{Input: input}
+	for index := range e.machines {
+		m := &e.machines[index]
+		m.index = uint8(index)
+		m.clkdiv = 1 << Camera7670.PIO_CLKDIV_INT_Pos
+		m.execctrl = 0x1F << Camera7670.PIO_EXECCTRL_WRAP_TOP_Pos
+		m.shiftctrl = Camera7670.PIO_SHIFTCTRL_IN_SHIFTDIR | Camera7670.PIO_SHIFTCTRL_OUT_SHIFTDIR
+		m.restart()
+	}
+	return e
+}
+
+// & OneLine Brief = Records the first fault.
+func (e *PIOEmulator) fault(format string, args ...any) {
+	if e.Fault == nil {
+		e.Fault = fmt.Errorf(format, args...)
+	}
+}
+
+// & OneLine Brief = Clears the shift counters, the delay and any stall like SM_RESTART.
+func (m *pioMachine) restart() {
+	m.isr, m.isrCount = 0, 0
+	m.osrCount = 32
+	m.delay, m.divider = 0, 0
+	m.irqWait = false
+}
+
+// & OneLine Brief = Depths of the TX and RX FIFOs after the joins of SHIFTCTRL.
+func (m *pioMachine) depths() (int, int) {
+	switch {
+	case m.shiftctrl&Camera7670.PIO_SHIFTCTRL_FJOIN_RX != 0:
+		return 0, 2 * PIO_FIFO_DEPTH
+	case m.shiftctrl&(1<<30) != 0:
+		return 2 * PIO_FIFO_DEPTH, 0
+	}
+	return PIO_FIFO_DEPTH, PIO_FIFO_DEPTH
+}
+
+// & OneLine Brief = Threshold of a shift register, 0 in the register means 32.
+func threshold(value uint32) int {
+	if value&0x1F == 0 {
+		return 32
+	}
+	return int(value & 0x1F)
+}
+
+func (m *pioMachine) pushThreshold() int { return threshold(m.shiftctrl >> 20) }
+func (m *pioMachine) pullThreshold() int { return threshold(m.shiftctrl >> 25) }
+func (m *pioMachine) inBase() uint8 {
+	return uint8(m.pinctrl>>Camera7670.PIO_PINCTRL_IN_BASE_Pos) & 0x1F
+}
+
+// & OneLine Brief = GPIO input register rotated so IN_BASE is bit 0.
+func (m *pioMachine) pins(input uint32) uint32 {
+	return bits.RotateLeft32(input, -int(m.inBase()))
+}
+
+/*
+ * @brief = Runs every enabled state machine and the DMA for a number of cycles.
+ * @param cycles = Cycles to run.
+ */
+func (e *PIOEmulator) Step(cycles int) {
+	for ; cycles > 0; cycles-- {
+		input := e.Input()
+		for index := range e.machines {
+			if e.enabled&(1<<index) == 0 {
+				continue
+			}
+			m := &e.machines[index]
+			m.divider++
+			if m.divider < max(int(m.clkdiv>>Camera7670.PIO_CLKDIV_INT_Pos), 1) {
+				continue
+			}
+			m.divider = 0
+			if m.delay > 0 {
+				m.delay--
+				continue
+			}
+			instruction := e.Program[m.pc]
+			if !e.execute(m, instruction, input, false) {
+				continue
+			}
+			// Delay bits left over by side-set.
+			delay_bits := 5 - int(m.pinctrl>>29)
+			m.delay = int(instruction>>8) & (1<<delay_bits - 1)
+		}
+		e.dma.step(e)
+		e.Cycles++
+	}
+}
+
+/*
+ * @brief = Executes an instruction on a state machine.
+ * @param exec = The instruction was written to SMx_INSTR, only a jump moves the program counter.
+ * @return = false if the machine stalls on it, it is executed again the next cycle.
+ */
+func (e *PIOEmulator) execute(m *pioMachine, instruction uint16, input uint32, exec bool) bool {
+	jumped := false
+	switch instruction >> 13 {
+	case 0: // JMP
+		var take bool
+		switch (instruction >> 5) & 0x07 {
+		case 0:
+			take = true
+		case 1:
+			take = m.x == 0
+		case 2:
+			take = m.x != 0
+			m.x--
+		case 3:
+			take = m.y == 0
+		case 4:
+			take = m.y != 0
+			m.y--
+		case 5:
+			take = m.x != m.y
+		case 6:
+			take = input&(1<<((m.execctrl>>24)&0x1F)) != 0
+		case 7:
+			take = m.osrCount < m.pullThreshold()
+		}
+		if take {
+			m.pc, jumped = uint8(instruction&0x1F), true
+		}
+
+	case 1: // WAIT
+		polarity := instruction&0x80 != 0
+		index := uint8(instruction & 0x1F)
+		switch (instruction >> 5) & 0x03 {
+		case 0:
+			if (input&(1<<index) != 0) != polarity {
+				return false
+			}
+		case 1:
+			if (m.pins(input)&(1<<index) != 0) != polarity {
+				return false
+			}
+		case 2:
+			flag := e.irqIndex(m, index)
+			if (e.irq&flag != 0) != polarity {
+				return false
+			}
+			if polarity {
+				e.irq &^= flag
+			}
+		default:
+			e.fault("PIO: reserved WAIT source at %d", m.pc)
+		}
+
+	case 2: // IN
+		count := threshold(uint32(instruction))
+		var data uint32
+		switch (instruction >> 5) & 0x07 {
+		case 0:
+			data = m.pins(input)
+		case 1:
+			data = m.x
+		case 2:
+			data = m.y
+		case 6:
+			data = m.isr
+		case 7:
+			data = m.osr
+		}
+		if count < 32 {
+			data &= 1<<count - 1
+		}
+		isr, isrCount := shift_in(m.isr, data, count, m.shiftctrl&Camera7670.PIO_SHIFTCTRL_IN_SHIFTDIR != 0), min(m.isrCount+count, 32)
+		if m.shiftctrl&Camera7670.PIO_SHIFTCTRL_AUTOPUSH != 0 && isrCount >= m.pushThreshold() {
+			if !m.push(e, isr) {
+				return false
+			}
+			isr, isrCount = 0, 0
+		}
+		m.isr, m.isrCount = isr, isrCount
+
+	case 3: // OUT
+		count := threshold(uint32(instruction))
+		var data uint32
+		if m.shiftctrl&Camera7670.PIO_SHIFTCTRL_OUT_SHIFTDIR != 0 {
+			data = m.osr & (1<<count - 1)
+			if count == 32 {
+				data, m.osr = m.osr, 0
+			} else {
+				m.osr >>= count
+			}
+		} else {
+			data = m.osr >> (32 - count)
+			if count == 32 {
+				m.osr = 0
+			} else {
+				m.osr <<= count
+			}
+		}
+		m.osrCount = min(m.osrCount+count, 32)
+		switch (instruction >> 5) & 0x07 {
+		case 1:
+			m.x = data
+		case 2:
+			m.y = data
+		case 5:
+			m.pc, jumped = uint8(data&0x1F), true
+		case 6:
+			m.isr, m.isrCount = data, count
+		case 7:
+			e.fault("PIO: OUT EXEC is not emulated, at %d", m.pc)
+		}
+
+	case 4: // PUSH / PULL
+		if_flag, block := instruction&0x40 != 0, instruction&0x20 != 0
+		if instruction&0x80 == 0 {
+			if if_flag && m.isrCount < m.pushThreshold() {
+				break
+			}
+			if !m.push(e, m.isr) {
+				if block {
+					return false
+				}
+			}
+			m.isr, m.isrCount = 0, 0
+		} else {
+			if if_flag && m.osrCount < m.pullThreshold() {
+				break
+			}
+			if len(m.tx) == 0 {
+				if block {
+					return false
+				}
+				m.osr = m.x
+			} else {
+				m.osr, m.tx = m.tx[0], m.tx[1:]
+			}
+			m.osrCount = 0
+		}
+
+	case 5: // MOV
+		var data uint32
+		switch instruction & 0x07 {
+		case 0:
+			data = m.pins(input)
+		case 1:
+			data = m.x
+		case 2:
+			data = m.y
+		case 6:
+			data = m.isr
+		case 7:
+			data = m.osr
+		}
+		switch (instruction >> 3) & 0x03 {
+		case 1:
+			data = ^data
+		case 2:
+			data = bits.Reverse32(data)
+		}
+		switch (instruction >> 5) & 0x07 {
+		case 1:
+			m.x = data
+		case 2:
+			m.y = data
+		case 4:
+			e.fault("PIO: MOV EXEC is not emulated, at %d", m.pc)
+		case 5:
+			m.pc, jumped = uint8(data&0x1F), true
+		case 6:
+			m.isr, m.isrCount = data, 0
+		case 7:
+			m.osr, m.osrCount = data, 0
+		}
+
+	case 6: // IRQ
+		flag := e.irqIndex(m, uint8(instruction&0x1F))
+		switch {
+		case instruction&0x40 != 0:
+			e.irq &^= flag
+		case m.irqWait:
+			if e.irq&flag != 0 {
+				return false
+			}
+			m.irqWait = false
+		default:
+			e.irq |= flag
+			if instruction&0x20 != 0 {
+				m.irqWait = true
+				return false
+			}
+		}
+
+	case 7: // SET
+		data := uint32(instruction & 0x1F)
+		switch (instruction >> 5) & 0x07 {
+		case 1:
+			m.x = data
+		case 2:
+			m.y = data
+		}
+	}
+
+	if !jumped && !exec {
+		if m.pc == uint8(m.execctrl>>Camera7670.PIO_EXECCTRL_WRAP_TOP_Pos)&0x1F {
+			m.pc = uint8(m.execctrl>>Camera7670.PIO_EXECCTRL_WRAP_BOTTOM_Pos) & 0x1F
+		} else {
+			m.pc = (m.pc + 1) % Camera7670.PIO_INSTR_COUNT
+		}
+	}
+	return true
+}
+
+// & OneLine Brief = Shifts count bits of data into a shift register in the direction of SHIFTCTRL.
+func shift_in(register, data uint32, count int, right bool) uint32 {
+	if count == 32 {
+		return data
+	}
+	if right {
+		return register>>count | data<<(32-count)
+	}
+	return register<<count | data
+}
+
+// & OneLine Brief = Flag of an IRQ index, the REL bit adds the number of the state machine.
+func (e *PIOEmulator) irqIndex(m *pioMachine, index uint8) uint8 {
+	if index&0x10 != 0 {
+		index = index&0x04 | (index+m.index)&0x03
+	}
+	return 1 << (index & 0x07)
+}
+
+// & OneLine Brief = Pushes a word to the RX FIFO, false and RXSTALL when it is full.
+func (m *pioMachine) push(e *PIOEmulator, word uint32) bool {
+	if _, depth := m.depths(); len(m.rx) >= depth {
+		e.fdebug |= Camera7670.PIO_FDEBUG_RXSTALL << m.index
+		return false
+	}
+	m.rx = append(m.rx, word)
+	return true
+}
+
+// & OneLine Brief = Pops the RX FIFO of a machine, 0 when it is empty.
+func (m *pioMachine) pop() (uint32, bool) {
+	if len(m.rx) == 0 {
+		return 0, false
+	}
+	word := m.rx[0]
+	m.rx = m.rx[1:]
+	return word, true
+}
+
+// & OneLine Brief = State machine and register of an offset in the SMx block, nil if the offset is not in it.
+func (e *PIOEmulator) machine(offset uint32) (*pioMachine, uint32) {
+	if offset < Camera7670.PIO_SM0_CLKDIV || offset >= Camera7670.PIO_SM0_CLKDIV+4*Camera7670.PIO_SM_STRIDE {
+		return nil, 0
+	}
+	index := (offset - Camera7670.PIO_SM0_CLKDIV) / Camera7670.PIO_SM_STRIDE
+	return &e.machines[index], offset - index*Camera7670.PIO_SM_STRIDE
+}
+
+func (e *PIOEmulator) ReadPIO(offset uint32) uint32 {
+	switch {
+	case offset == Camera7670.PIO_CTRL:
+		return uint32(e.enabled)
+	case offset == Camera7670.PIO_FSTAT:
+		var fstat uint32
+		for index := range e.machines {
+			m := &e.machines[index]
+			tx_depth, rx_depth := m.depths()
+			if len(m.rx) >= rx_depth {
+				fstat |= 1 << index
+			}
+			if len(m.rx) == 0 {
+				fstat |= 1 << (8 + index)
+			}
+			if len(m.tx) >= tx_depth {
+				fstat |= 1 << (16 + index)
+			}
+			if len(m.tx) == 0 {
+				fstat |= 1 << (24 + index)
+			}
+		}
+		return fstat
+	case offset == Camera7670.PIO_FDEBUG:
+		return e.fdebug
+	case offset >= Camera7670.PIO_RXF0 && offset < Camera7670.PIO_RXF0+16:
+		word, ok := e.machines[(offset-Camera7670.PIO_RXF0)/4].pop()
+		if !ok {
+			e.fdebug |= 1 << (8 + (offset-Camera7670.PIO_RXF0)/4)
+		}
+		return word
+	}
+
+	m, register := e.machine(offset)
+	switch register {
+	case Camera7670.PIO_SM0_CLKDIV:
+		return m.clkdiv
+	case Camera7670.PIO_SM0_EXECCTRL:
+		return m.execctrl
+	case Camera7670.PIO_SM0_SHIFTCTRL:
+		return m.shiftctrl
+	case Camera7670.PIO_SM0_ADDR:
+		return uint32(m.pc)
+	case Camera7670.PIO_SM0_INSTR:
+		return uint32(e.Program[m.pc])
+	case Camera7670.PIO_SM0_PINCTRL:
+		return m.pinctrl
+	}
+	return 0
+}
+
+func (e *PIOEmulator) WritePIO(offset uint32, value uint32) {
+	switch {
+	case offset == Camera7670.PIO_CTRL:
+		e.enabled = uint8(value & 0x0F)
+		for index := range e.machines {
+			if value&(Camera7670.PIO_CTRL_SM_RESTART<<index) != 0 {
+				e.machines[index].restart()
+			}
+		}
+		return
+	case offset == Camera7670.PIO_FDEBUG:
+		e.fdebug &^= value
+		return
+	case offset >= Camera7670.PIO_TXF0 && offset < Camera7670.PIO_TXF0+16:
+		m := &e.machines[(offset-Camera7670.PIO_TXF0)/4]
+		if depth, _ := m.depths(); len(m.tx) < depth {
+			m.tx = append(m.tx, value)
+		} else {
+			e.fdebug |= 1 << (16 + m.index)
+		}
+		return
+	case offset >= Camera7670.PIO_INSTR_MEM0 && offset < Camera7670.PIO_INSTR_MEM0+4*Camera7670.PIO_INSTR_COUNT:
+		e.Program[(offset-Camera7670.PIO_INSTR_MEM0)/4] = uint16(value)
+		return
+	}
+
+	m, register := e.machine(offset)
+	switch register {
+	case Camera7670.PIO_SM0_CLKDIV:
+		m.clkdiv = value
+	case Camera7670.PIO_SM0_EXECCTRL:
+		m.execctrl = value
+	case Camera7670.PIO_SM0_SHIFTCTRL:
+		// Changing a FIFO join empties both FIFOs.
+		if (m.shiftctrl^value)&(3<<30) != 0 {
+			m.tx, m.rx = nil, nil
+		}
+		m.shiftctrl = value
+	case Camera7670.PIO_SM0_INSTR:
+		e.execute(m, uint16(value), e.Input(), true)
+	case Camera7670.PIO_SM0_PINCTRL:
+		m.pinctrl = value
+	}
+}
+
+func (e *PIOEmulator) PIOAddress(offset uint32) uint32 {
+	return PIO0_BASE + offset
+}
+
+/*
+ * @brief = PIOEmulator fed by the outputs of a Simulated OV7670, every register access lets the sensor and the PIO run one tick.
+ * ^ The PIO catches up with ticks the driver spent polling pins, so it sees every edge the sensor made.
+ * @element pins = GPIOs the sensor outputs are wired to.
+ */
+type SensorPIO struct {
+	*PIOEmulator
+	sensor *Sensor
+	pins   Camera7670.PIOPins
+	tick   uint64
+}
+
+/*
+ * @brief = Creates an emulated PIO block wired to the outputs of the sensor.
+ * @param pins = GPIO of VSync, HREF, PCLK and the data pins, the same the PIOBackend is given.
+ * @return = pointer of a SensorPIO Object.
+ */
+func (s *Sensor) CreatePIO(pins Camera7670.PIOPins) *SensorPIO {
+	p := &SensorPIO{sensor: s, pins: pins, tick: s.tick}
+	p.PIOEmulator = CreatePIOEmulator(p.input)
+	return p
+}
+
+// & OneLine Brief = GPIO input register with the outputs of the sensor at the tick of the PIO.
+func (p *SensorPIO) input() uint32 {
+	now := p.sensor.tick
+	p.sensor.tick = p.tick
+	defer func() { p.sensor.tick = now }()
+
+	var register uint32
+	set := func(gpio uint8, high bool) {
+		if high {
+			register |= 1 << gpio
+		}
+	}
+	set(p.pins.VSync, p.sensor.Level(SIGNAL_VSYNC))
+	set(p.pins.HREF, p.sensor.Level(SIGNAL_HREF))
+	set(p.pins.PCLK, p.sensor.Level(SIGNAL_PCLK))
+	if p.pins.Data != nil {
+		register |= p.pins.Data.Spread(p.sensor.Data())
+	}
+	return register
+}
+
+// & OneLine Brief = Advances the sensor one tick and runs the PIO for every tick it is behind.
+func (p *SensorPIO) sync() {
+	p.sensor.Advance(1)
+	p.tick = min(p.tick, p.sensor.tick)
+	for p.tick < p.sensor.tick {
+		p.PIOEmulator.Step(1)
+		p.tick++
+	}
+}
+
+func (p *SensorPIO) ReadPIO(offset uint32) uint32 {
+	p.sync()
+	return p.PIOEmulator.ReadPIO(offset)
+}
+
+func (p *SensorPIO) WritePIO(offset uint32, value uint32) {
+	p.sync()
+	p.PIOEmulator.WritePIO(offset, value)
+}
+
+func (p *SensorPIO) ReadDMA(offset uint32) uint32 {
+	p.sync()
+	return p.PIOEmulator.ReadDMA(offset)
+}
+
+func (p *SensorPIO) WriteDMA(offset uint32, value uint32) {
+	p.sync()
+	p.PIOEmulator.WriteDMA(offset, value)
+}
@@ -0,0 +1,165 @@
+package Sim7670
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	DataStructures "PICO_OV7670/DS"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+/*
+~ File Description:
+^ Host tests of the PIO capture: the program the driver assembles runs in the PIO and DMA emulator against the simulated sensor.
+*/
+
+// & OneLine Brief = Capture pins with the data lines on the GPIOs given, the sync pins on GPIO0 - GPIO2.
+func pioPins(t *testing.T, data ...uint8) Camera7670.PIOPins {
+	t.Helper()
+	plan, err := Camera7670.CreatePortPlan(data)
+	if err != nil {
+		t.Fatalf("CreatePortPlan: %v", err)
+	}
+	return Camera7670.PIOPins{VSync: 0, HREF: 1, PCLK: 2, Data: plan}
+}
+
+// & Wirings of D[7:0] the capture is run with.
+var pioWirings = map[string][]uint8{
+	"contiguous": {8, 9, 10, 11, 12, 13, 14, 15},
+	"reversed":   {15, 14, 13, 12, 11, 10, 9, 8},
+	"scattered":  {3, 5, 7, 16, 17, 20, 22, 28},
+}
+
+func TestPIOProgram(t *testing.T) {
+	pins := pioPins(t, pioWirings["contiguous"]...)
+	capture, err := Camera7670.CreatePIOCapture(pins, 320)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint16{
+		0x80A0,     // pull block
+		0xA027,     // mov x, osr
+		0x2000 | 1, // wait 0 gpio 1 (HREF)
+		0x2080 | 1, // wait 1 gpio 1
+		0x2080 | 2, // wait 1 gpio 2 (PCLK)
+		0x4000 | 8, // in pins, 8
+		0x2000 | 2, // wait 0 gpio 2
+		0x0040 | 4, // jmp x-- 4
+	}
+	if len(capture.Program) != len(want) {
+		t.Fatalf("program has %d instructions, want %d", len(capture.Program), len(want))
+	}
+	for address, instruction := range want {
+		if capture.Program[address] != instruction {
+			t.Errorf("instruction %d = 0x%04X, want 0x%04X", address, capture.Program[address], instruction)
+		}
+	}
+	if capture.WrapTarget != 1 || capture.Wrap != 7 {
+		t.Errorf("wrap = %d to %d, want 1 to 7", capture.WrapTarget, capture.Wrap)
+	}
+	if capture.BytesPerWord != 4 || capture.LineWords != 80 || capture.PinCtrl>>Camera7670.PIO_PINCTRL_IN_BASE_Pos != 8 {
+		t.Errorf("contiguous capture packs %d bytes a word in %d words from GPIO%d", capture.BytesPerWord, capture.LineWords, capture.PinCtrl>>Camera7670.PIO_PINCTRL_IN_BASE_Pos)
+	}
+
+	// A line that is not a whole number of words pushes every byte on its own.
+	odd, _ := Camera7670.CreatePIOCapture(pins, 322)
+	if odd.BytesPerWord != 1 || odd.LineWords != 322 || (odd.ShiftCtrl>>Camera7670.PIO_SHIFTCTRL_PUSH_THRESH_Pos)&0x1F != 8 {
+		t.Errorf("odd line packs %d bytes a word with push threshold %d", odd.BytesPerWord, (odd.ShiftCtrl>>Camera7670.PIO_SHIFTCTRL_PUSH_THRESH_Pos)&0x1F)
+	}
+
+	// Any other wiring samples the whole input register.
+	scattered, _ := Camera7670.CreatePIOCapture(pioPins(t, pioWirings["scattered"]...), 320)
+	if scattered.Program[5] != 0x4000 || scattered.BytesPerWord != 1 || scattered.PinCtrl != 0 {
+		t.Errorf("scattered capture samples with 0x%04X, %d bytes a word, PINCTRL 0x%08X", scattered.Program[5], scattered.BytesPerWord, scattered.PinCtrl)
+	}
+}
+
+// & OneLine Brief = Camera on the colour bars at QQVGA RGB with a PIO backend on the emulator.
+func newPIOCamera(t *testing.T, pins Camera7670.PIOPins) (*Sensor, *Camera7670.OV7670, *SensorPIO, *Camera7670.PIOBackend) {
+	t.Helper()
+	s, Cam := newBarCamera(t, Camera7670.RGB)
+	pio := s.CreatePIO(pins)
+	backend, err := Camera7670.CreatePIOBackend(pio, pins, 0, [2]uint8{0, 1})
+	if err != nil {
+		t.Fatalf("CreatePIOBackend: %v", err)
+	}
+	Cam.Backend = backend
+	return s, Cam, pio, backend
+}
+
+// & Lines of the window the PIO tests capture, a short frame keeps the emulator fast.
+const pioLines = 24
+
+func TestPIOCaptureFrame(t *testing.T) {
+	// 41 pixels are 82 bytes, not a whole number of words, contiguous pins then push every byte on its own.
+	widths := map[string][]int{"contiguous": {40, 41}, "reversed": {40}, "scattered": {40}}
+	for name, data := range pioWirings {
+		for _, width := range widths[name] {
+			s, Cam, pio, _ := newPIOCamera(t, pioPins(t, data...))
+			if err := Cam.SetWindow(0, 0, width, pioLines); err != nil {
+				t.Fatalf("SetWindow: %v", err)
+			}
+			frame, err := DataStructures.CreateImageFromCamera(Cam)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := frame.ReadImage(Cam); err != nil {
+				t.Fatalf("%s %d wide: ReadImage: %v", name, width, err)
+			}
+			if pio.Fault != nil {
+				t.Errorf("%s %d wide: emulator fault: %v", name, width, pio.Fault)
+			}
+			if !bytes.Equal(frame.ImageData, s.Frame()) {
+				t.Errorf("%s %d wide: captured frame differs from the frame of the sensor", name, width)
+			}
+		}
+	}
+}
+
+func TestPIOLineHandOver(t *testing.T) {
+	s, Cam, pio, backend := newPIOCamera(t, pioPins(t, pioWirings["contiguous"]...))
+	if err := Cam.SetWindow(0, 0, 40, pioLines); err != nil {
+		t.Fatalf("SetWindow: %v", err)
+	}
+	g := s.Geometry()
+	golden := s.Frame()
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+
+	if err := backend.StartFrame(ctx, Cam, g.LineBytes); err != nil {
+		t.Fatalf("StartFrame: %v", err)
+	}
+	buffers := [2]uint32{pio.ReadDMA(Camera7670.DMA_WRITE_ADDR), pio.ReadDMA(Camera7670.DMA_CHANNEL_STRIDE + Camera7670.DMA_WRITE_ADDR)}
+	if buffers[0] == buffers[1] {
+		t.Fatalf("both channels write to 0x%08X", buffers[0])
+	}
+
+	line := make([]byte, g.LineBytes)
+	for row := 0; row < 4; row++ {
+		count, err := backend.ReadLine(ctx, Cam, line, 1)
+		if err != nil || count != g.LineBytes {
+			t.Fatalf("line %d: ReadLine = %d, %v", row, count, err)
+		}
+		if !bytes.Equal(line, golden[row*g.LineBytes:(row+1)*g.LineBytes]) {
+			t.Errorf("line %d differs from the frame of the sensor", row)
+		}
+		// The channel of the line got its buffer back and its done flag cleared.
+		channel := uint32(row % 2)
+		if write := pio.ReadDMA(channel*Camera7670.DMA_CHANNEL_STRIDE + Camera7670.DMA_WRITE_ADDR); write != buffers[channel] {
+			t.Errorf("line %d: channel %d writes at 0x%08X, want its buffer 0x%08X", row, channel, write, buffers[channel])
+		}
+		if pio.ReadDMA(Camera7670.DMA_INTR)&(1<<channel) != 0 {
+			t.Errorf("line %d: done flag of channel %d still set", row, channel)
+		}
+	}
+
+	// Falling 3 lines behind overruns both buffers, the backend has to notice instead of handing over a mix of lines.
+	s.Advance(3 * s.byteTicks() * uint64(g.LineClocks()))
+	if _, err := backend.ReadLine(ctx, Cam, line, 1); err == nil || !strings.Contains(err.Error(), "lines were lost") {
+		t.Errorf("ReadLine after an overrun = %v, want lost lines", err)
+	}
+	backend.EndFrame(Cam)
+	if pio.Fault != nil {
+		t.Errorf("emulator fault: %v", pio.Fault)
+	}
+}
@@ -0,0 +1,224 @@
+package Sim7670
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+)
+
+/*
+~ File Description:
+^ Scenes are what the Simulated OV7670 is looking at, plus the encoding of scene pixels into sensor bytes.
+^ Synthetic scenes are generated from code, file based scenes are decoded with the image package (PNG, JPEG, GIF).
+*/
+
+/*
+ * @brief = Source of light for the simulated sensor.
+ * @method Sample = Colour at pixel (x, y) of a width x height output frame.
+ */
+type Scene interface {
+	Sample(x, y, width, height int) (r, g, b uint8)
+}
+
+// & Whole frame filled with one colour.
+type SolidScene struct {
+	R, G, B uint8
+}
+
+func (sc SolidScene) Sample(x, y, width, height int) (uint8, uint8, uint8) {
+	return sc.R, sc.G, sc.B
+}
+
+// & Horizontal black to white ramp.
+type GradientScene struct{}
+
+func (sc GradientScene) Sample(x, y, width, height int) (uint8, uint8, uint8) {
+	v := uint8(x * 255 / max(width-1, 1))
+	return v, v, v
+}
+
+// & Eight vertical bars: white, yellow, cyan, green, magenta, red, blue, black.
+type ColourBarScene struct{}
+
+var colourBars = [8][3]uint8{
+	{255, 255, 255},
+	{255, 255, 0},
+	{0, 255, 255},
+	{0, 255, 0},
+	{255, 0, 255},
+	{255, 0, 0},
+	{0, 0, 255},
+	{0, 0, 0},
+}
+
+func (sc ColourBarScene) Sample(x, y, width, height int) (uint8, uint8, uint8) {
+	bar := colourBars[x*8/max(width, 1)]
+	return bar[0], bar[1], bar[2]
+}
+
+// & Black and white squares of Size pixels.
+type CheckerScene struct {
+	Size int
+}
+
+func (sc CheckerScene) Sample(x, y, width, height int) (uint8, uint8, uint8) {
+	size := max(sc.Size, 1)
+	if (x/size+y/size)%2 == 0 {
+		return 255, 255, 255
+	}
+	return 0, 0, 0
+}
+
+/*
+ * @brief = Scene backed by an image, scaled to the output size with nearest neighbour sampling.
+ * @element Image = Any decoded image.
+ */
+type ImageScene struct {
+	Image image.Image
+}
+
+func (sc ImageScene) Sample(x, y, width, height int) (uint8, uint8, uint8) {
+	bounds := sc.Image.Bounds()
+	px := bounds.Min.X + x*bounds.Dx()/max(width, 1)
+	py := bounds.Min.Y + y*bounds.Dy()/max(height, 1)
+	r, g, b, _ := sc.Image.At(px, py).RGBA()
+	return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)
+}
+
+/*
+ * @brief = Loads a PNG, JPEG or GIF file as a scene.
+ * @param path = Path of the image file.
+ * @return = ImageScene and an error if the file could not be opened or decoded.
+ */
+func LoadScene(path string) (*ImageScene, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("Sim7670: failed to decode scene %s: %w", path, err)
+	}
+
+	return &ImageScene{Image: img}, nil
+}
+
+// & OneLine Brief = Samples the test pattern or the scene at a window pixel, black when there is none.
+func (s *Sensor) sample(x, y int) (uint8, uint8, uint8) {
+	g := s.Geometry()
+	x, y = x+g.OffsetX, y+g.OffsetY
+	if g.Pattern != Camera7670.TEST_PATTERN_OFF {
+		return g.Pattern.Pixel(x, y, g.FullWidth, g.FullHeight)
+	}
+	if s.Scene == nil {
+		return 0, 0, 0
+	}
+	if g.Mirror {
+		x = g.FullWidth - 1 - x
+	}
+	if g.Flip {
+		y = g.FullHeight - 1 - y
+	}
+	return s.Scene.Sample(x, y, g.FullWidth, g.FullHeight)
+}
+
+// & OneLine Brief = Returns the bytes of an active line, rendered once per line per frame.
+func (s *Sensor) lineBytes(row int) []byte {
+	frame := s.tick / s.FrameTicks()
+	if s.lineCache == nil || s.lineRow != row || s.lineFrame != frame {
+		s.lineCache = s.renderLine(row)
+		s.lineRow = row
+		s.lineFrame = frame
+	}
+	return s.lineCache
+}
+
+/*
+ * @brief = Encodes one output line in the pixel format selected by the registers.
+ * @param row = Index of the active line.
+ * @return = LineBytes bytes in the order they leave D[7:0].
+ */
+func (s *Sensor) renderLine(row int) []byte {
+	g := s.Geometry()
+	line := make([]byte, g.LineBytes)
+
+	// The walk is laid out over the whole frame, the window cuts it like the image.
+	if g.Pattern == Camera7670.TEST_PATTERN_SHIFTING_ONES {
+		for index := range line {
+			line[index] = Camera7670.ShiftingOnes(index+g.OffsetX*g.BytesPerPixel, row+g.OffsetY)
+		}
+		return line
+	}
+
+	for x := 0; x < g.Width; x++ {
+		r, gr, b := s.sample(x, row)
+		switch g.Format {
+		case FormatBayer:
+			// BGGR pattern, blue and green on even rows, green and red on odd rows.
+			switch {
+			case row%2 == 0 && x%2 == 0:
+				line[x] = b
+			case row%2 == 1 && x%2 == 1:
+				line[x] = r
+			default:
+				line[x] = gr
+			}
+		case FormatRGB565:
+			v := uint16(r>>3)<<11 | uint16(gr>>2)<<5 | uint16(b>>3)
+			line[2*x], line[2*x+1] = uint8(v>>8), uint8(v)
+		case FormatRGB555:
+			v := uint16(r>>3)<<10 | uint16(gr>>3)<<5 | uint16(b>>3)
+			line[2*x], line[2*x+1] = uint8(v>>8), uint8(v)
+		case FormatRGB444:
+			if !Camera7670.ParseRGB444(s.Registers[Camera7670.REG_RGB444]).WordFormatRGBx { // xR GB
+				line[2*x], line[2*x+1] = r>>4, gr&0xF0|b>>4
+			} else { // RG Bx
+				line[2*x], line[2*x+1] = r&0xF0|gr>>4, b&0xF0
+			}
+		case FormatYUV422:
+			y, u, v := rgbToYUV(r, gr, b)
+			s.placeYUV(line, x, y, u, v)
+		}
+	}
+
+	return line
+}
+
+/*
+ * @brief = Places the Y and the shared chroma byte of pixel x according to TSLB[3] and COM13[0].
+ * ^ 00 = Y U Y V, 01 = Y V Y U, 10 = U Y V Y, 11 = V Y U Y.
+ */
+func (s *Sensor) placeYUV(line []byte, x int, y, u, v uint8) {
+	uOnEven := !Camera7670.ParseCOM13(s.Registers[Camera7670.REG_COM13]).UVSwap
+	chromaFirst := Camera7670.ParseTSLB(s.Registers[Camera7670.REG_TSLB]).UVFirst
+
+	chroma := v
+	if (x%2 == 0) == uOnEven {
+		chroma = u
+	}
+
+	if chromaFirst {
+		line[2*x], line[2*x+1] = chroma, y
+	} else {
+		line[2*x], line[2*x+1] = y, chroma
+	}
+}
+
+// & OneLine Brief = BT.601 full range RGB to YCbCr in integer maths.
+func rgbToYUV(r, g, b uint8) (uint8, uint8, uint8) {
+	R, G, B := int(r), int(g), int(b)
+	y := (77*R + 150*G + 29*B) >> 8
+	u := ((-43*R - 85*G + 128*B) >> 8) + 128
+	v := ((128*R - 107*G - 21*B) >> 8) + 128
+	return clamp(y), clamp(u), clamp(v)
+}
+
+func clamp(v int) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
@@ -0,0 +1,268 @@
+package Sim7670
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	"fmt"
+)
+
+/*
+~ File Description:
+^ Simulated OV7670 Image Sensor used to run the driver and the capture loops on a host machine.
+^ Keeps the full register file which is reachable through the same RegisterBus as the real I2C (SCCB) bus.
+^ The waveform side (VSync, HREF, PCLK and D[7:0]) lives in Waveform.go.
+*/
+
+// & Compile time checks that the simulator plugs into the driver interfaces.
+var (
+	_ Camera7670.RegisterBus = (*Sensor)(nil)
+	_ Camera7670.ClockSource = (*Sensor)(nil)
+	_ Camera7670.InputPin    = SignalPin{}
+	_ Camera7670.DataPort    = DataPort{}
+	_ Camera7670.OutputPin   = ControlPin{}
+)
+
+// & Default PollsPerClock, enough for the driver to sample HREF and PCLK in between two edges.
+const DEFAULT_POLLS_PER_CLOCK = 4
+
+// & Power on values of the registers the simulator cares about, everything else resets to 0x00.
+var defaultRegisters = map[uint8]uint8{
+	Camera7670.REG_GAIN:               0x00,
+	Camera7670.REG_BLUE:               0x80,
+	Camera7670.REG_RED:                0x80,
+	Camera7670.REG_VREF:               0x03,
+	Camera7670.REG_PID:                0x76,
+	Camera7670.REG_VER:                0x73,
+	Camera7670.REG_AECH:               0x40,
+	Camera7670.REG_CLKRC:              0x80,
+	Camera7670.REG_COM8:               0x8F,
+	Camera7670.REG_COM9:               0x4A,
+	Camera7670.REG_HSTART:             0x11,
+	Camera7670.REG_HSTOP:              0x61,
+	Camera7670.REG_VSTRT:              0x03,
+	Camera7670.REG_VSTOP:              0x7B,
+	Camera7670.REG_MIDH:               0x7F,
+	Camera7670.REG_MIDL:               0xA2,
+	Camera7670.REG_MVFP:               0x01,
+	Camera7670.REG_AEW:                0x75,
+	Camera7670.REG_AEB:                0x63,
+	Camera7670.REG_HREF:               0x80,
+	Camera7670.REG_TSLB:               0x0D,
+	Camera7670.REG_COM13:              0x88,
+	Camera7670.REG_COM15:              0xC0,
+	Camera7670.REG_GGAIN:              0x00,
+	Camera7670.REG_DBLV:               0x0A,
+	Camera7670.REG_SCALING_XSC:        0x3A,
+	Camera7670.REG_SCALING_YSC:        0x35,
+	Camera7670.REG_SCALING_DCWCTR:     0x11,
+	Camera7670.REG_SCALING_PCLK_DIV:   0xF0,
+	Camera7670.REG_SCALING_PCLK_DELAY: 0x02,
+	Camera7670.REG_MTX1:               0x40,
+	Camera7670.REG_MTX2:               0x34,
+	Camera7670.REG_MTX3:               0x0C,
+	Camera7670.REG_MTX4:               0x17,
+	Camera7670.REG_MTX5:               0x29,
+	Camera7670.REG_MTX6:               0x40,
+	Camera7670.REG_BRIGHT:             0x00,
+	Camera7670.REG_CONTRAS:            0x40,
+	Camera7670.REG_CONTRAS_CENTER:     0x80,
+	Camera7670.REG_MTXS:               0x1E,
+	Camera7670.REG_EDGE:               0x00,
+	Camera7670.REG_REG75:              0x0F,
+	Camera7670.REG_REG76:              0x01,
+	Camera7670.REG_SLOP:               0x24,
+	Camera7670.REG_GAM1:               0x04,
+	Camera7670.REG_GAM2:               0x07,
+	Camera7670.REG_GAM3:               0x10,
+	Camera7670.REG_GAM4:               0x28,
+	Camera7670.REG_GAM5:               0x36,
+	Camera7670.REG_GAM6:               0x44,
+	Camera7670.REG_GAM7:               0x52,
+	Camera7670.REG_GAM8:               0x60,
+	Camera7670.REG_GAM9:               0x6C,
+	Camera7670.REG_GAM10:              0x78,
+	Camera7670.REG_GAM11:              0x8C,
+	Camera7670.REG_GAM12:              0x9E,
+	Camera7670.REG_GAM13:              0xBB,
+	Camera7670.REG_GAM14:              0xD2,
+	Camera7670.REG_GAM15:              0xE6,
+}
+
+// & Identification registers which ignore writes.
+var readOnlyRegisters = map[uint8]bool{
+	Camera7670.REG_PID:  true,
+	Camera7670.REG_VER:  true,
+	Camera7670.REG_MIDH: true,
+	Camera7670.REG_MIDL: true,
+}
+
+/*
+ * @brief = Simulated OV7670.
+ * @element Address = I2C Address the sensor answers to.
+ * @element Registers = The full register file 0x00 - 0xFF.
+ * @element Scene = Source of the light falling on the sensor.
+ * @element MCLKFrequency = Frequency given to Start, 0 while the clock is stopped.
+ * @element PoweredDown = Level of the PWDN input, the outputs and SCCB are off while it is high. The registers are kept.
+ * @element InReset = The RESET input is held low, the registers are at their power on values and SCCB is off.
+ * @element Writes = Number of register writes received, handy to check a configuration sequence.
+ * @element PollsPerClock = Pin samples per half period of the internal clock, how much faster the host polls than the sensor runs.
+ */
+type Sensor struct {
+	Address       uint8
+	Registers     [256]uint8
+	Scene         Scene
+	MCLKFrequency uint64
+	PoweredDown   bool
+	InReset       bool
+	Writes        int
+	PollsPerClock int
+
+	tick      uint64
+	geometry  Geometry
+	dirty     bool
+	lineCache []byte
+	lineRow   int
+	lineFrame uint64
+}
+
+/*
+ * @brief = Creates a pointer to a Simulated OV7670 in its power on state.
+ * @param scene = Scene the sensor looks at, nil gives a black frame.
+ * @return = pointer of a Sensor Object.
+ */
+func CreateSensor(scene Scene) *Sensor {
+	sensor := &Sensor{Address: Camera7670.DEFAULT_OV7670_ADDRESS, Scene: scene, PollsPerClock: DEFAULT_POLLS_PER_CLOCK}
+	sensor.PowerOnReset()
+	return sensor
+}
+
+/*
+& OneLine Brief = Loads the power on register values and restarts the frame timing.
+*/
+func (s *Sensor) PowerOnReset() {
+	s.Registers = [256]uint8{}
+	for reg, val := range defaultRegisters {
+		s.Registers[reg] = val
+	}
+	s.tick = 0
+	s.dirty = true
+	s.lineCache = nil
+}
+
+/*
+ * @brief = RegisterBus implementation, writes data starting at reg. Auto-increments like SCCB does.
+ * @return = error if the address does not match (NACK on real hardware).
+ */
+func (s *Sensor) WriteRegister(address uint8, reg uint8, data []byte) error {
+	if err := s.sccb(address); err != nil {
+		return err
+	}
+
+	for _, val := range data {
+		s.Writes++
+		switch {
+		case readOnlyRegisters[reg]:
+		case reg == Camera7670.REG_COM7 && Camera7670.ParseCOM7(val).Reset:
+			s.PowerOnReset()
+		default:
+			s.Registers[reg] = val
+		}
+		reg++
+	}
+	s.dirty = true
+	s.lineCache = nil
+
+	return nil
+}
+
+/*
+ * @brief = RegisterBus implementation, reads len(data) registers starting at reg.
+ * @return = error if the address does not match (NACK on real hardware).
+ */
+func (s *Sensor) ReadRegister(address uint8, reg uint8, data []byte) error {
+	if err := s.sccb(address); err != nil {
+		return err
+	}
+
+	for i := range data {
+		data[i] = s.Registers[reg]
+		reg++
+	}
+
+	return nil
+}
+
+/*
+ * @brief = ClockSource implementation, feeds XCLK to the sensor. Without it the sensor outputs nothing.
+ * @param frequency = Frequency of MCLK in Hz.
+ * @return = error if the frequency is outside the 10 - 48 MHz the OV7670 accepts.
+ */
+func (s *Sensor) Start(frequency uint64) error {
+	if frequency < 10_000_000 || frequency > 48_000_000 {
+		return fmt.Errorf("Sim7670: XCLK of %d Hz is outside 10 - 48 MHz", frequency)
+	}
+	s.MCLKFrequency = frequency
+	return nil
+}
+
+// & OneLine Brief = ClockSource implementation, stops XCLK. The sensor stops in the middle of its frame.
+func (s *Sensor) Stop() error {
+	s.MCLKFrequency = 0
+	return nil
+}
+
+// & OneLine Brief = Checks that the sensor answers on SCCB, error if the address does not match or the sensor is off.
+func (s *Sensor) sccb(address uint8) error {
+	switch {
+	case address != s.Address:
+		return fmt.Errorf("Sim7670: no device at address 0x%02X", address)
+	case s.PoweredDown:
+		return fmt.Errorf("Sim7670: powered down (PWDN high)")
+	case s.InReset:
+		return fmt.Errorf("Sim7670: held in reset (RESET low)")
+	}
+
+	return nil
+}
+
+// & OneLine Brief = Reports whether the sensor is clocking out frames: MCLK running, not powered down, in reset or in soft sleep.
+func (s *Sensor) Running() bool {
+	return s.MCLKFrequency != 0 && !s.PoweredDown && !s.InReset && !Camera7670.ParseCOM2(s.Registers[Camera7670.REG_COM2]).SoftSleep
+}
+
+/*
+ * @brief = OutputPin of the driver wired to the PWDN or RESET input of the Simulated OV7670.
+ * ^ Holding RESET low loads the power on register values.
+ */
+type ControlPin struct {
+	sensor *Sensor
+	reset  bool
+}
+
+func (p ControlPin) Set(high bool) {
+	if !p.reset {
+		p.sensor.PoweredDown = high
+		return
+	}
+
+	if !high {
+		p.sensor.PowerOnReset()
+	}
+	p.sensor.InReset = !high
+}
+
+// & OneLine Brief = PWDN input of the sensor, assign it to the PWDN field of the driver.
+func (s *Sensor) PWDN() ControlPin {
+	return ControlPin{sensor: s}
+}
+
+// & OneLine Brief = RESET input of the sensor (active low), assign it to the RESET field of the driver.
+func (s *Sensor) RESET() ControlPin {
+	return ControlPin{sensor: s, reset: true}
+}
+
+/*
+& OneLine Brief = Returns a pointer to a driver connected to this sensor, just like CreateOV7670 does with real pins.
+*/
+func (s *Sensor) CreateOV7670() *Camera7670.OV7670 {
+	return Camera7670.CreateOV7670(s, s.VSync(), s.HSync(), s, s.PCLK(), s.DataPins())
+}
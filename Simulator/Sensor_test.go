@@ -0,0 +1,270 @@
+package Sim7670
+
+import (
+	Camera7670 "PICO_OV7670/Camera"
+	DataStructures "PICO_OV7670/DS"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+/*
+~ File Description:
+^ End to end host tests: the driver configures the simulated sensor over its register bus and the DS capture loops read it.
+^ The colour bars have known RGB565 and Y values, so the captures are checked against frames worked out by hand.
+*/
+
+// & RGB565 and BT.601 Y of the eight colour bars.
+var (
+	barRGB565 = [8]uint16{0xFFFF, 0xFFE0, 0x07FF, 0x07E0, 0xF81F, 0xF800, 0x001F, 0x0000}
+	barY      = [8]uint8{255, 226, 178, 149, 105, 76, 28, 0}
+)
+
+// & OneLine Brief = Frame of the colour bars at QQVGA as an image of a format stores it.
+func knownBars(image Camera7670.IMAGE) []byte {
+	const width, height = 160, 120
+	frame := make([]byte, 0, width*height*2)
+	for row := 0; row < height; row++ {
+		for x := 0; x < width; x++ {
+			bar := x * 8 / width
+			if image == Camera7670.GREYSCALED {
+				frame = append(frame, barY[bar])
+			} else {
+				frame = append(frame, uint8(barRGB565[bar]>>8), uint8(barRGB565[bar]))
+			}
+		}
+	}
+	return frame
+}
+
+// & OneLine Brief = Driver of a simulated sensor without a capture timeout, so the tests follow ticks and not the speed of the host.
+func newSimCamera(s *Sensor) *Camera7670.OV7670 {
+	Cam := s.CreateOV7670()
+	Cam.Timeout = 0
+	return Cam
+}
+
+// & OneLine Brief = Simulated sensor showing the colour bars with a camera configured for image at QQVGA.
+func newBarCamera(t *testing.T, image Camera7670.IMAGE) (*Sensor, *Camera7670.OV7670) {
+	t.Helper()
+	s := CreateSensor(ColourBarScene{})
+	Cam := newSimCamera(s)
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := Cam.Configure(image, Camera7670.QQVGA); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := Cam.SetPCLKSpeed(Camera7670.PCLK_DIV0); err != nil {
+		t.Fatalf("SetPCLKSpeed: %v", err)
+	}
+	return s, Cam
+}
+
+type memoryCard struct{ data []byte }
+
+func (m *memoryCard) WriteAt(p []byte, offset int64) (int, error) {
+	if end := int(offset) + len(p); end > len(m.data) {
+		m.data = append(m.data, make([]byte, end-len(m.data))...)
+	}
+	return copy(m.data[offset:], p), nil
+}
+
+func TestReadImageKnownFrame(t *testing.T) {
+	for _, image := range []Camera7670.IMAGE{Camera7670.GREYSCALED, Camera7670.RGB} {
+		s, Cam := newBarCamera(t, image)
+		frame, err := DataStructures.CreateImageFromCamera(Cam)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := frame.ReadImage(Cam); err != nil {
+			t.Fatalf("%s: ReadImage: %v", image, err)
+		}
+		if !bytes.Equal(frame.ImageData, knownBars(image)) {
+			t.Errorf("%s: captured frame differs from the colour bars", image)
+		}
+		if image == Camera7670.RGB && !bytes.Equal(frame.ImageData, s.Frame()) {
+			t.Errorf("RGB: captured frame differs from the frame of the sensor")
+		}
+	}
+}
+
+func TestStoreImageKnownFrame(t *testing.T) {
+	const address = 512
+	for _, image := range []Camera7670.IMAGE{Camera7670.GREYSCALED, Camera7670.RGB} {
+		_, Cam := newBarCamera(t, image)
+		card := &memoryCard{}
+		if err := DataStructures.StoreImage(Cam, card, address, Camera7670.QQVGA, image); err != nil {
+			t.Fatalf("%s: StoreImage: %v", image, err)
+		}
+		if len(card.data) < address || !bytes.Equal(card.data[address:], knownBars(image)) {
+			t.Errorf("%s: stored frame differs from the colour bars", image)
+		}
+	}
+}
+
+func TestStreamImageRows(t *testing.T) {
+	_, Cam := newBarCamera(t, Camera7670.RGB)
+	want := knownBars(Camera7670.RGB)
+	next := 0
+	sink := DataStructures.RowSinkFunc(func(index int, format DataStructures.RowFormat, line []byte) error {
+		if index != next {
+			t.Fatalf("row %d arrived, want row %d", index, next)
+		}
+		if format.Width != 160 || format.Height != 120 || format.BytesPerPixel != 2 {
+			t.Fatalf("row format = %+v, want 160x120 at 2 bytes per pixel", format)
+		}
+		if !bytes.Equal(line, want[index*format.RowBytes():(index+1)*format.RowBytes()]) {
+			t.Errorf("row %d differs from the colour bars", index)
+		}
+		next++
+		return nil
+	})
+
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	if err := DataStructures.StreamImageContext(ctx, sink, Cam, Camera7670.RGB, Camera7670.QQVGA); err != nil {
+		t.Fatalf("StreamImageContext: %v", err)
+	}
+	if next != 120 {
+		t.Errorf("%d rows streamed, want 120", next)
+	}
+}
+
+// & Grey that brightens with every row, so each row of a frame is told apart from the others.
+type rowScene struct{}
+
+func (sc rowScene) Sample(x, y, width, height int) (uint8, uint8, uint8) {
+	v := uint8(y * 2)
+	return v, v, v
+}
+
+func TestStreamImageSlowSink(t *testing.T) {
+	s, Cam := newBarCamera(t, Camera7670.GREYSCALED)
+	s.Scene = rowScene{}
+	g := s.Geometry()
+	var delivered []uint8
+	sink := DataStructures.RowSinkFunc(func(index int, format DataStructures.RowFormat, line []byte) error {
+		for _, v := range line {
+			if v != line[0] {
+				t.Fatalf("row %d mixes the bytes of several lines", index)
+			}
+		}
+		if index > 0 && line[0] <= delivered[index-1] {
+			t.Fatalf("row %d = %d after %d, rows went out of order", index, line[0], delivered[index-1])
+		}
+		delivered = append(delivered, line[0])
+		// Busy for two lines, as a sink writing to a slow card would be.
+		if index == 10 {
+			s.Advance(2 * s.byteTicks() * uint64(g.LineClocks()))
+		}
+		return nil
+	})
+
+	ctx, cancel := Cam.CaptureContext()
+	defer cancel()
+	err := DataStructures.StreamImageContext(ctx, sink, Cam, Camera7670.GREYSCALED, Camera7670.QQVGA)
+	var corrupted *DataStructures.CorruptedImageError
+	if !errors.As(err, &corrupted) {
+		t.Fatalf("StreamImageContext with a slow sink = %v, want a *CorruptedImageError", err)
+	}
+	if corrupted.Row != len(delivered) || corrupted.Lost != 120-len(delivered) || corrupted.Lost < 2 {
+		t.Errorf("%d rows delivered, error reports %d done and %d lost", len(delivered), corrupted.Row, corrupted.Lost)
+	}
+}
+
+func TestConfigureCustomScaler(t *testing.T) {
+	// 200x150 needs the 1/2 down sampler plus the fractional scaler, a window alone would cut the outer bars off.
+	const width, height = 200, 150
+	s := CreateSensor(ColourBarScene{})
+	Cam := newSimCamera(s)
+	if err := Cam.Initialize(20_000_000); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if err := Cam.ConfigureCustom(Camera7670.RGB, width, height); err != nil {
+		t.Fatalf("ConfigureCustom: %v", err)
+	}
+	if err := Cam.SetPCLKSpeed(Camera7670.PCLK_DIV0); err != nil {
+		t.Fatalf("SetPCLKSpeed: %v", err)
+	}
+	if g := s.Geometry(); g.Width != width || g.Height != height {
+		t.Fatalf("sensor outputs %dx%d, want %dx%d", g.Width, g.Height, width, height)
+	}
+
+	frame, err := DataStructures.CreateImageFromCamera(Cam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := frame.ReadImage(Cam); err != nil {
+		t.Fatalf("ReadImage: %v", err)
+	}
+	if len(frame.ImageData) != width*height*2 || !bytes.Equal(frame.ImageData, s.Frame()) {
+		t.Fatalf("captured %d bytes, want the %dx%d frame of the sensor", len(frame.ImageData), width, height)
+	}
+	// Every bar is still in view at its place across the frame.
+	for bar, want := range barRGB565 {
+		x := (2*bar + 1) * width / 16
+		if got := uint16(frame.ImageData[2*x])<<8 | uint16(frame.ImageData[2*x+1]); got != want {
+			t.Errorf("pixel %d = %#04x, want bar %d %#04x", x, got, bar, want)
+		}
+	}
+}
+
+// & Data port with the data lines wired to other bits, line D[i] arrives on bit order[i].
+type swappedPort struct {
+	port  Camera7670.DataPort
+	order [8]int
+}
+
+func (p swappedPort) Read() uint8 {
+	var read uint8
+	for line, bit := range p.order {
+		if p.port.Read()&(1<<line) != 0 {
+			read |= 1 << bit
+		}
+	}
+	return read
+}
+
+func TestCalibrateDataPinsWindowed(t *testing.T) {
+	// The window does not start at the frame corner, calibration has to see the pattern of the whole frame.
+	wiring := [8]int{1, 0, 2, 3, 7, 6, 5, 4}
+	for _, order := range [][8]int{{0, 1, 2, 3, 4, 5, 6, 7}, wiring} {
+		s, Cam := newBarCamera(t, Camera7670.RGB)
+		if err := Cam.SetWindow(3, 5, 40, 30); err != nil {
+			t.Fatalf("SetWindow: %v", err)
+		}
+		Cam.DataPins = swappedPort{port: s.DataPins(), order: order}
+
+		calibration, err := DataStructures.CalibrateDataPins(Cam)
+		if err != nil {
+			t.Fatalf("CalibrateDataPins: %v", err)
+		}
+		if calibration.Order != order || !calibration.Mapped() || calibration.Mismatches != 0 {
+			t.Errorf("calibration of wiring %v = %s, order %v", order, calibration, calibration.Order)
+		}
+		if Cam.Window() != (Camera7670.Window{X: 3, Y: 5, Width: 40, Height: 30}) || Cam.TestPattern() != Camera7670.TEST_PATTERN_OFF {
+			t.Errorf("window %+v and pattern %s were not put back", Cam.Window(), Cam.TestPattern())
+		}
+	}
+}
+
+func TestVerifyShiftingOnesWindowed(t *testing.T) {
+	_, Cam := newBarCamera(t, Camera7670.GREYSCALED)
+	if err := Cam.SetWindow(7, 3, 32, 16); err != nil {
+		t.Fatalf("SetWindow: %v", err)
+	}
+	if err := Cam.SetTestPattern(Camera7670.TEST_PATTERN_SHIFTING_ONES); err != nil {
+		t.Fatalf("SetTestPattern: %v", err)
+	}
+	frame, err := DataStructures.CreateImageFromCamera(Cam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := frame.ReadImage(Cam); err != nil {
+		t.Fatalf("ReadImage: %v", err)
+	}
+	if err := frame.VerifyTestPattern(Cam); err != nil {
+		t.Errorf("VerifyTestPattern: %v", err)
+	}
+}
@@ -0,0 +1,382 @@
+package Sim7670
+
+import Camera7670 "PICO_OV7670/Camera"
+
+/*
+~ File Description:
+^ Generates the VSync, HREF, PCLK and D[7:0] waveforms of the Simulated OV7670.
+^ Output size and pixel format follow COM7, COM3, COM14, SCALING_DCWCTR, SCALING_XSC/YSC, COM15, RGB444, TSLB and COM13.
+^ Unless TSLB auto window is set, the window registers (HSTART/HSTOP/HREF, VSTRT/VSTOP/VREF) crop the output.
+^ Pixel clock speed follows CLKRC and COM14, polarities and PCLK gating follow COM10.
+^
+^ Time is measured in ticks. Every sample of VSync, HREF or PCLK advances the sensor by one tick, so a host
+^ that keeps polling a pin always sees it change eventually, exactly like the busy loops in the driver expect.
+^ One half period of PCLK lasts PCLKDivider * PollsPerClock ticks.
+*/
+
+// & Output pixel format decoded from the registers.
+type Format int
+
+const (
+	FormatYUV422 Format = iota
+	FormatRGB565
+	FormatRGB555
+	FormatRGB444
+	FormatBayer
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatYUV422:
+		return "YUV422"
+	case FormatRGB565:
+		return "RGB565"
+	case FormatRGB555:
+		return "RGB555"
+	case FormatRGB444:
+		return "RGB444"
+	case FormatBayer:
+		return "BAYER"
+	}
+
+	return "NOT VALID"
+}
+
+// & Fixed frame timing of the simulator (in lines).
+const (
+	VSYNC_LINES       = 3
+	BACK_PORCH_LINES  = 17
+	FRONT_PORCH_LINES = 10
+)
+
+/*
+ * @brief = Frame geometry and timing decoded from the register file.
+ * @elements Width, Height = Output size in pixels, after the window.
+ * @elements FullWidth, FullHeight = Size of the whole scaled frame the window is cut from.
+ * @elements OffsetX, OffsetY = Position of the window inside the whole frame.
+ * @elements Mirror, Flip = Readout direction of the whole frame (MVFP).
+ * @element Pattern = Test pattern output instead of the scene.
+ * @element BytesPerPixel = Bytes clocked out per pixel, 2 for YUV/RGB and 1 for Bayer.
+ * @element LineBytes = PCLK cycles while HREF is high.
+ * @element BlankBytes = PCLK cycles while HREF is low in between two lines, dummy pixels (EXHCH/EXHCL) included.
+ * @element DummyLines = Blank lines added to the end of the frame (DM_LNH/DM_LNL).
+ * @element PCLKDivider = Internal clock half periods per half period of PCLK.
+ */
+type Geometry struct {
+	Width         int
+	Height        int
+	FullWidth     int
+	FullHeight    int
+	OffsetX       int
+	OffsetY       int
+	Mirror        bool
+	Flip          bool
+	Pattern       Camera7670.TEST_PATTERN
+	Format        Format
+	BytesPerPixel int
+	LineBytes     int
+	BlankBytes    int
+	DummyLines    int
+	PCLKDivider   int
+}
+
+// & OneLine Brief = Total PCLK cycles in a line, active plus blanking.
+func (g Geometry) LineClocks() int {
+	return g.LineBytes + g.BlankBytes
+}
+
+// & OneLine Brief = Total lines in a frame, sync plus porches plus active lines.
+func (g Geometry) FrameLines() int {
+	return VSYNC_LINES + BACK_PORCH_LINES + g.Height + FRONT_PORCH_LINES + g.DummyLines
+}
+
+// & OneLine Brief = Length of one PCLK period in ticks.
+func (s *Sensor) byteTicks() uint64 {
+	return uint64(2 * s.Geometry().PCLKDivider * max(s.PollsPerClock, 1))
+}
+
+// & OneLine Brief = Length of one complete frame in ticks.
+func (s *Sensor) FrameTicks() uint64 {
+	g := s.Geometry()
+	return s.byteTicks() * uint64(g.LineClocks()) * uint64(g.FrameLines())
+}
+
+/*
+ * @brief = Decodes the current output geometry from the register file.
+ * @return = Geometry of the frames the sensor is producing right now.
+ */
+func (s *Sensor) Geometry() Geometry {
+	if s.dirty {
+		s.geometry = s.decodeGeometry()
+		s.dirty = false
+	}
+	return s.geometry
+}
+
+func (s *Sensor) decodeGeometry() Geometry {
+	var g Geometry
+	com7 := Camera7670.ParseCOM7(s.Registers[Camera7670.REG_COM7])
+	com14 := Camera7670.ParseCOM14(s.Registers[Camera7670.REG_COM14])
+
+	// COM3 DCW enable together with COM14 manual scaling uses the down sampling of SCALING_DCWCTR.
+	var h_rate, v_rate uint8
+	com3 := Camera7670.ParseCOM3(s.Registers[Camera7670.REG_COM3])
+	if com3.DCWEnable && com14.ManualScaling {
+		dcw := Camera7670.ParseSCALING_DCWCTR(s.Registers[Camera7670.REG_SCALING_DCWCTR])
+		h_rate, v_rate = dcw.HorizontalRate, dcw.VerticalRate
+	}
+	// A reversed readout starts one pixel or line later, windows that are not corrected for it are shifted.
+	mvfp := Camera7670.ParseMVFP(s.Registers[Camera7670.REG_MVFP])
+	g.Mirror, g.Flip = mvfp.Mirror, mvfp.VFlip
+	g.Pattern = Camera7670.ParseTestPattern(s.Registers[Camera7670.REG_SCALING_XSC], s.Registers[Camera7670.REG_SCALING_YSC], s.Registers[Camera7670.REG_COM17])
+	format := Camera7670.ScaledFormat(com7.Resolution, h_rate, v_rate)
+	// COM3 scale enable zooms the down sampled frame out by SCALING_XSC/YSC[6:0].
+	if com3.ScaleEnable {
+		format = format.Zoomed(s.Registers[Camera7670.REG_SCALING_XSC], s.Registers[Camera7670.REG_SCALING_YSC])
+	}
+	format = format.Oriented(Camera7670.Orientation{Mirror: g.Mirror, Flip: g.Flip})
+	g.Width, g.Height = format.Width, format.Height
+	g.FullWidth, g.FullHeight = g.Width, g.Height
+
+	if !Camera7670.ParseTSLB(s.Registers[Camera7670.REG_TSLB]).AutoWindow {
+		s.cropToWindow(&g, format)
+	}
+
+	rgb444 := Camera7670.ParseRGB444(s.Registers[Camera7670.REG_RGB444]).Enable
+	com15 := Camera7670.ParseCOM15(s.Registers[Camera7670.REG_COM15])
+	switch {
+	case com7.Format&Camera7670.COM7_RAW_BAYER != 0:
+		g.Format = FormatBayer
+	case com7.Format == Camera7670.COM7_RGB && com15.RGB == Camera7670.COM15_RGB565 && rgb444:
+		g.Format = FormatRGB444
+	case com7.Format == Camera7670.COM7_RGB && com15.RGB == Camera7670.COM15_RGB555:
+		g.Format = FormatRGB555
+	case com7.Format == Camera7670.COM7_RGB:
+		g.Format = FormatRGB565
+	default:
+		g.Format = FormatYUV422
+	}
+
+	g.BytesPerPixel = 2
+	if g.Format == FormatBayer {
+		g.BytesPerPixel = 1
+	}
+	g.LineBytes = g.Width * g.BytesPerPixel
+	g.BlankBytes = g.LineBytes * 144 / 640
+	if g.BlankBytes < 16 {
+		g.BlankBytes = 16
+	}
+
+	g.PCLKDivider = Camera7670.ParseCLKRC(s.Registers[Camera7670.REG_CLKRC]).Divider()
+	scaling := 0
+	if com14.ScalingPCLK {
+		scaling = int(min(com14.PCLKDivider, 4))
+		g.PCLKDivider <<= scaling
+	}
+
+	// Dummy pixels last 2 internal clocks each, fewer PCLK cycles when PCLK is divided.
+	dummy_pixels := int(s.Registers[Camera7670.REG_EXHCH]>>4)<<8 | int(s.Registers[Camera7670.REG_EXHCL])
+	g.BlankBytes += (2 * dummy_pixels) >> scaling
+	g.DummyLines = int(s.Registers[Camera7670.REG_DM_LNH])<<8 | int(s.Registers[Camera7670.REG_DM_LNL])
+
+	return g
+}
+
+/*
+ * @brief = Cuts the output window out of the whole frame using the window registers.
+ * @param g = Geometry holding the whole frame size, gets the window size and offset.
+ * @param format = Whole frame, maps sensor pixels and lines to output pixels.
+ */
+func (s *Sensor) cropToWindow(g *Geometry, format Camera7670.FrameFormat) {
+	href := int(s.Registers[Camera7670.REG_HREF])
+	vref := int(s.Registers[Camera7670.REG_VREF])
+	hstart := int(s.Registers[Camera7670.REG_HSTART])<<3 | href&0x07
+	hstop := int(s.Registers[Camera7670.REG_HSTOP])<<3 | (href>>3)&0x07
+	vstart := int(s.Registers[Camera7670.REG_VSTRT])<<2 | vref&0x03
+	vstop := int(s.Registers[Camera7670.REG_VSTOP])<<2 | (vref>>2)&0x03
+
+	hspan := (hstop - hstart + Camera7670.SENSOR_LINE_PIXELS) % Camera7670.SENSOR_LINE_PIXELS
+	vspan := max(vstop-vstart, 0)
+
+	g.OffsetX = min(max(hstart-format.OriginX, 0)*g.FullWidth/format.SpanX, g.FullWidth)
+	g.OffsetY = min(max(vstart-format.OriginY, 0)*g.FullHeight/format.SpanY, g.FullHeight)
+	g.Width = min(hspan*g.FullWidth/format.SpanX, g.FullWidth-g.OffsetX)
+	g.Height = min(vspan*g.FullHeight/format.SpanY, g.FullHeight-g.OffsetY)
+}
+
+/*
+ * @brief = Frequency of PCLK for the current MCLK, DBLV PLL, CLKRC and COM14 settings.
+ * @return = PCLK in Hz, 0 while MCLK is stopped.
+ */
+func (s *Sensor) PCLKFrequency() uint64 {
+	multiplier := [4]uint64{1, 4, 6, 8}[s.Registers[Camera7670.REG_DBLV]>>6]
+	return s.MCLKFrequency * multiplier / uint64(s.Geometry().PCLKDivider)
+}
+
+// & Which waveform a SignalPin exposes.
+type Signal int
+
+const (
+	SIGNAL_VSYNC Signal = iota
+	SIGNAL_HREF
+	SIGNAL_PCLK
+)
+
+func (sig Signal) String() string {
+	switch sig {
+	case SIGNAL_VSYNC:
+		return "VSYNC"
+	case SIGNAL_HREF:
+		return "HREF"
+	case SIGNAL_PCLK:
+		return "PCLK"
+	}
+
+	return "NOT VALID"
+}
+
+/*
+ * @brief = InputPin connected to one of the sync or clock outputs of the Simulated OV7670.
+ * ^ Every Get advances the sensor by one tick.
+ */
+type SignalPin struct {
+	sensor *Sensor
+	signal Signal
+}
+
+func (p SignalPin) Get() bool {
+	p.sensor.Advance(1)
+	return p.sensor.Level(p.signal)
+}
+
+/*
+ * @brief = DataPort connected to D[7:0] of the Simulated OV7670.
+ * ^ Reading the port does not advance time.
+ */
+type DataPort struct {
+	sensor *Sensor
+}
+
+func (p DataPort) Read() uint8 {
+	return p.sensor.Data()
+}
+
+// & OneLine Brief = VSync output of the sensor.
+func (s *Sensor) VSync() SignalPin {
+	return SignalPin{sensor: s, signal: SIGNAL_VSYNC}
+}
+
+// & OneLine Brief = HREF output of the sensor, wired to the HSync input of the driver.
+func (s *Sensor) HSync() SignalPin {
+	return SignalPin{sensor: s, signal: SIGNAL_HREF}
+}
+
+// & OneLine Brief = PCLK output of the sensor.
+func (s *Sensor) PCLK() SignalPin {
+	return SignalPin{sensor: s, signal: SIGNAL_PCLK}
+}
+
+// & OneLine Brief = D[7:0] outputs of the sensor.
+func (s *Sensor) DataPins() DataPort {
+	return DataPort{sensor: s}
+}
+
+/*
+ * @brief = Moves the sensor forward in time. Nothing happens while the sensor is not Running.
+ * @param ticks = Number of ticks to advance.
+ */
+func (s *Sensor) Advance(ticks uint64) {
+	if !s.Running() {
+		return
+	}
+	s.tick += ticks
+}
+
+// & Position of the sensor inside the current frame.
+type position struct {
+	line   int
+	column int
+	high   bool
+}
+
+func (s *Sensor) position() position {
+	g := s.Geometry()
+	pos := s.tick % s.FrameTicks()
+	byteTicks := s.byteTicks()
+	clock := int(pos / byteTicks)
+
+	return position{
+		line:   clock / g.LineClocks(),
+		column: clock % g.LineClocks(),
+		high:   pos%byteTicks >= byteTicks/2,
+	}
+}
+
+// & OneLine Brief = Returns the active row HREF is outputting at the moment, -1 during blanking.
+func (s *Sensor) activeRow() (int, int) {
+	g := s.Geometry()
+	p := s.position()
+	row := p.line - VSYNC_LINES - BACK_PORCH_LINES
+	if row < 0 || row >= g.Height || p.column >= g.LineBytes {
+		return -1, p.column
+	}
+	return row, p.column
+}
+
+/*
+ * @brief = Level of a sensor output at the current tick, including the COM10 polarity settings.
+ * @param sig = Output to sample.
+ * @return = true when the pin is high.
+ */
+func (s *Sensor) Level(sig Signal) bool {
+	if !s.Running() {
+		return false
+	}
+
+	com10 := Camera7670.ParseCOM10(s.Registers[Camera7670.REG_COM10])
+	row, _ := s.activeRow()
+	switch sig {
+	case SIGNAL_VSYNC:
+		return (s.position().line < VSYNC_LINES) != com10.VSyncNegative
+	case SIGNAL_HREF:
+		return (row >= 0) != com10.HREFReverse
+	case SIGNAL_PCLK:
+		high := s.position().high
+		if com10.PCLKGatedByHREF && row < 0 {
+			high = false
+		}
+		return high != com10.PCLKReverse
+	}
+
+	return false
+}
+
+/*
+ * @brief = Byte on D[7:0] at the current tick.
+ * @return = Pixel data while HREF is high, 0x00 during blanking.
+ */
+func (s *Sensor) Data() uint8 {
+	if !s.Running() {
+		return 0
+	}
+
+	row, column := s.activeRow()
+	if row < 0 {
+		return 0
+	}
+	return s.lineBytes(row)[column]
+}
+
+/*
+ * @brief = Renders a whole frame the way it appears on D[7:0], line after line without blanking.
+ * ^ Used as the golden frame to compare captures against.
+ * @return = Width * Height * BytesPerPixel bytes.
+ */
+func (s *Sensor) Frame() []byte {
+	g := s.Geometry()
+	frame := make([]byte, 0, g.LineBytes*g.Height)
+	for row := 0; row < g.Height; row++ {
+		frame = append(frame, s.renderLine(row)...)
+	}
+	return frame
+}
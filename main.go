@@ -7,6 +7,7 @@ import (
 	CORE "PICO_OV7670/CoreFiles"
 	DataStructures "PICO_OV7670/DS"
 	SDController "PICO_OV7670/SDCardController"
+	"errors"
 	"fmt"
 	"machine"
 	"runtime"
@@ -70,8 +71,10 @@ const (
 
 // ^ Program Configurations
 const (
-	UART_ChunkSize  = 2
-	UART_ReliefTime = time.Microsecond
+	UART_ChunkSize   = 2
+	UART_ReliefTime  = time.Microsecond
+	CALIBRATE_PINS   = false // * Checks the order of the Data Pins with the shifting ones test pattern at start up and prints the order to use.
+	CAPTURE_WITH_PIO = false // * Samples the lines with PIO0 state machine 0 and DMA channels 0 and 1 instead of the CPU, PCLK can then run at full speed.
 )
 
 // * Variables
@@ -129,6 +132,19 @@ func main() {
 		ImageFile = &SDController.SDCard{CommunicationLine: machine.UART0}
 
 		// ^ Camera
+		if err := DataPins.Validate(
+			Camera7670.UsePin("MCLK", MCLK),
+			Camera7670.UsePin("PCLK", PCLK),
+			Camera7670.UsePin("VSYNC", VSync),
+			Camera7670.UsePin("HREF", HSync),
+			Camera7670.UsePin("SDA", I2C_SDA),
+			Camera7670.UsePin("SCL", I2C_SCL),
+			Camera7670.UsePin("UART TX", UART_TX),
+			Camera7670.UsePin("UART RX", UART_RX),
+		); err != nil {
+			Display.Print([]byte("BAD DATA PINS"))
+			Application.Exit(1, fmt.Sprintf("Data Pins are not wired right. Error = %v\n", err))
+		}
 		VSync.Configure(machine.PinConfig{Mode: machine.PinInput})
 		HSync.Configure(machine.PinConfig{Mode: machine.PinInput})
 		MCLK.Configure(machine.PinConfig{Mode: machine.PinPWM})
@@ -139,19 +155,49 @@ func main() {
 			machine.I2C0,
 			VSync,
 			HSync,
-			MCLK,
+			Camera7670.CreatePWMClock(MCLK),
 			PCLK,
 			DataPins,
 		)
 
-		Camera.Initialize(MCLKSPEED)
+		if err := Camera.Initialize(MCLKSPEED); err != nil {
+			var probeErr *Camera7670.ProbeError
+			if errors.As(err, &probeErr) {
+				Display.Print([]byte("NO OV7670 FOUND"))
+			}
+			Application.Exit(1, fmt.Sprintf("Failed to Initialize Camera. Error = %v\n", err))
+		}
 		if err := Camera.Configure(ImageColorSpace, ImageResolution); err != nil {
 			Application.Exit(1, fmt.Sprintf("Failed to Configure Camera. Error = %v\n", err))
 		}
 		Camera.SetPCLKSpeed(PCLKSPEED) // * Writes at 0x11 Register Changes the speed of PCLK giving more time to PICO to scan a pixel. Currently it is at the highest value of 0x1F but you can decrease it to further speedify things.
 
+		if CALIBRATE_PINS {
+			calibration, err := DataStructures.CalibrateDataPins(Camera)
+			if err != nil {
+				Application.Exit(1, fmt.Sprintf("Failed to Calibrate Data Pins. Error = %v\n", err))
+			}
+			CORE.PrintLN(fmt.Sprintf("Data Pins: %s", calibration.String()))
+			if ordered, err := Camera7670.ReorderPins(calibration, DataPins.Pins); err == nil && !calibration.Correct() {
+				CORE.PrintLN(fmt.Sprintf("Use the Data Pins in this order: %v", ordered))
+			}
+		}
+
+		if CAPTURE_WITH_PIO {
+			backend, err := Camera7670.CreatePIOBackend(
+				Camera7670.CreateRP2040PIO(),
+				Camera7670.PIOPins{VSync: uint8(VSync), HREF: uint8(HSync), PCLK: uint8(PCLK), Data: DataPins.Plan()},
+				0,
+				[2]uint8{0, 1},
+			)
+			if err != nil {
+				Application.Exit(1, fmt.Sprintf("Failed to Create PIO Capture. Error = %v\n", err))
+			}
+			Camera.Backend = backend
+		}
+
 		// ^ Camera Image Holder
-		Image, _ = DataStructures.CreateImage(ImageColorSpace, ImageResolution)
+		Image, _ = DataStructures.CreateImageFromCamera(Camera)
 
 		// ^ INBUILD LED
 		INBUILT_LED = CORE.CreateIOPin(25, machine.PinOutput)
@@ -169,7 +215,7 @@ func main() {
 			runtime.GC()
 		}
 
-		Image.ReadImage(Camera, false)
+		Image.ReadImage(Camera)
 
 		ImageFile.TurnOnLED()
 		ImageFile.CreateFile(fmt.Sprintf("Frame%d.RID", FrameCounter))